@@ -1,37 +1,194 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
 
 // Resource:
 // https://dev.to/techschoolguru/load-config-from-file-environment-variables-in-golang-with-viper-2j2d
 
-// Config - Hold configuration values from environment variables
+// Config - Hold configuration values, layered from defaults, the app.env file, environment variables and flags
 type Config struct {
-	CSV_FILENAME    string `mapstructure:"CSV_FILENAME"`
-	POKEMON_API_URL string `mapstructure:"POKEMON_API_URL"`
-	// DEFAULT_FILTER_NUM_WORKERS      int    `mapstructure:"DEFAULT_NUM_WORKERS"`
-	// DEFAULT_FILTER_ITEMS            int    `mapstructure:"DEFAULT_FILTER_ITEMS"`
-	// DEFAULT_FILTER_ITEMS_PER_WORKER int    `mapstructure:"DEFAULT_FILTER_ITEMS_PER_WORKER"`
+	SERVER_HOST                     string        `mapstructure:"SERVER_HOST"`                     // Address to listen on. Empty means every interface
+	SERVER_PORT                     int           `mapstructure:"SERVER_PORT"`                     // Port to listen on
+	SERVER_READ_HEADER_TIMEOUT      time.Duration `mapstructure:"SERVER_READ_HEADER_TIMEOUT"`      // Time clients have to send the request headers
+	SERVER_READ_TIMEOUT             time.Duration `mapstructure:"SERVER_READ_TIMEOUT"`             // Time clients have to send the whole request. 0 means no limit
+	SERVER_WRITE_TIMEOUT            time.Duration `mapstructure:"SERVER_WRITE_TIMEOUT"`            // Time to write a response, streams included. 0 means no limit
+	SERVER_IDLE_TIMEOUT             time.Duration `mapstructure:"SERVER_IDLE_TIMEOUT"`             // Time keep-alive connections wait for the next request
+	SERVER_MAX_HEADER_BYTES         int           `mapstructure:"SERVER_MAX_HEADER_BYTES"`         // Largest request headers accepted
+	SERVER_SHUTDOWN_GRACE_PERIOD    time.Duration `mapstructure:"SERVER_SHUTDOWN_GRACE_PERIOD"`    // Time in-flight requests and writes have to finish on shutdown
+	CSV_FILENAME                    string        `mapstructure:"CSV_FILENAME"`                    // Pokemons loaded on start and kept up to date on every change
+	CSV_BACKUP                      bool          `mapstructure:"CSV_BACKUP"`                      // Keep the previous csv contents in a .bak file on every write
+	STORAGE_BACKEND                 string        `mapstructure:"STORAGE_BACKEND"`                 // Where pokemons are kept: memory, sqlite or bolt
+	STORAGE_PATH                    string        `mapstructure:"STORAGE_PATH"`                    // Database file of the sqlite and bolt backends
+	POKEMON_API_URL                 string        `mapstructure:"POKEMON_API_URL"`                 // First page of the pokemon list of the API
+	POKEMON_API_LIMIT               int           `mapstructure:"POKEMON_API_LIMIT"`               // Max pokemons to fetch from the API. 0 means no limit
+	POKEMON_API_WORKERS             int           `mapstructure:"POKEMON_API_WORKERS"`             // Workers fetching pokemon details concurrently
+	POKEMON_API_MAX_IN_FLIGHT       int           `mapstructure:"POKEMON_API_MAX_IN_FLIGHT"`       // Max concurrent detail requests. 0 means one per worker
+	POKEMON_API_TIMEOUT             time.Duration `mapstructure:"POKEMON_API_TIMEOUT"`             // Timeout of every API request. 0 means none
+	POKEMON_API_MAX_RETRIES         int           `mapstructure:"POKEMON_API_MAX_RETRIES"`         // Retries of requests failing with a network error, 5xx or 429
+	POKEMON_API_BACKOFF_BASE        time.Duration `mapstructure:"POKEMON_API_BACKOFF_BASE"`        // Wait before the first retry, doubling on every other one
+	POKEMON_API_BACKOFF_MAX         time.Duration `mapstructure:"POKEMON_API_BACKOFF_MAX"`         // Longest wait between retries
+	POKEMON_API_BREAKER_THRESHOLD   int           `mapstructure:"POKEMON_API_BREAKER_THRESHOLD"`   // Consecutive failures opening the circuit breaker. 0 disables it
+	POKEMON_API_BREAKER_COOLDOWN    time.Duration `mapstructure:"POKEMON_API_BREAKER_COOLDOWN"`    // Time the breaker stays open
+	POKEMON_API_CACHE_DIR           string        `mapstructure:"POKEMON_API_CACHE_DIR"`           // Directory caching API responses. Empty disables the cache
+	REFRESH_SCHEDULE                string        `mapstructure:"REFRESH_SCHEDULE"`                // Interval (i.e. 6h) or cron expression of the scheduled refresh. Empty disables it
+	REFRESH_MODE                    string        `mapstructure:"REFRESH_MODE"`                    // How scheduled refreshes sync: replace, merge or upsert-only
+	WORKER_POOL_CAPACITY            int           `mapstructure:"WORKER_POOL_CAPACITY"`            // Workers all filters and searches run at once. 0 means no limit
+	WORKER_POOL_MAX_PER_REQUEST     int           `mapstructure:"WORKER_POOL_MAX_PER_REQUEST"`     // Most workers a single request may ask for, even without capacity. 0 means the whole capacity
+	WORKER_POOL_QUEUE_SIZE          int           `mapstructure:"WORKER_POOL_QUEUE_SIZE"`          // Requests waiting for workers before answering 429
+	WORKER_POOL_QUEUE_TIMEOUT       time.Duration `mapstructure:"WORKER_POOL_QUEUE_TIMEOUT"`       // Longest wait for workers before answering 503
+	DEFAULT_FILTER_NUM_WORKERS      int           `mapstructure:"DEFAULT_FILTER_NUM_WORKERS"`      // Workers of a filter or search without 'workers' param
+	DEFAULT_FILTER_ITEMS            int           `mapstructure:"DEFAULT_FILTER_ITEMS"`            // Items of a filter or search without 'items' param
+	DEFAULT_FILTER_ITEMS_PER_WORKER int           `mapstructure:"DEFAULT_FILTER_ITEMS_PER_WORKER"` // Items per worker of a filter or search without 'items_per_workers' param
+}
+
+// Value of every setting neither app.env, the environment nor a flag sets
+var defaults = map[string]interface{}{
+	"SERVER_HOST":                     "localhost",
+	"SERVER_PORT":                     8082,
+	"SERVER_READ_HEADER_TIMEOUT":      5 * time.Second,
+	"SERVER_READ_TIMEOUT":             time.Minute,
+	"SERVER_WRITE_TIMEOUT":            time.Duration(0),
+	"SERVER_IDLE_TIMEOUT":             2 * time.Minute,
+	"SERVER_MAX_HEADER_BYTES":         1 << 20,
+	"SERVER_SHUTDOWN_GRACE_PERIOD":    10 * time.Second,
+	"CSV_FILENAME":                    "pokemons.csv",
+	"CSV_BACKUP":                      false,
+	"STORAGE_BACKEND":                 "memory",
+	"STORAGE_PATH":                    "pokemons.db",
+	"POKEMON_API_URL":                 "https://pokeapi.co/api/v2/pokemon/",
+	"POKEMON_API_LIMIT":               0,
+	"POKEMON_API_WORKERS":             4,
+	"POKEMON_API_MAX_IN_FLIGHT":       0,
+	"POKEMON_API_TIMEOUT":             10 * time.Second,
+	"POKEMON_API_MAX_RETRIES":         3,
+	"POKEMON_API_BACKOFF_BASE":        200 * time.Millisecond,
+	"POKEMON_API_BACKOFF_MAX":         5 * time.Second,
+	"POKEMON_API_BREAKER_THRESHOLD":   5,
+	"POKEMON_API_BREAKER_COOLDOWN":    30 * time.Second,
+	"POKEMON_API_CACHE_DIR":           "",
+	"REFRESH_SCHEDULE":                "",
+	"REFRESH_MODE":                    "merge",
+	"WORKER_POOL_CAPACITY":            64,
+	"WORKER_POOL_MAX_PER_REQUEST":     8,
+	"WORKER_POOL_QUEUE_SIZE":          100,
+	"WORKER_POOL_QUEUE_TIMEOUT":       2 * time.Second,
+	"DEFAULT_FILTER_NUM_WORKERS":      2,
+	"DEFAULT_FILTER_ITEMS":            5,
+	"DEFAULT_FILTER_ITEMS_PER_WORKER": 10,
 }
 
 // New - Config factory method
-func New(path string) (*Config, error) {
-	viper.AddConfigPath(path)
-	viper.SetConfigName("app")
-	viper.SetConfigType("env")
+// Every setting comes from the first of: a flag in args (i.e. --server-port=9000), an environment variable
+// (SERVER_PORT=9000), the app.env file in path, or its default. A missing app.env is fine, an invalid value is not
+func New(path string, args []string) (*Config, error) {
+	v := viper.New()
+	v.AddConfigPath(path)
+	v.SetConfigName("app")
+	v.SetConfigType("env")
 
-	viper.AutomaticEnv()
+	v.AutomaticEnv()
 
-	err := viper.ReadInConfig()
-	if err != nil {
+	flags := pflag.NewFlagSet("go-bootcamp", pflag.ContinueOnError)
+	for _, key := range keys() {
+		v.SetDefault(key, defaults[key])
+		if err := addFlag(flags, key); err != nil {
+			return nil, err
+		}
+		v.BindPFlag(key, flags.Lookup(flagName(key)))
+	}
+	if err := flags.Parse(args); err != nil {
 		return nil, err
 	}
 
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, err
+		}
+	}
+
 	var config Config
-	err = viper.Unmarshal(&config)
-	if err != nil {
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
 	return &config, nil
 }
+
+// Setting names, in Config order
+func keys() []string {
+	t := reflect.TypeOf(Config{})
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i] = t.Field(i).Tag.Get("mapstructure")
+	}
+	return names
+}
+
+// Flags are the setting names in kebab case, i.e. --server-port for SERVER_PORT
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// Adds the flag of a setting, typed after its default
+func addFlag(flags *pflag.FlagSet, key string) error {
+	usage := "Overrides " + key
+	switch value := defaults[key].(type) {
+	case string:
+		flags.String(flagName(key), value, usage)
+	case int:
+		flags.Int(flagName(key), value, usage)
+	case bool:
+		flags.Bool(flagName(key), value, usage)
+	case time.Duration:
+		flags.Duration(flagName(key), value, usage)
+	default:
+		return fmt.Errorf("setting %s has no default", key)
+	}
+	return nil
+}
+
+// Settings applied without a restart when app.env changes
+var reloadable = map[string]bool{
+	"REFRESH_SCHEDULE":                true,
+	"REFRESH_MODE":                    true,
+	"DEFAULT_FILTER_NUM_WORKERS":      true,
+	"DEFAULT_FILTER_ITEMS":            true,
+	"DEFAULT_FILTER_ITEMS_PER_WORKER": true,
+}
+
+// Reload - Takes the settings of next that apply without a restart, as long as the outcome is still valid
+// Returns the settings taken, and those changed that need a restart. On error c is left untouched
+func (c *Config) Reload(next *Config) (applied []string, restart []string, err error) {
+	merged := *c
+	current, target := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(next).Elem()
+	for i, key := range keys() {
+		if reflect.DeepEqual(current.Field(i).Interface(), target.Field(i).Interface()) {
+			continue
+		}
+		if !reloadable[key] {
+			restart = append(restart, key)
+			continue
+		}
+		current.Field(i).Set(target.Field(i))
+		applied = append(applied, key)
+	}
+
+	if err := merged.Validate(); err != nil {
+		return nil, nil, err
+	}
+	*c = merged
+	return applied, restart, nil
+}
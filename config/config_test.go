@@ -0,0 +1,330 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNew - Test every layer overrides the ones below: defaults, app.env, env vars and flags
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name     string
+		appEnv   string
+		env      map[string]string
+		args     []string
+		expected func(*Config)
+		error    string
+	}{
+		{
+			name: "defaults without app.env",
+		},
+		{
+			name:   "app.env over defaults",
+			appEnv: "SERVER_PORT=9000\nDEFAULT_FILTER_ITEMS=20\nSERVER_IDLE_TIMEOUT=30s\n",
+			expected: func(c *Config) {
+				c.SERVER_PORT = 9000
+				c.DEFAULT_FILTER_ITEMS = 20
+				c.SERVER_IDLE_TIMEOUT = 30 * time.Second
+			},
+		},
+		{
+			name:   "env vars over app.env",
+			appEnv: "SERVER_PORT=9000\nCSV_BACKUP=false\n",
+			env:    map[string]string{"SERVER_PORT": "9001", "CSV_BACKUP": "true"},
+			expected: func(c *Config) {
+				c.SERVER_PORT = 9001
+				c.CSV_BACKUP = true
+			},
+		},
+		{
+			name:   "flags over env vars",
+			appEnv: "SERVER_PORT=9000\n",
+			env:    map[string]string{"SERVER_PORT": "9001", "SERVER_HOST": "0.0.0.0"},
+			args:   []string{"--server-port=9002", "--default-filter-num-workers", "4", "--server-write-timeout=1m"},
+			expected: func(c *Config) {
+				c.SERVER_PORT = 9002
+				c.SERVER_HOST = "0.0.0.0"
+				c.DEFAULT_FILTER_NUM_WORKERS = 4
+				c.SERVER_WRITE_TIMEOUT = time.Minute
+			},
+		},
+		{
+			name:  "unknown flag",
+			args:  []string{"--port=9000"},
+			error: "unknown flag: --port",
+		},
+		{
+			name:  "malformed value",
+			args:  []string{"--server-port=http"},
+			error: `invalid argument "http" for "--server-port" flag`,
+		},
+		{
+			name:   "invalid value",
+			appEnv: "SERVER_PORT=70000\n",
+			error:  "SERVER_PORT: 70000 is not a valid port. Must be between 1 and 65535",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.appEnv != "" {
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.env"), []byte(tc.appEnv), 0644))
+			}
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			config, err := New(dir, tc.args)
+
+			if tc.error != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.error)
+				return
+			}
+			assert.NoError(t, err)
+			expected := validConfig()
+			if tc.expected != nil {
+				tc.expected(&expected)
+			}
+			assert.Equal(t, expected, *config)
+		})
+	}
+}
+
+// TestConfig_Validate - Test invalid settings are all reported
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		change   func(*Config)
+		expected []string
+	}{
+		{
+			name:   "valid",
+			change: func(*Config) {},
+		},
+		{
+			name: "server",
+			change: func(c *Config) {
+				c.SERVER_PORT = 0
+				c.SERVER_READ_TIMEOUT = -time.Second
+				c.SERVER_MAX_HEADER_BYTES = 0
+				c.SERVER_SHUTDOWN_GRACE_PERIOD = 0
+			},
+			expected: []string{
+				"SERVER_PORT: 0 is not a valid port. Must be between 1 and 65535",
+				"SERVER_MAX_HEADER_BYTES: must be positive, got 0",
+				"SERVER_SHUTDOWN_GRACE_PERIOD: must be positive, got 0s",
+				"SERVER_READ_TIMEOUT: must not be negative, got -1s",
+			},
+		},
+		{
+			name: "storage",
+			change: func(c *Config) {
+				c.STORAGE_BACKEND = "sqlite"
+				c.STORAGE_PATH = ""
+			},
+			expected: []string{"STORAGE_PATH: is required by the sqlite backend"},
+		},
+		{
+			name:     "unknown storage",
+			change:   func(c *Config) { c.STORAGE_BACKEND = "disk" },
+			expected: []string{"STORAGE_BACKEND: disk is not a valid input. Must be either 'memory', 'sqlite' or 'bolt'"},
+		},
+		{
+			name: "pokemon api",
+			change: func(c *Config) {
+				c.POKEMON_API_URL = "pokeapi.co/api/v2/pokemon/"
+				c.POKEMON_API_WORKERS = 0
+				c.POKEMON_API_BACKOFF_BASE = 10 * time.Second
+				c.POKEMON_API_BREAKER_COOLDOWN = 0
+			},
+			expected: []string{
+				`POKEMON_API_URL: "pokeapi.co/api/v2/pokemon/" is not a valid URL. Must be an absolute http or https URL`,
+				"POKEMON_API_WORKERS: must be positive, got 0",
+				"POKEMON_API_BACKOFF_BASE: 10s is longer than POKEMON_API_BACKOFF_MAX (5s)",
+				"POKEMON_API_BREAKER_COOLDOWN: must be positive while the breaker is enabled, got 0s",
+			},
+		},
+		{
+			name: "breaker disabled",
+			change: func(c *Config) {
+				c.POKEMON_API_BREAKER_THRESHOLD = 0
+				c.POKEMON_API_BREAKER_COOLDOWN = 0
+			},
+		},
+		{
+			name: "refresh",
+			change: func(c *Config) {
+				c.REFRESH_SCHEDULE = "often"
+				c.REFRESH_MODE = "sync"
+			},
+			expected: []string{
+				"REFRESH_SCHEDULE: often is not a valid schedule",
+				"REFRESH_MODE: sync is not a valid input",
+			},
+		},
+		{
+			name: "filter defaults",
+			change: func(c *Config) {
+				c.DEFAULT_FILTER_NUM_WORKERS = 16
+				c.DEFAULT_FILTER_ITEMS = 0
+				c.DEFAULT_FILTER_ITEMS_PER_WORKER = -1
+			},
+			expected: []string{
+				"DEFAULT_FILTER_NUM_WORKERS: 16 is more than a single request may ask for (8)",
+				"DEFAULT_FILTER_ITEMS: must be positive, got 0",
+				"DEFAULT_FILTER_ITEMS_PER_WORKER: must be positive, got -1",
+			},
+		},
+		{
+			name: "filter workers without budget",
+			change: func(c *Config) {
+				c.WORKER_POOL_CAPACITY = 0
+				c.DEFAULT_FILTER_NUM_WORKERS = 16
+			},
+			expected: []string{
+				"DEFAULT_FILTER_NUM_WORKERS: 16 is more than a single request may ask for (8)",
+			},
+		},
+		{
+			name: "filter workers without any limit",
+			change: func(c *Config) {
+				c.WORKER_POOL_CAPACITY = 0
+				c.WORKER_POOL_MAX_PER_REQUEST = 0
+				c.DEFAULT_FILTER_NUM_WORKERS = 300
+			},
+			expected: []string{
+				"DEFAULT_FILTER_NUM_WORKERS: 300 is more than a single request may ask for (256)",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := validConfig()
+			tc.change(&config)
+
+			err := config.Validate()
+
+			if len(tc.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Len(t, strings.Split(err.Error(), "\n"), len(tc.expected)+1)
+			for _, problem := range tc.expected {
+				assert.Contains(t, err.Error(), problem)
+			}
+		})
+	}
+}
+
+// The configuration made of the defaults
+func validConfig() Config {
+	return Config{
+		SERVER_HOST:                     "localhost",
+		SERVER_PORT:                     8082,
+		SERVER_READ_HEADER_TIMEOUT:      5 * time.Second,
+		SERVER_READ_TIMEOUT:             time.Minute,
+		SERVER_IDLE_TIMEOUT:             2 * time.Minute,
+		SERVER_MAX_HEADER_BYTES:         1 << 20,
+		SERVER_SHUTDOWN_GRACE_PERIOD:    10 * time.Second,
+		CSV_FILENAME:                    "pokemons.csv",
+		STORAGE_BACKEND:                 "memory",
+		STORAGE_PATH:                    "pokemons.db",
+		POKEMON_API_URL:                 "https://pokeapi.co/api/v2/pokemon/",
+		POKEMON_API_WORKERS:             4,
+		POKEMON_API_TIMEOUT:             10 * time.Second,
+		POKEMON_API_MAX_RETRIES:         3,
+		POKEMON_API_BACKOFF_BASE:        200 * time.Millisecond,
+		POKEMON_API_BACKOFF_MAX:         5 * time.Second,
+		POKEMON_API_BREAKER_THRESHOLD:   5,
+		POKEMON_API_BREAKER_COOLDOWN:    30 * time.Second,
+		REFRESH_MODE:                    "merge",
+		WORKER_POOL_CAPACITY:            64,
+		WORKER_POOL_MAX_PER_REQUEST:     8,
+		WORKER_POOL_QUEUE_SIZE:          100,
+		WORKER_POOL_QUEUE_TIMEOUT:       2 * time.Second,
+		DEFAULT_FILTER_NUM_WORKERS:      2,
+		DEFAULT_FILTER_ITEMS:            5,
+		DEFAULT_FILTER_ITEMS_PER_WORKER: 10,
+	}
+}
+
+// TestConfig_Reload - Test only the settings safe to change while serving are taken, and only if valid
+func TestConfig_Reload(t *testing.T) {
+	testCases := []struct {
+		name            string
+		change          func(*Config)
+		expected        func(*Config)
+		expectedApplied []string
+		expectedRestart []string
+		error           string
+	}{
+		{
+			name:   "nothing changed",
+			change: func(*Config) {},
+		},
+		{
+			name: "safe changes",
+			change: func(c *Config) {
+				c.DEFAULT_FILTER_ITEMS = 20
+				c.REFRESH_SCHEDULE = "1h"
+			},
+			expected: func(c *Config) {
+				c.DEFAULT_FILTER_ITEMS = 20
+				c.REFRESH_SCHEDULE = "1h"
+			},
+			expectedApplied: []string{"REFRESH_SCHEDULE", "DEFAULT_FILTER_ITEMS"},
+		},
+		{
+			name: "changes needing a restart",
+			change: func(c *Config) {
+				c.SERVER_PORT = 9000
+				c.DEFAULT_FILTER_ITEMS = 20
+			},
+			expected: func(c *Config) {
+				c.DEFAULT_FILTER_ITEMS = 20
+			},
+			expectedApplied: []string{"DEFAULT_FILTER_ITEMS"},
+			expectedRestart: []string{"SERVER_PORT"},
+		},
+		{
+			name: "invalid along with the running settings",
+			change: func(c *Config) {
+				c.WORKER_POOL_MAX_PER_REQUEST = 16
+				c.DEFAULT_FILTER_NUM_WORKERS = 12
+			},
+			error: "DEFAULT_FILTER_NUM_WORKERS: 12 is more than a single request may ask for (8)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, next := validConfig(), validConfig()
+			tc.change(&next)
+
+			applied, restart, err := config.Reload(&next)
+
+			expected := validConfig()
+			if tc.expected != nil {
+				tc.expected(&expected)
+			}
+			if tc.error != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.error)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.expectedApplied, applied)
+			assert.Equal(t, tc.expectedRestart, restart)
+			assert.Equal(t, expected, config)
+		})
+	}
+}
@@ -19,7 +19,8 @@ func NewPoolConfig(oe enum.OddEven, numWorkers int, items int, ipw int) GoRoutin
 	// Create a channel so the workers inject elements as they find them
 	ch := make(chan interface{}, 50)
 	// Create a channel to indicate a worker is done
-	doneCh := make(chan struct{}, 10)
+	// One slot per worker, so no worker blocks signaling once the monitor stopped listening
+	doneCh := make(chan struct{}, numWorkers)
 
 	return GoRoutinePoolConfig{oe, numWorkers, items, ipw, ch, doneCh}
 }
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"rincon-orlando/go-bootcamp/scheduler"
+	"rincon-orlando/go-bootcamp/util/enum"
+)
+
+// Validate - Checks every setting, so a bad one stops the app at startup instead of failing on some request
+// All the problems found are reported at once, one per line
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, key string, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.SERVER_PORT > 0 && c.SERVER_PORT <= 65535, "SERVER_PORT", "%d is not a valid port. Must be between 1 and 65535", c.SERVER_PORT)
+	check(c.SERVER_MAX_HEADER_BYTES > 0, "SERVER_MAX_HEADER_BYTES", "must be positive, got %d", c.SERVER_MAX_HEADER_BYTES)
+	check(c.SERVER_SHUTDOWN_GRACE_PERIOD > 0, "SERVER_SHUTDOWN_GRACE_PERIOD", "must be positive, got %s", c.SERVER_SHUTDOWN_GRACE_PERIOD)
+
+	if backend, err := enum.ParseBackend(c.STORAGE_BACKEND); err != nil {
+		check(false, "STORAGE_BACKEND", "%v", err)
+	} else {
+		check(backend == enum.MemoryBackend || c.STORAGE_PATH != "", "STORAGE_PATH", "is required by the %s backend", c.STORAGE_BACKEND)
+	}
+
+	apiURL, err := url.Parse(c.POKEMON_API_URL)
+	check(err == nil && (apiURL.Scheme == "http" || apiURL.Scheme == "https") && apiURL.Host != "",
+		"POKEMON_API_URL", "%q is not a valid URL. Must be an absolute http or https URL", c.POKEMON_API_URL)
+	check(c.POKEMON_API_LIMIT >= 0, "POKEMON_API_LIMIT", "must not be negative, got %d", c.POKEMON_API_LIMIT)
+	check(c.POKEMON_API_WORKERS > 0, "POKEMON_API_WORKERS", "must be positive, got %d", c.POKEMON_API_WORKERS)
+	check(c.POKEMON_API_MAX_IN_FLIGHT >= 0, "POKEMON_API_MAX_IN_FLIGHT", "must not be negative, got %d", c.POKEMON_API_MAX_IN_FLIGHT)
+	check(c.POKEMON_API_MAX_RETRIES >= 0, "POKEMON_API_MAX_RETRIES", "must not be negative, got %d", c.POKEMON_API_MAX_RETRIES)
+	check(c.POKEMON_API_BACKOFF_BASE <= c.POKEMON_API_BACKOFF_MAX, "POKEMON_API_BACKOFF_BASE",
+		"%s is longer than POKEMON_API_BACKOFF_MAX (%s)", c.POKEMON_API_BACKOFF_BASE, c.POKEMON_API_BACKOFF_MAX)
+	check(c.POKEMON_API_BREAKER_THRESHOLD >= 0, "POKEMON_API_BREAKER_THRESHOLD", "must not be negative, got %d", c.POKEMON_API_BREAKER_THRESHOLD)
+	check(c.POKEMON_API_BREAKER_THRESHOLD == 0 || c.POKEMON_API_BREAKER_COOLDOWN > 0, "POKEMON_API_BREAKER_COOLDOWN",
+		"must be positive while the breaker is enabled, got %s", c.POKEMON_API_BREAKER_COOLDOWN)
+
+	if c.REFRESH_SCHEDULE != "" {
+		_, err := scheduler.Parse(c.REFRESH_SCHEDULE)
+		check(err == nil, "REFRESH_SCHEDULE", "%v", err)
+	}
+	_, err = enum.ParseSyncMode(c.REFRESH_MODE)
+	check(err == nil, "REFRESH_MODE", "%v", err)
+
+	check(c.WORKER_POOL_CAPACITY >= 0, "WORKER_POOL_CAPACITY", "must not be negative, got %d", c.WORKER_POOL_CAPACITY)
+	check(c.WORKER_POOL_MAX_PER_REQUEST >= 0, "WORKER_POOL_MAX_PER_REQUEST", "must not be negative, got %d", c.WORKER_POOL_MAX_PER_REQUEST)
+	check(c.WORKER_POOL_QUEUE_SIZE >= 0, "WORKER_POOL_QUEUE_SIZE", "must not be negative, got %d", c.WORKER_POOL_QUEUE_SIZE)
+
+	check(c.DEFAULT_FILTER_NUM_WORKERS > 0, "DEFAULT_FILTER_NUM_WORKERS", "must be positive, got %d", c.DEFAULT_FILTER_NUM_WORKERS)
+	max := c.MaxWorkersPerRequest()
+	check(c.DEFAULT_FILTER_NUM_WORKERS <= max, "DEFAULT_FILTER_NUM_WORKERS",
+		"%d is more than a single request may ask for (%d)", c.DEFAULT_FILTER_NUM_WORKERS, max)
+	check(c.DEFAULT_FILTER_ITEMS > 0, "DEFAULT_FILTER_ITEMS", "must be positive, got %d", c.DEFAULT_FILTER_ITEMS)
+	check(c.DEFAULT_FILTER_ITEMS_PER_WORKER > 0, "DEFAULT_FILTER_ITEMS_PER_WORKER", "must be positive, got %d", c.DEFAULT_FILTER_ITEMS_PER_WORKER)
+
+	// No timeout may be negative, 0 means no limit for all of them
+	durations := map[string]time.Duration{
+		"SERVER_READ_HEADER_TIMEOUT": c.SERVER_READ_HEADER_TIMEOUT,
+		"SERVER_READ_TIMEOUT":        c.SERVER_READ_TIMEOUT,
+		"SERVER_WRITE_TIMEOUT":       c.SERVER_WRITE_TIMEOUT,
+		"SERVER_IDLE_TIMEOUT":        c.SERVER_IDLE_TIMEOUT,
+		"POKEMON_API_TIMEOUT":        c.POKEMON_API_TIMEOUT,
+		"POKEMON_API_BACKOFF_BASE":   c.POKEMON_API_BACKOFF_BASE,
+		"POKEMON_API_BACKOFF_MAX":    c.POKEMON_API_BACKOFF_MAX,
+		"WORKER_POOL_QUEUE_TIMEOUT":  c.WORKER_POOL_QUEUE_TIMEOUT,
+	}
+	for _, key := range keys() {
+		if d, ok := durations[key]; ok {
+			check(d >= 0, key, "must not be negative, got %s", d)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Most workers a single filter or search may ask for when nothing else limits them
+// Every worker is a goroutine, so no request gets to start them without bounds
+const unboundedMaxWorkersPerRequest = 256
+
+// MaxWorkersPerRequest - Most workers a single filter or search may ask for, always positive
+// Mirrors workerpool.NewBudget, which caps the per request limit to the capacity
+func (c *Config) MaxWorkersPerRequest() int {
+	max := c.WORKER_POOL_MAX_PER_REQUEST
+	if c.WORKER_POOL_CAPACITY > 0 && (max <= 0 || max > c.WORKER_POOL_CAPACITY) {
+		max = c.WORKER_POOL_CAPACITY
+	}
+	if max <= 0 {
+		return unboundedMaxWorkersPerRequest
+	}
+	return max
+}
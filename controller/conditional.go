@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Validators of the representation a GET asks for, telling clients whether the copy they have is still good
+type validators struct {
+	etag         string    // Empty when changes are not tracked, or the format is wrong
+	lastModified time.Time // Zero when changes are not tracked
+}
+
+// Builds the validators of the representation a GET asks for out of the version of the pokemons
+// The ETag covers the version along with everything shaping the response: path, query params and negotiated format.
+// So it never needs the pokemons, let alone their encoding, and differs for every format and every page
+func newValidators(ctx *gin.Context, version uint64, lastModified time.Time) validators {
+	v := validators{lastModified: lastModified}
+	format, ok := responseFormat(ctx)
+	if version == 0 || !ok {
+		return v
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{strconv.FormatUint(version, 10), ctx.Request.URL.Path, ctx.Request.URL.Query().Encode(), format} {
+		hash.Write([]byte(part + "\n"))
+	}
+	v.etag = `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	return v
+}
+
+// Answers 304 Not Modified right away when If-None-Match lists the ETag, before the pokemons are even read
+// Such a tag was handed out along a successful response to this same request, so a match means nothing changed.
+// Wildcards and dates only tell once the request is known to succeed, so renderConditional checks them.
+// Returns true when the response is done
+func (v validators) answerEarly(ctx *gin.Context) bool {
+	if v.etag == "" || !matchesETag(ctx.Request, v.etag, false) {
+		return false
+	}
+
+	v.setHeaders(ctx)
+	ctx.Status(http.StatusNotModified)
+	return true
+}
+
+// Writes obj like render does, answering 304 Not Modified when the client already has it
+// Clients are asked to revalidate every time, which is cheap since unchanged data is never resent
+func renderConditional(ctx *gin.Context, v validators, obj interface{}) {
+	contentType, data, ok := encode(ctx, obj)
+	if !ok {
+		return
+	}
+
+	v.setHeaders(ctx)
+	if notModified(ctx.Request, v.etag, v.lastModified) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// Sets the validators, along with the headers asking clients to revalidate
+func (v validators) setHeaders(ctx *gin.Context) {
+	if v.etag != "" {
+		ctx.Header("ETag", v.etag)
+	}
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Vary", "Accept")
+	if !v.lastModified.IsZero() {
+		ctx.Header("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Evaluates If-None-Match, or If-Modified-Since when the former is missing, as RFC 7232 says
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if req.Header.Get("If-None-Match") != "" {
+		return matchesETag(req, etag, true)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.After(since)
+}
+
+// Tells whether If-None-Match lists etag, or '*' when wildcard is set
+func matchesETag(req *http.Request, etag string, wildcard bool) bool {
+	for _, candidate := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" && wildcard {
+			return true
+		}
+		// GET uses the weak comparison, so a weak version of our tag matches too
+		if etag != "" && strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
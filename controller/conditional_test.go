@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sends a GET through both conditional endpoints, returning the recorded response
+func conditionalGet(muc *mockUseCase, url string, headers ...string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	_, r := gin.CreateTestContext(w)
+
+	ctl := New(muc, testDefaults)
+	r.GET("/pokemons", ctl.GetAllPokemons)
+	r.GET("/pokemons/:id", ctl.GetPokemonById)
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestController_Conditional - Validates ETag and Last-Modified based conditional GETs
+func TestController_Conditional(t *testing.T) {
+	lastModified := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	newMock := func(total int) *mockUseCase {
+		muc := &mockUseCase{lastModified: lastModified, version: 7}
+		muc.On("ListPokemons").Return(pokemons, total, nil)
+		muc.On("GetPokemonById").Return(&pokemons[0], nil)
+		return muc
+	}
+
+	for _, url := range []string{"/pokemons", "/pokemons/1"} {
+		t.Run(url, func(t *testing.T) {
+			first := conditionalGet(newMock(len(pokemons)), url)
+			assert.Equal(t, http.StatusOK, first.Code)
+			etag := first.Header().Get("ETag")
+			assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+			assert.Equal(t, "Fri, 01 Oct 2021 12:00:00 GMT", first.Header().Get("Last-Modified"))
+			assert.Equal(t, "no-cache", first.Header().Get("Cache-Control"))
+			assert.Equal(t, "Accept", first.Header().Get("Vary"))
+
+			testCases := []struct {
+				name         string
+				url          string
+				headers      []string
+				expectedCode int
+				expectedRead bool // Whether the pokemons had to be read to answer
+			}{
+				{"matching etag", url, []string{"If-None-Match", etag}, http.StatusNotModified, false},
+				{"weak etag", url, []string{"If-None-Match", "W/" + etag}, http.StatusNotModified, false},
+				{"etag list", url, []string{"If-None-Match", `"other", ` + etag}, http.StatusNotModified, false},
+				{"any etag", url, []string{"If-None-Match", "*"}, http.StatusNotModified, true},
+				{"other etag", url, []string{"If-None-Match", `"other"`}, http.StatusOK, true},
+				{"other format", url + "?format=csv", []string{"If-None-Match", etag}, http.StatusOK, true},
+				{"other params", url + "?fields=id", []string{"If-None-Match", etag}, http.StatusOK, true},
+				{"not modified since", url, []string{"If-Modified-Since", "Fri, 01 Oct 2021 12:00:00 GMT"}, http.StatusNotModified, true},
+				{"modified since", url, []string{"If-Modified-Since", "Fri, 01 Oct 2021 11:59:59 GMT"}, http.StatusOK, true},
+				{"invalid date", url, []string{"If-Modified-Since", "yesterday"}, http.StatusOK, true},
+				{"etag wins over date", url, []string{"If-None-Match", `"other"`, "If-Modified-Since", "Fri, 01 Oct 2021 12:00:00 GMT"}, http.StatusOK, true},
+			}
+
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					muc := newMock(len(pokemons))
+					w := conditionalGet(muc, tc.url, tc.headers...)
+
+					assert.Equal(t, tc.expectedCode, w.Code)
+					if tc.expectedCode == http.StatusNotModified {
+						assert.Empty(t, w.Body.String())
+						assert.Equal(t, etag, w.Header().Get("ETag"))
+					} else {
+						assert.NotEmpty(t, w.Body.String())
+					}
+					assert.Equal(t, tc.expectedRead, len(muc.Calls) > 0)
+				})
+			}
+
+			// Any change to the pokemons changes the ETag
+			muc := newMock(len(pokemons))
+			muc.version++
+			w := conditionalGet(muc, url, "If-None-Match", etag)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+// TestController_ConditionalHeaders - Validates the ETag follows the page and format asked for, and untracked changes are never assumed
+func TestController_ConditionalHeaders(t *testing.T) {
+	newMock := func(version uint64) *mockUseCase {
+		muc := &mockUseCase{version: version}
+		muc.On("ListPokemons").Return(pokemons[:1], 3, nil)
+		return muc
+	}
+
+	w := conditionalGet(newMock(1), "/pokemons?limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Same params in another order are the same representation, another page or format is not
+	w = conditionalGet(newMock(1), "/pokemons?sort=id&limit=1")
+	other := w.Header().Get("ETag")
+	assert.Equal(t, other, conditionalGet(newMock(1), "/pokemons?limit=1&sort=id").Header().Get("ETag"))
+	for _, url := range []string{"/pokemons?limit=1&offset=1", "/pokemons?limit=1&format=yaml"} {
+		assert.NotContains(t, []string{etag, other}, conditionalGet(newMock(1), url).Header().Get("ETag"))
+	}
+	assert.NotEqual(t, etag, conditionalGet(newMock(1), "/pokemons?limit=1", "Accept", "text/csv").Header().Get("ETag"))
+
+	// Without tracking there is no ETag, nor a last change time to compare dates against
+	w = conditionalGet(newMock(0), "/pokemons?limit=1", "If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+}
+
+// TestController_ConditionalErrors - Validates errors are never answered with 304
+func TestController_ConditionalErrors(t *testing.T) {
+	muc := &mockUseCase{lastModified: time.Now(), version: 1}
+	muc.On("GetPokemonById").Return((*model.Pokemon)(nil), model.Errorf(model.ErrNotFound, "pokemon with 1 not found"))
+
+	w := conditionalGet(muc, "/pokemons/1", "If-None-Match", "*")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
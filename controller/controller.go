@@ -1,69 +1,276 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"rincon-orlando/go-bootcamp/model"
 	"rincon-orlando/go-bootcamp/util/enum"
+	"rincon-orlando/go-bootcamp/util/filter"
+	"rincon-orlando/go-bootcamp/workerpool"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type usecase interface {
-	GetAllPokemons() []model.Pokemon
+	GetAllPokemons() ([]model.Pokemon, error)
+	ListPokemons(sortBy string, offset int, limit int) ([]model.Pokemon, int, error)
 	GetPokemonById(id int) (*model.Pokemon, error)
-	SetPokemons(pokemons []model.Pokemon)
-	FetchPokemonsFromApi() ([]model.Pokemon, error)
-	FilterPokemonsConcurrently(enum.OddEven, int, int, int) []model.Pokemon
+	LastModified() time.Time
+	Version() uint64
+	CreatePokemon(pokemon model.Pokemon) (*model.Pokemon, error)
+	UpdatePokemon(pokemon model.Pokemon) error
+	PatchPokemon(id int, patch func(pokemon *model.Pokemon) error) (*model.Pokemon, error)
+	DeletePokemon(id int) error
+	ImportPokemons(r io.Reader, mode enum.ImportMode) (model.ImportReport, error)
+	ExportPokemons(w io.Writer) error
+	RefreshPokemons(ctx context.Context, mode enum.SyncMode, dryRun bool) (model.SyncReport, error)
+	RefreshStatus() model.RefreshStatus
+	UpstreamStatus() model.UpstreamStatus
+	FilterPokemonsConcurrently(context.Context, enum.Source, enum.OddEven, int, int, int) ([]model.Pokemon, error)
+	SearchPokemonsConcurrently(context.Context, enum.Source, filter.Predicate, int, int, int) ([]model.Pokemon, error)
+	StreamFilterPokemons(context.Context, enum.Source, enum.OddEven, int, int, int, func(model.Pokemon)) (workerpool.StopReason, error)
+	StreamSearchPokemons(context.Context, enum.Source, filter.Predicate, int, int, int, func(model.Pokemon)) (workerpool.StopReason, error)
+}
+
+// PoolDefaults - Values of the worker pool params requests leave out
+type PoolDefaults struct {
+	Workers        int // 'workers' param
+	MaxWorkers     int // Most workers the 'workers' param may ask for. 0 means no limit
+	Items          int // 'items' param
+	ItemsPerWorker int // 'items_per_workers' param
 }
 
 // Controller - Handler to communicate between endpoints and the usecase
 type Controller struct {
-	uc usecase
+	uc       usecase
+	defaults *atomic.Value // PoolDefaults, shared by every copy of the Controller so they can change while serving
 }
 
 // New - Controller Factory
-func New(uc usecase) Controller {
-	return Controller{uc}
+func New(uc usecase, defaults PoolDefaults) Controller {
+	c := Controller{uc, &atomic.Value{}}
+	c.SetPoolDefaults(defaults)
+	return c
+}
+
+// SetPoolDefaults - Replaces the values of the worker pool params requests leave out, from the next request on
+func (c Controller) SetPoolDefaults(defaults PoolDefaults) {
+	c.defaults.Store(defaults)
 }
 
 // GetAllPokemons - handler that returns all pokemons in the underlying repository
+// Supports 'limit' plus 'offset' or 'cursor' paging, 'sort' (i.e. id, -name) ordering and 'fields' projection
+// Answers conditional requests with 304 Not Modified when nothing changed
 func (c Controller) GetAllPokemons(ctx *gin.Context) {
-	data := c.uc.GetAllPokemons()
-	ctx.IndentedJSON(http.StatusOK, data)
+	p, ok := parsePage(ctx)
+	if !ok {
+		return
+	}
+
+	// Read before the pokemons, so a change in between can only make the validators older, never newer
+	v := newValidators(ctx, c.uc.Version(), c.uc.LastModified())
+	if v.answerEarly(ctx) {
+		return
+	}
+
+	data, total, err := c.uc.ListPokemons(ctx.Query("sort"), p.offset, p.limit)
+	if err != nil {
+		// Only validation errors come from the sort param, storage ones have nothing to do with it
+		if errors.Is(err, model.ErrValidation) {
+			err = fmt.Errorf("'sort' param error. %w", err)
+		}
+		renderError(ctx, err)
+		return
+	}
+
+	response, ok := projectFields(ctx, data)
+	if !ok {
+		return
+	}
+
+	setPageHeaders(ctx, p, total)
+	renderConditional(ctx, v, response)
 }
 
 // GetPokemonById - handler that returns a particular pokemon if it is present in the underlying repository
+// Answers conditional requests with 304 Not Modified when nothing changed
 func (c Controller) GetPokemonById(ctx *gin.Context) {
-	id := ctx.Param("id")
-	idInt, err := strconv.Atoi(id)
-	if err != nil {
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to convert id " + id + " to int"})
+	idInt, ok := parseIdParam(ctx)
+	if !ok {
 		return
 	}
 
+	v := newValidators(ctx, c.uc.Version(), c.uc.LastModified())
+	if v.answerEarly(ctx) {
+		return
+	}
 	pokemon, err := c.uc.GetPokemonById(idInt)
 	if err != nil {
-		ctx.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		renderError(ctx, err)
+		return
+	}
+
+	renderConditional(ctx, v, pokemon)
+}
+
+// CreatePokemon - handler that adds a new pokemon out of the JSON body. A missing id means the next free one
+func (c Controller) CreatePokemon(ctx *gin.Context) {
+	var pokemon model.Pokemon
+	if err := ctx.ShouldBindJSON(&pokemon); err != nil {
+		badParam(ctx, "Failed to parse pokemon. %s", err)
+		return
+	}
+
+	created, err := c.uc.CreatePokemon(pokemon)
+	if err != nil {
+		renderError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", "/pokemons/"+strconv.Itoa(created.ID))
+	render(ctx, http.StatusCreated, created)
+}
+
+// UpdatePokemon - handler that replaces a whole pokemon with the JSON body
+func (c Controller) UpdatePokemon(ctx *gin.Context) {
+	idInt, ok := parseIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	var pokemon model.Pokemon
+	if err := ctx.ShouldBindJSON(&pokemon); err != nil {
+		badParam(ctx, "Failed to parse pokemon. %s", err)
+		return
+	}
+
+	c.update(ctx, idInt, pokemon)
+}
+
+// PatchPokemon - handler that updates only the pokemon fields present in the JSON body (JSON merge patch)
+func (c Controller) PatchPokemon(ctx *gin.Context) {
+	idInt, ok := parseIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	body, err := ctx.GetRawData()
+	if err != nil {
+		badParam(ctx, "Failed to read pokemon. %s", err)
 		return
 	}
 
-	ctx.IndentedJSON(http.StatusOK, pokemon)
+	// The usecase hands over the current pokemon under its write lock, so no concurrent change gets lost
+	pokemon, err := c.uc.PatchPokemon(idInt, func(pokemon *model.Pokemon) error {
+		// Decoding over the current pokemon only overwrites the fields present in the body
+		if err := binding.JSON.BindBody(body, pokemon); err != nil {
+			return model.Errorf(model.ErrValidation, "Failed to parse pokemon. %s", err)
+		}
+		if pokemon.ID == 0 {
+			pokemon.ID = idInt
+		}
+		if pokemon.ID != idInt {
+			return model.Errorf(model.ErrValidation, "Body id %d does not match path id %d", pokemon.ID, idInt)
+		}
+		return nil
+	})
+	if err != nil {
+		renderError(ctx, err)
+		return
+	}
+
+	render(ctx, http.StatusOK, pokemon)
+}
+
+// Updates the pokemon under id, making sure the body does not point at a different one
+func (c Controller) update(ctx *gin.Context, id int, pokemon model.Pokemon) {
+	if pokemon.ID == 0 {
+		pokemon.ID = id
+	}
+	if pokemon.ID != id {
+		badParam(ctx, "Body id %d does not match path id %d", pokemon.ID, id)
+		return
+	}
+
+	if err := c.uc.UpdatePokemon(pokemon); err != nil {
+		renderError(ctx, err)
+		return
+	}
+
+	render(ctx, http.StatusOK, pokemon)
 }
 
-// FetchPokemonsFromApi - handlers that returns a pokemon list from external API
+// DeletePokemon - handler that removes a pokemon given its id
+func (c Controller) DeletePokemon(ctx *gin.Context) {
+	idInt, ok := parseIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.uc.DeletePokemon(idInt); err != nil {
+		renderError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Parses the 'id' path param
+// Writes a bad request response and returns false if it is wrong
+func parseIdParam(ctx *gin.Context) (int, bool) {
+	id := ctx.Param("id")
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		badParam(ctx, "Failed to convert id %s to int", id)
+		return 0, false
+	}
+	return idInt, true
+}
+
+// FetchPokemonsFromApi - handler that syncs the local pokemons with the external API, responding with the diff
+// The 'mode' param tells how: 'replace' (the default), 'merge' or 'upsert-only'.
+// With 'dry_run=true' the diff is only a preview and nothing is changed.
+// Joins the refresh already running with the same settings, if any, scheduled ones included.
+// The client going away stops the fetch it started
 func (c Controller) FetchPokemonsFromApi(ctx *gin.Context) {
-	data, err := c.uc.FetchPokemonsFromApi()
+	mode, err := enum.ParseSyncMode(ctx.DefaultQuery("mode", "replace"))
 	if err != nil {
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		badParam(ctx, "'mode' param error. %s", err)
 		return
 	}
 
-	// Update repository underlying info
-	c.uc.SetPokemons(data)
+	dryRunArg := ctx.DefaultQuery("dry_run", "false")
+	dryRun, err := strconv.ParseBool(dryRunArg)
+	if err != nil {
+		badParam(ctx, "'dry_run' param error. Cannot convert %s to bool", dryRunArg)
+		return
+	}
+
+	report, err := c.uc.RefreshPokemons(ctx.Request.Context(), mode, dryRun)
+	if err != nil {
+		renderError(ctx, err)
+		return
+	}
 
-	ctx.IndentedJSON(http.StatusOK, c.uc.GetAllPokemons())
+	render(ctx, http.StatusOK, report)
+}
+
+// GetRefreshStatus - handler that returns how the last refresh from the external API went and when the next scheduled one is
+func (c Controller) GetRefreshStatus(ctx *gin.Context) {
+	render(ctx, http.StatusOK, c.uc.RefreshStatus())
+}
+
+// GetUpstreamStatus - handler that returns the circuit breaker state of the external API client
+func (c Controller) GetUpstreamStatus(ctx *gin.Context) {
+	render(ctx, http.StatusOK, c.uc.UpstreamStatus())
 }
 
 // FilterPokemonsConcurrently - handler to return a list of odd/even pokemons processed concurrently
@@ -72,31 +279,150 @@ func (c Controller) FilterPokemonsConcurrently(ctx *gin.Context) {
 	// Only support "odd" or "even". Parsing will know
 	oddEven, err := enum.ParseOddEven(typeArg)
 	if err != nil {
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"message": "'type' param error. " + err.Error()})
+		badParam(ctx, "'type' param error. %s", err)
+		return
+	}
+
+	params, ok := c.parsePoolParams(ctx)
+	if !ok {
+		return
+	}
+
+	reqCtx, cancel, ok := poolContext(ctx)
+	if !ok {
+		return
+	}
+	defer cancel()
+
+	// Clients asking for NDJSON or SSE get every pokemon as soon as a worker finds it
+	if format := streamFormat(ctx); format != "" {
+		writeStream(ctx, format, func(emit func(model.Pokemon)) (workerpool.StopReason, error) {
+			return c.uc.StreamFilterPokemons(reqCtx, params.source, oddEven, params.numWorkers, params.items, params.itemsPerWorker, emit)
+		})
 		return
 	}
 
+	data, err := c.uc.FilterPokemonsConcurrently(reqCtx, params.source, oddEven, params.numWorkers, params.items, params.itemsPerWorker)
+	writePoolResult(ctx, data, err)
+}
+
+// SearchPokemonsConcurrently - handler to return a list of pokemons matching a filter expression, processed concurrently
+func (c Controller) SearchPokemonsConcurrently(ctx *gin.Context) {
+	q := ctx.Query("q")
+	predicate, err := filter.Parse(q)
+	if err != nil {
+		// The problem points at the failing position of the expression
+		badParam(ctx, "'q' param error. %w", err)
+		return
+	}
+
+	params, ok := c.parsePoolParams(ctx)
+	if !ok {
+		return
+	}
+
+	reqCtx, cancel, ok := poolContext(ctx)
+	if !ok {
+		return
+	}
+	defer cancel()
+
+	// Clients asking for NDJSON or SSE get every pokemon as soon as a worker finds it
+	if format := streamFormat(ctx); format != "" {
+		writeStream(ctx, format, func(emit func(model.Pokemon)) (workerpool.StopReason, error) {
+			return c.uc.StreamSearchPokemons(reqCtx, params.source, predicate, params.numWorkers, params.items, params.itemsPerWorker, emit)
+		})
+		return
+	}
+
+	data, err := c.uc.SearchPokemonsConcurrently(reqCtx, params.source, predicate, params.numWorkers, params.items, params.itemsPerWorker)
+	writePoolResult(ctx, data, err)
+}
+
+// Settings shared by the worker pool endpoints
+type poolParams struct {
+	source         enum.Source
+	numWorkers     int
+	items          int
+	itemsPerWorker int
+}
+
+// Parses the 'source', 'workers', 'items' and 'items_per_workers' query params shared by the worker pool endpoints
+// Writes a bad request response and returns false if any of them is wrong
+func (c Controller) parsePoolParams(ctx *gin.Context) (poolParams, bool) {
+	defaults := c.defaults.Load().(PoolDefaults)
+
+	// Where to read pokemons from: the in-memory repository or the CSV file, row by row
+	sourceArg := ctx.DefaultQuery("source", "memory")
+	source, err := enum.ParseSource(sourceArg)
+	if err != nil {
+		badParam(ctx, "'source' param error. %s", err)
+		return poolParams{}, false
+	}
+
 	// Amount of valid items you need to display as a response
-	// TODO: Take the default value from the env
-	items := ctx.DefaultQuery("items", "5")
+	items := ctx.DefaultQuery("items", strconv.Itoa(defaults.Items))
 	itemsInt, err := strconv.Atoi(items)
 	if err != nil {
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"message": "'items' param error. Cannot convert " + items + " to int"})
-		return
+		badParam(ctx, "'items' param error. Cannot convert %s to int", items)
+		return poolParams{}, false
 	}
 
 	// Amount of valid items the worker should append to the response
-	// TODO: Take the default value from the env
-	ipw := ctx.DefaultQuery("items_per_workers", "10")
+	ipw := ctx.DefaultQuery("items_per_workers", strconv.Itoa(defaults.ItemsPerWorker))
 	ipwInt, err := strconv.Atoi(ipw)
 	if err != nil {
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"message": "'items_per_workers' param error. Cannot convert " + ipw + " to int"})
-		return
+		badParam(ctx, "'items_per_workers' param error. Cannot convert %s to int", ipw)
+		return poolParams{}, false
+	}
+
+	// Workers processing pokemons concurrently. The usecase tells whether there is room for that many
+	workers := ctx.DefaultQuery("workers", strconv.Itoa(defaults.Workers))
+	workersInt, err := strconv.Atoi(workers)
+	if err != nil || workersInt <= 0 {
+		badParam(ctx, "'workers' param error. Cannot convert %s to a positive int", workers)
+		return poolParams{}, false
+	}
+	// Checked even without a budget, which only bounds the workers running at once
+	if defaults.MaxWorkers > 0 && workersInt > defaults.MaxWorkers {
+		badParam(ctx, "'workers' param error. %d requested, must be between 1 and %d", workersInt, defaults.MaxWorkers)
+		return poolParams{}, false
 	}
 
-	// As of now this is not a param.
-	// TODO: Take the default value from the env
-	numWorkers := 2
+	return poolParams{source, workersInt, itemsInt, ipwInt}, true
+}
+
+// Builds the context the worker pool runs with: cancelled when the client goes away,
+// and with a deadline if the optional 'timeout' query param (i.e. 500ms, 2s) is given
+// Writes a bad request response and returns false if the timeout is wrong
+func poolContext(ctx *gin.Context) (context.Context, context.CancelFunc, bool) {
+	timeout := ctx.Query("timeout")
+	if timeout == "" {
+		reqCtx, cancel := context.WithCancel(ctx.Request.Context())
+		return reqCtx, cancel, true
+	}
+
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		badParam(ctx, "'timeout' param error. Cannot convert %s to a positive duration", timeout)
+		return nil, nil, false
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), duration)
+	return reqCtx, cancel, true
+}
 
-	ctx.IndentedJSON(http.StatusOK, c.uc.FilterPokemonsConcurrently(oddEven, numWorkers, itemsInt, ipwInt))
+// Writes the outcome of a worker pool run
+func writePoolResult(ctx *gin.Context, data []model.Pokemon, err error) {
+	switch {
+	case err == nil:
+		render(ctx, http.StatusOK, data)
+	case errors.Is(err, context.DeadlineExceeded):
+		renderError(ctx, model.Errorf(context.DeadlineExceeded, "Timed out before finding the requested items"))
+	case errors.Is(err, context.Canceled):
+		// The client went away, there is no one to answer to
+		ctx.Abort()
+	default:
+		renderError(ctx, err)
+	}
 }
@@ -2,20 +2,30 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"rincon-orlando/go-bootcamp/model"
 	"rincon-orlando/go-bootcamp/util/enum"
+	"rincon-orlando/go-bootcamp/util/filter"
+	"rincon-orlando/go-bootcamp/workerpool"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// Same as the configuration defaults
+var testDefaults = PoolDefaults{Workers: 2, MaxWorkers: 200, Items: 5, ItemsPerWorker: 10}
+
 var pokemons = []model.Pokemon{
 	{ID: 1, Name: "bulbasaur"},
 	{ID: 2, Name: "ivysaur"},
@@ -24,30 +34,117 @@ var pokemons = []model.Pokemon{
 
 type mockUseCase struct {
 	mock.Mock
+	lastModified time.Time // Returned as is, so tests not caring about it need no expectation
+	version      uint64    // Same
+}
+
+func (muc *mockUseCase) GetAllPokemons() ([]model.Pokemon, error) {
+	arg := muc.Called()
+	return arg.Get(0).([]model.Pokemon), arg.Error(1)
 }
 
-func (muc mockUseCase) GetAllPokemons() []model.Pokemon {
+func (muc *mockUseCase) ListPokemons(sortBy string, offset int, limit int) ([]model.Pokemon, int, error) {
 	arg := muc.Called()
-	return arg.Get(0).([]model.Pokemon)
+	return arg.Get(0).([]model.Pokemon), arg.Int(1), arg.Error(2)
 }
 
-func (muc mockUseCase) GetPokemonById(id int) (*model.Pokemon, error) {
+func (muc *mockUseCase) GetPokemonById(id int) (*model.Pokemon, error) {
 	arg := muc.Called()
 	return arg.Get(0).(*model.Pokemon), arg.Error(1)
 }
 
-func (muc mockUseCase) SetPokemons(pokemons []model.Pokemon) {
-	// Just don't fail :)
+func (muc *mockUseCase) LastModified() time.Time {
+	return muc.lastModified
+}
+
+func (muc *mockUseCase) Version() uint64 {
+	return muc.version
+}
+
+func (muc *mockUseCase) RefreshPokemons(ctx context.Context, mode enum.SyncMode, dryRun bool) (model.SyncReport, error) {
+	arg := muc.Called(mode, dryRun)
+	return arg.Get(0).(model.SyncReport), arg.Error(1)
+}
+
+func (muc *mockUseCase) RefreshStatus() model.RefreshStatus {
+	arg := muc.Called()
+	return arg.Get(0).(model.RefreshStatus)
+}
+
+func (muc *mockUseCase) CreatePokemon(pokemon model.Pokemon) (*model.Pokemon, error) {
+	arg := muc.Called()
+	if err := arg.Error(0); err != nil {
+		return nil, err
+	}
+	return &pokemon, nil
+}
+
+func (muc *mockUseCase) UpdatePokemon(pokemon model.Pokemon) error {
+	arg := muc.Called()
+	return arg.Error(0)
+}
+
+// Patches the pokemon the expectation returns, unless it comes with an error
+func (muc *mockUseCase) PatchPokemon(id int, patch func(pokemon *model.Pokemon) error) (*model.Pokemon, error) {
+	arg := muc.Called()
+	if err := arg.Error(1); err != nil {
+		return nil, err
+	}
+	pokemon := *arg.Get(0).(*model.Pokemon)
+	if err := patch(&pokemon); err != nil {
+		return nil, err
+	}
+	return &pokemon, nil
+}
+
+func (muc *mockUseCase) DeletePokemon(id int) error {
+	arg := muc.Called()
+	return arg.Error(0)
+}
+
+func (muc *mockUseCase) ImportPokemons(r io.Reader, mode enum.ImportMode) (model.ImportReport, error) {
+	arg := muc.Called(mode)
+	return arg.Get(0).(model.ImportReport), arg.Error(1)
+}
+
+func (muc *mockUseCase) ExportPokemons(w io.Writer) error {
+	arg := muc.Called()
+	if err := arg.Error(0); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "id,name\n1,bulbasaur\n")
+	return err
+}
+
+func (muc *mockUseCase) UpstreamStatus() model.UpstreamStatus {
+	arg := muc.Called()
+	return arg.Get(0).(model.UpstreamStatus)
+}
+
+func (muc *mockUseCase) FilterPokemonsConcurrently(context.Context, enum.Source, enum.OddEven, int, int, int) ([]model.Pokemon, error) {
+	arg := muc.Called()
+	return arg.Get(0).([]model.Pokemon), arg.Error(1)
 }
 
-func (muc mockUseCase) FetchPokemonsFromApi() ([]model.Pokemon, error) {
+func (muc *mockUseCase) SearchPokemonsConcurrently(context.Context, enum.Source, filter.Predicate, int, int, int) ([]model.Pokemon, error) {
 	arg := muc.Called()
 	return arg.Get(0).([]model.Pokemon), arg.Error(1)
 }
 
-func (muc mockUseCase) FilterPokemonsConcurrently(enum.OddEven, int, int, int) []model.Pokemon {
+func (muc *mockUseCase) StreamFilterPokemons(_ context.Context, _ enum.Source, _ enum.OddEven, _ int, _ int, _ int, emit func(model.Pokemon)) (workerpool.StopReason, error) {
+	arg := muc.Called()
+	for _, pokemon := range arg.Get(0).([]model.Pokemon) {
+		emit(pokemon)
+	}
+	return arg.Get(1).(workerpool.StopReason), arg.Error(2)
+}
+
+func (muc *mockUseCase) StreamSearchPokemons(_ context.Context, _ enum.Source, _ filter.Predicate, _ int, _ int, _ int, emit func(model.Pokemon)) (workerpool.StopReason, error) {
 	arg := muc.Called()
-	return arg.Get(0).([]model.Pokemon)
+	for _, pokemon := range arg.Get(0).([]model.Pokemon) {
+		emit(pokemon)
+	}
+	return arg.Get(1).(workerpool.StopReason), arg.Error(2)
 }
 
 // TestController_GetAllPokemons - Test controller get all pokemons
@@ -70,10 +167,10 @@ func TestController_GetAllPokemons(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, r := gin.CreateTestContext(w)
 
-		muc := mockUseCase{}
-		muc.On("GetAllPokemons").Return(tc.useCasePokemons)
+		muc := &mockUseCase{}
+		muc.On("ListPokemons").Return(tc.useCasePokemons, len(tc.useCasePokemons), nil)
 
-		ctl := New(muc)
+		ctl := New(muc, testDefaults)
 
 		r.GET("/pokemons", ctl.GetAllPokemons)
 
@@ -92,8 +189,142 @@ func TestController_GetAllPokemons(t *testing.T) {
 	}
 }
 
+// RFC 7807 problem details of error responses
 type controllerResponse struct {
-	Message string `json:"message"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Code   string `json:"code"`
+}
+
+// TestController_GetAllPokemons_Paging - Test controller paging, sorting and projection of all pokemons
+func TestController_GetAllPokemons_Paging(t *testing.T) {
+	testCases := []struct {
+		name              string
+		query             string
+		useCasePokemons   []model.Pokemon
+		useCaseTotal      int
+		useCaseError      error
+		expectedBody      string
+		expectedTotal     string
+		expectedLink      string
+		expectedErrorCode int
+		error             error
+	}{
+		{
+			name:            "first page",
+			query:           "limit=2",
+			useCasePokemons: pokemons[:2],
+			useCaseTotal:    5,
+			expectedBody:    `[{"id":1,"name":"bulbasaur"},{"id":2,"name":"ivysaur"}]`,
+			expectedTotal:   "5",
+			expectedLink:    `</pokemons?limit=2&offset=0>; rel="first", </pokemons?limit=2&offset=2>; rel="next", </pokemons?limit=2&offset=4>; rel="last"`,
+		},
+		{
+			name:            "middle page",
+			query:           "limit=2&offset=2&sort=-name",
+			useCasePokemons: pokemons[2:],
+			useCaseTotal:    5,
+			expectedBody:    `[{"id":3,"name":"venusaur"}]`,
+			expectedTotal:   "5",
+			expectedLink:    `</pokemons?limit=2&offset=0&sort=-name>; rel="first", </pokemons?limit=2&offset=0&sort=-name>; rel="prev", </pokemons?limit=2&offset=4&sort=-name>; rel="next", </pokemons?limit=2&offset=4&sort=-name>; rel="last"`,
+		},
+		{
+			name:            "last page through cursor",
+			query:           "limit=2&cursor=" + encodeCursor(2),
+			useCasePokemons: pokemons[2:],
+			useCaseTotal:    3,
+			expectedBody:    `[{"id":3,"name":"venusaur"}]`,
+			expectedTotal:   "3",
+			expectedLink:    `</pokemons?cursor=` + encodeCursor(0) + `&limit=2>; rel="first", </pokemons?cursor=` + encodeCursor(0) + `&limit=2>; rel="prev", </pokemons?cursor=` + encodeCursor(2) + `&limit=2>; rel="last"`,
+		},
+		{
+			name:            "field projection",
+			query:           "fields=name",
+			useCasePokemons: pokemons,
+			useCaseTotal:    3,
+			expectedBody:    `[{"name":"bulbasaur"},{"name":"ivysaur"},{"name":"venusaur"}]`,
+			expectedTotal:   "3",
+		},
+		{
+			name:              "wrong limit",
+			query:             "limit=0",
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'limit' param error. Must be an int between 1 and 1000"),
+		},
+		{
+			name:              "wrong offset",
+			query:             "offset=-1",
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'offset' param error. Cannot convert -1 to a non negative int"),
+		},
+		{
+			name:              "wrong cursor",
+			query:             "cursor=nope",
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'cursor' param error. Invalid cursor nope"),
+		},
+		{
+			name:              "offset and cursor",
+			query:             "offset=1&cursor=" + encodeCursor(2),
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'offset' and 'cursor' params cannot be used together"),
+		},
+		{
+			name:              "wrong sort",
+			query:             "sort=color",
+			useCasePokemons:   []model.Pokemon{},
+			useCaseError:      model.Errorf(model.ErrValidation, "color is not a valid sort key"),
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'sort' param error. color is not a valid sort key"),
+		},
+		{
+			name:              "unreadable repository",
+			useCasePokemons:   []model.Pokemon{},
+			useCaseError:      model.Errorf(model.ErrPersistence, "disk I/O error"),
+			expectedErrorCode: http.StatusInternalServerError,
+			error:             errors.New("disk I/O error"),
+		},
+		{
+			name:              "wrong field",
+			query:             "fields=name,color",
+			useCasePokemons:   pokemons,
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'fields' param error. color is not a pokemon field"),
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, r := gin.CreateTestContext(w)
+
+			muc := &mockUseCase{}
+			muc.On("ListPokemons").Return(tc.useCasePokemons, tc.useCaseTotal, tc.useCaseError)
+
+			ctl := New(muc, testDefaults)
+
+			r.GET("/pokemons", ctl.GetAllPokemons)
+
+			c.Request, _ = http.NewRequest(http.MethodGet, "/pokemons?"+tc.query, nil)
+
+			r.ServeHTTP(w, c.Request)
+
+			b, _ := ioutil.ReadAll(w.Body)
+			if tc.expectedErrorCode != 0 {
+				assert.Equal(t, tc.expectedErrorCode, w.Code)
+				var cr controllerResponse
+				json.Unmarshal(b, &cr)
+				assert.Equal(t, tc.error.Error(), cr.Detail)
+			} else {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.JSONEq(t, tc.expectedBody, string(b))
+				assert.Equal(t, tc.expectedTotal, w.Header().Get("X-Total-Count"))
+				assert.Equal(t, tc.expectedLink, w.Header().Get("Link"))
+			}
+		})
+	}
 }
 
 // TestController_GetPokemonById - Test controller GetPokemonById
@@ -128,7 +359,7 @@ func TestController_GetPokemonById(t *testing.T) {
 			useCasePokemon:    nil,
 			expectedResponse:  nil,
 			expectedErrorCode: http.StatusNotFound,
-			error:             errors.New("UseCase thrown error"),
+			error:             model.Errorf(model.ErrNotFound, "pokemon with 4 not found"),
 		},
 	}
 
@@ -138,10 +369,10 @@ func TestController_GetPokemonById(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, r := gin.CreateTestContext(w)
 
-		muc := mockUseCase{}
+		muc := &mockUseCase{}
 		muc.On("GetPokemonById").Return(tc.useCasePokemon, tc.error)
 
-		ctl := New(muc)
+		ctl := New(muc, testDefaults)
 
 		r.GET("/pokemons/:id", ctl.GetPokemonById)
 
@@ -154,7 +385,7 @@ func TestController_GetPokemonById(t *testing.T) {
 			assert.Equal(t, tc.expectedErrorCode, w.Code)
 			var cr controllerResponse
 			json.Unmarshal(b, &cr)
-			assert.Equal(t, tc.error.Error(), cr.Message)
+			assert.Equal(t, tc.error.Error(), cr.Detail)
 		} else {
 			var response model.Pokemon
 			json.Unmarshal(b, &response)
@@ -165,58 +396,199 @@ func TestController_GetPokemonById(t *testing.T) {
 
 // TestController_FetchPokemonsFromApi - Test controller FetchPokemonsFromApi method
 func TestController_FetchPokemonsFromApi(t *testing.T) {
+	report := model.SyncReport{Mode: "merge", Added: []int{3}, Updated: []int{1}, Unchanged: []int{2}, Removed: []int{}}
+
 	testCases := []struct {
 		name              string
-		useCasePokemons   []model.Pokemon
-		expectedResponse  []model.Pokemon
+		query             string
+		refreshError      error
+		expectedMode      enum.SyncMode
+		expectedDryRun    bool
 		expectedErrorCode int
 		error             error
 	}{
 		{
-			name:              "get all pokemons",
-			useCasePokemons:   pokemons,
-			expectedResponse:  pokemons,
-			expectedErrorCode: 0,
-			error:             nil,
+			name:           "replace by default",
+			expectedMode:   enum.Replace,
+			expectedDryRun: false,
+		},
+		{
+			name:           "merge dry run",
+			query:          "?mode=merge&dry_run=true",
+			expectedMode:   enum.Merge,
+			expectedDryRun: true,
+		},
+		{
+			name:           "upsert only",
+			query:          "?mode=upsert-only&dry_run=0",
+			expectedMode:   enum.UpsertOnly,
+			expectedDryRun: false,
+		},
+		{
+			name:              "wrong mode",
+			query:             "?mode=append",
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'mode' param error. append is not a valid input. Must be either 'replace', 'merge' or 'upsert-only'"),
+		},
+		{
+			name:              "wrong dry run",
+			query:             "?dry_run=maybe",
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'dry_run' param error. Cannot convert maybe to bool"),
 		},
 		{
 			name:              "failed api request",
-			useCasePokemons:   pokemons,
-			expectedResponse:  []model.Pokemon{},
+			expectedMode:      enum.Replace,
+			refreshError:      errors.New("Internal Server Error"),
 			expectedErrorCode: http.StatusInternalServerError,
 			error:             errors.New("Internal Server Error"),
 		},
+		{
+			name:              "api unavailable",
+			expectedMode:      enum.Replace,
+			refreshError:      model.Errorf(model.ErrUpstreamUnavailable, "fetching pokemons from the API: %w", errors.New("circuit breaker is open")),
+			expectedErrorCode: http.StatusBadGateway,
+			error:             errors.New("fetching pokemons from the API: circuit breaker is open"),
+		},
+		{
+			name:              "failed persisting pokemons",
+			expectedMode:      enum.Replace,
+			refreshError:      model.Errorf(model.ErrPersistence, "persisting pokemons into pokemons.csv: disk full"),
+			expectedErrorCode: http.StatusInternalServerError,
+			error:             errors.New("persisting pokemons into pokemons.csv: disk full"),
+		},
 	}
 
 	gin.SetMode(gin.TestMode)
 
 	for _, tc := range testCases {
-		w := httptest.NewRecorder()
-		c, r := gin.CreateTestContext(w)
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, r := gin.CreateTestContext(w)
+
+			muc := &mockUseCase{}
+			muc.On("RefreshPokemons", tc.expectedMode, tc.expectedDryRun).Return(report, tc.refreshError)
+
+			ctl := New(muc, testDefaults)
+
+			r.GET("/pokemons/fetch", ctl.FetchPokemonsFromApi)
+
+			c.Request, _ = http.NewRequest(http.MethodGet, "/pokemons/fetch"+tc.query, nil)
+
+			r.ServeHTTP(w, c.Request)
+
+			b, _ := ioutil.ReadAll(w.Body)
+			if tc.expectedErrorCode != 0 {
+				assert.Equal(t, tc.expectedErrorCode, w.Code)
+				var cr controllerResponse
+				json.Unmarshal(b, &cr)
+				assert.Equal(t, tc.error.Error(), cr.Detail)
+			} else {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var response model.SyncReport
+				json.Unmarshal(b, &response)
+				assert.Equal(t, report, response)
+				muc.AssertCalled(t, "RefreshPokemons", tc.expectedMode, tc.expectedDryRun)
+			}
+		})
+	}
+}
 
-		muc := mockUseCase{}
-		muc.On("FetchPokemonsFromApi").Return(tc.useCasePokemons, tc.error)
-		muc.On("GetAllPokemons").Return(tc.useCasePokemons)
+// TestController_GetRefreshStatus - Test controller GetRefreshStatus method
+func TestController_GetRefreshStatus(t *testing.T) {
+	startedAt := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	nextRun := startedAt.Add(6 * time.Hour)
+	testCases := []struct {
+		name             string
+		status           model.RefreshStatus
+		expectedResponse string
+	}{
+		{
+			name:             "never refreshed",
+			status:           model.RefreshStatus{},
+			expectedResponse: `{"running": false}`,
+		},
+		{
+			name: "scheduled",
+			status: model.RefreshStatus{
+				Schedule: "6h",
+				LastRun: &model.RefreshRun{
+					Trigger:   model.ScheduledRefresh,
+					Mode:      "merge",
+					StartedAt: startedAt,
+					Duration:  "1.5s",
+					Outcome:   "failure",
+					Error:     "fetching pokemons from the API: circuit breaker is open, not calling the API",
+				},
+				NextRun: &nextRun,
+			},
+			expectedResponse: `{"schedule": "6h", "running": false, "next_run": "2021-10-01T18:00:00Z", "last_run": {
+				"trigger": "scheduled", "mode": "merge", "started_at": "2021-10-01T12:00:00Z", "duration": "1.5s",
+				"outcome": "failure", "error": "fetching pokemons from the API: circuit breaker is open, not calling the API"}}`,
+		},
+	}
 
-		ctl := New(muc)
+	gin.SetMode(gin.TestMode)
 
-		r.GET("/pokemons/fetch", ctl.FetchPokemonsFromApi)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
 
-		c.Request, _ = http.NewRequest(http.MethodGet, "/pokemons/fetch", bytes.NewBuffer([]byte("{}")))
+			muc := &mockUseCase{}
+			muc.On("RefreshStatus").Return(tc.status)
 
-		r.ServeHTTP(w, c.Request)
+			ctl := New(muc, testDefaults)
+			r.GET("/refresh/status", ctl.GetRefreshStatus)
 
-		b, _ := ioutil.ReadAll(w.Body)
-		if w.Code != http.StatusOK {
-			assert.Equal(t, tc.expectedErrorCode, w.Code)
-			var cr controllerResponse
-			json.Unmarshal(b, &cr)
-			assert.Equal(t, tc.error.Error(), cr.Message)
-		} else {
-			var response []model.Pokemon
-			json.Unmarshal(b, &response)
-			assert.Equal(t, tc.expectedResponse, response)
-		}
+			req, _ := http.NewRequest(http.MethodGet, "/refresh/status", nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tc.expectedResponse, w.Body.String())
+		})
+	}
+}
+
+// TestController_GetUpstreamStatus - Test controller GetUpstreamStatus method
+func TestController_GetUpstreamStatus(t *testing.T) {
+	retryAt := time.Date(2021, 10, 1, 12, 0, 30, 0, time.UTC)
+	testCases := []struct {
+		name             string
+		status           model.UpstreamStatus
+		expectedResponse string
+	}{
+		{
+			name:             "closed",
+			status:           model.UpstreamStatus{State: "closed", ConsecutiveFailures: 2},
+			expectedResponse: `{"state": "closed", "consecutive_failures": 2}`,
+		},
+		{
+			name:             "open",
+			status:           model.UpstreamStatus{State: "open", ConsecutiveFailures: 5, RetryAt: &retryAt},
+			expectedResponse: `{"state": "open", "consecutive_failures": 5, "retry_at": "2021-10-01T12:00:30Z"}`,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+
+			muc := &mockUseCase{}
+			muc.On("UpstreamStatus").Return(tc.status)
+
+			ctl := New(muc, testDefaults)
+			r.GET("/upstream/status", ctl.GetUpstreamStatus)
+
+			req, _ := http.NewRequest(http.MethodGet, "/upstream/status", nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tc.expectedResponse, w.Body.String())
+		})
 	}
 }
 
@@ -226,9 +598,11 @@ func TestController_FilterPokemonsConcurrently(t *testing.T) {
 		name              string
 		query             string
 		useCasePokemons   []model.Pokemon
+		useCaseError      error
 		expectedResponse  []model.Pokemon
 		expectedErrorCode int
 		error             error
+		expectedRetry     string
 	}{
 		{
 			name:              "default values",
@@ -270,6 +644,76 @@ func TestController_FilterPokemonsConcurrently(t *testing.T) {
 			expectedErrorCode: http.StatusBadRequest,
 			error:             errors.New("'items_per_workers' param error. Cannot convert ipw to int"),
 		},
+		{
+			name:              "wrong source value",
+			query:             "type=even&source=disk",
+			useCasePokemons:   pokemons,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'source' param error. disk is not a valid input. Must be either 'memory' or 'csv'"),
+		},
+		{
+			name:              "wrong timeout value",
+			query:             "type=even&timeout=soon",
+			useCasePokemons:   pokemons,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'timeout' param error. Cannot convert soon to a positive duration"),
+		},
+		{
+			name:              "wrong workers value",
+			query:             "type=even&workers=0",
+			useCasePokemons:   pokemons,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'workers' param error. Cannot convert 0 to a positive int"),
+		},
+		{
+			name:              "too many workers",
+			query:             "type=even&workers=100",
+			useCasePokemons:   []model.Pokemon{},
+			useCaseError:      model.Errorf(model.ErrValidation, "%w", fmt.Errorf("%w: 100 requested, must be between 1 and 8", workerpool.ErrTooManyWorkers)),
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("too many workers: 100 requested, must be between 1 and 8"),
+		},
+		{
+			name:              "too many workers without budget",
+			query:             "type=even&workers=1000000",
+			useCasePokemons:   pokemons,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'workers' param error. 1000000 requested, must be between 1 and 200"),
+		},
+		{
+			name:              "no room to wait for workers",
+			query:             "type=even",
+			useCasePokemons:   []model.Pokemon{},
+			useCaseError:      model.Errorf(model.ErrTooManyRequests, "%w", &workerpool.BusyError{Err: workerpool.ErrQueueFull, Wait: 2 * time.Second}),
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusTooManyRequests,
+			error:             errors.New("too many requests waiting for workers"),
+			expectedRetry:     "2",
+		},
+		{
+			name:              "waited too long for workers",
+			query:             "type=even",
+			useCasePokemons:   []model.Pokemon{},
+			useCaseError:      model.Errorf(model.ErrOverloaded, "%w", &workerpool.BusyError{Err: workerpool.ErrQueueTimeout, Wait: 1500 * time.Millisecond}),
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusServiceUnavailable,
+			error:             errors.New("timed out waiting for workers"),
+			expectedRetry:     "2",
+		},
+		{
+			name:              "timed out",
+			query:             "type=even&timeout=10ms",
+			useCasePokemons:   []model.Pokemon{},
+			useCaseError:      context.DeadlineExceeded,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusServiceUnavailable,
+			error:             errors.New("Timed out before finding the requested items"),
+		},
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -278,10 +722,10 @@ func TestController_FilterPokemonsConcurrently(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, r := gin.CreateTestContext(w)
 
-		muc := mockUseCase{}
-		muc.On("FilterPokemonsConcurrently").Return(tc.useCasePokemons)
+		muc := &mockUseCase{}
+		muc.On("FilterPokemonsConcurrently").Return(tc.useCasePokemons, tc.useCaseError)
 
-		ctl := New(muc)
+		ctl := New(muc, testDefaults)
 
 		r.GET("/pokemons/filter", ctl.FilterPokemonsConcurrently)
 
@@ -294,7 +738,8 @@ func TestController_FilterPokemonsConcurrently(t *testing.T) {
 			assert.Equal(t, tc.expectedErrorCode, w.Code)
 			var cr controllerResponse
 			json.Unmarshal(b, &cr)
-			assert.Equal(t, tc.error.Error(), cr.Message)
+			assert.Equal(t, tc.error.Error(), cr.Detail)
+			assert.Equal(t, tc.expectedRetry, w.Header().Get("Retry-After"))
 		} else {
 			var response []model.Pokemon
 			json.Unmarshal(b, &response)
@@ -302,3 +747,227 @@ func TestController_FilterPokemonsConcurrently(t *testing.T) {
 		}
 	}
 }
+
+// TestController_parsePoolParams - Test the worker pool params fall back to the configured defaults, as last set
+func TestController_parsePoolParams(t *testing.T) {
+	defaults := PoolDefaults{Workers: 3, Items: 7, ItemsPerWorker: 4}
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected poolParams
+	}{
+		{
+			name:     "defaults",
+			query:    "",
+			expected: poolParams{enum.Memory, 3, 7, 4},
+		},
+		{
+			name:     "params win over defaults",
+			query:    "source=csv&workers=1&items=20&items_per_workers=5",
+			expected: poolParams{enum.CSV, 1, 20, 5},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest(http.MethodGet, "/pokemons/filter?"+tc.query, nil)
+
+			ctl := New(&mockUseCase{}, testDefaults)
+			ctl.SetPoolDefaults(defaults)
+			params, ok := ctl.parsePoolParams(c)
+
+			assert.True(t, ok)
+			assert.Equal(t, tc.expected, params)
+		})
+	}
+}
+
+type searchErrorResponse struct {
+	Detail   string `json:"detail"`
+	Position *int   `json:"position"`
+}
+
+// TestController_SearchPokemonsConcurrently - Test controller SearchPokemonsConcurrently method
+func TestController_SearchPokemonsConcurrently(t *testing.T) {
+	position := func(n int) *int { return &n }
+
+	testCases := []struct {
+		name              string
+		query             string
+		useCasePokemons   []model.Pokemon
+		expectedResponse  []model.Pokemon
+		expectedErrorCode int
+		expectedPosition  *int
+		error             error
+	}{
+		{
+			name:              "valid expression",
+			query:             "q=" + url.QueryEscape(`id>1 AND name~"saur"`),
+			useCasePokemons:   pokemons[1:],
+			expectedResponse:  pokemons[1:],
+			expectedErrorCode: 0,
+			error:             nil,
+		},
+		{
+			name:              "missing expression",
+			query:             "",
+			useCasePokemons:   pokemons,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			expectedPosition:  position(0),
+			error:             errors.New(`'q' param error. expected a field, got "end of expression" at position 0`),
+		},
+		{
+			name:              "wrong expression",
+			query:             "q=" + url.QueryEscape(`id>1 AND color="red"`),
+			useCasePokemons:   pokemons,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			expectedPosition:  position(9),
+			error:             errors.New(`'q' param error. unknown field "color" at position 9`),
+		},
+		{
+			name:              "wrong items value",
+			query:             "q=id>1&items=items",
+			useCasePokemons:   pokemons,
+			expectedResponse:  nil,
+			expectedErrorCode: http.StatusBadRequest,
+			error:             errors.New("'items' param error. Cannot convert items to int"),
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		c, r := gin.CreateTestContext(w)
+
+		muc := &mockUseCase{}
+		muc.On("SearchPokemonsConcurrently").Return(tc.useCasePokemons, nil)
+
+		ctl := New(muc, testDefaults)
+
+		r.GET("/pokemons/search", ctl.SearchPokemonsConcurrently)
+
+		c.Request, _ = http.NewRequest(http.MethodGet, "/pokemons/search?"+tc.query, bytes.NewBuffer([]byte("{}")))
+
+		r.ServeHTTP(w, c.Request)
+
+		b, _ := ioutil.ReadAll(w.Body)
+		if w.Code != http.StatusOK {
+			assert.Equal(t, tc.expectedErrorCode, w.Code)
+			var er searchErrorResponse
+			json.Unmarshal(b, &er)
+			assert.Equal(t, tc.error.Error(), er.Detail)
+			assert.Equal(t, tc.expectedPosition, er.Position)
+		} else {
+			var response []model.Pokemon
+			json.Unmarshal(b, &response)
+			assert.Equal(t, tc.expectedResponse, response)
+		}
+	}
+}
+
+// TestController_FilterPokemonsConcurrently_Stream - Test controller streams the filter results when asked to
+func TestController_FilterPokemonsConcurrently_Stream(t *testing.T) {
+	testCases := []struct {
+		name             string
+		accept           string
+		useCasePokemons  []model.Pokemon
+		useCaseReason    workerpool.StopReason
+		useCaseError     error
+		expectedCode     int
+		expectedType     string
+		expectedResponse string
+	}{
+		{
+			name:            "ndjson",
+			accept:          "application/x-ndjson",
+			useCasePokemons: pokemons[:2],
+			useCaseReason:   workerpool.EOF,
+			expectedType:    "application/x-ndjson",
+			expectedResponse: `{"id":1,"name":"bulbasaur"}
+{"id":2,"name":"ivysaur"}
+{"type":"summary","reason":"eof","items":2}
+`,
+		},
+		{
+			name:            "server sent events",
+			accept:          "text/event-stream",
+			useCasePokemons: pokemons[:1],
+			useCaseReason:   workerpool.ItemsReached,
+			expectedType:    "text/event-stream",
+			expectedResponse: `event: pokemon
+data: {"id":1,"name":"bulbasaur"}
+
+event: summary
+data: {"type":"summary","reason":"items_reached","items":1}
+
+`,
+		},
+		{
+			name:            "timed out stream",
+			accept:          "application/x-ndjson",
+			useCasePokemons: pokemons[:1],
+			useCaseReason:   workerpool.Cancelled,
+			useCaseError:    context.DeadlineExceeded,
+			expectedType:    "application/x-ndjson",
+			expectedResponse: `{"id":1,"name":"bulbasaur"}
+{"type":"summary","reason":"timeout","items":1,"error":"context deadline exceeded"}
+`,
+		},
+		{
+			name:          "no room for workers",
+			accept:        "application/x-ndjson",
+			useCaseReason: workerpool.Cancelled,
+			useCaseError:  model.Errorf(model.ErrTooManyRequests, "%w", &workerpool.BusyError{Err: workerpool.ErrQueueFull, Wait: time.Second}),
+			expectedCode:  http.StatusTooManyRequests,
+			expectedType:  "application/problem+json",
+		},
+		{
+			name:          "nothing found before failing",
+			accept:        "application/x-ndjson",
+			useCaseReason: workerpool.Cancelled,
+			useCaseError:  errors.New("parse error on line 3"),
+			expectedType:  "application/x-ndjson",
+			expectedResponse: `{"type":"summary","reason":"cancelled","items":0,"error":"parse error on line 3"}
+`,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		c, r := gin.CreateTestContext(w)
+
+		muc := &mockUseCase{}
+		muc.On("StreamFilterPokemons").Return(tc.useCasePokemons, tc.useCaseReason, tc.useCaseError)
+
+		ctl := New(muc, testDefaults)
+
+		r.GET("/pokemons/filter", ctl.FilterPokemonsConcurrently)
+
+		c.Request, _ = http.NewRequest(http.MethodGet, "/pokemons/filter?type=odd", nil)
+		c.Request.Header.Set("Accept", tc.accept)
+
+		r.ServeHTTP(w, c.Request)
+
+		assert.Equal(t, tc.expectedType, w.Header().Get("Content-Type"))
+		if tc.expectedCode != 0 {
+			// Refused before streaming anything, so it is a regular error response
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			var cr controllerResponse
+			json.Unmarshal(w.Body.Bytes(), &cr)
+			assert.Equal(t, tc.useCaseError.Error(), cr.Detail)
+			continue
+		}
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, tc.expectedResponse, w.Body.String())
+	}
+}
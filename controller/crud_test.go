@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestController_Crud - Test controller CreatePokemon, UpdatePokemon, PatchPokemon and DeletePokemon methods
+func TestController_Crud(t *testing.T) {
+	testCases := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		useCaseError     error
+		expectedCode     int
+		expectedLocation string
+	}{
+		{
+			name:             "create pokemon",
+			method:           http.MethodPost,
+			path:             "/pokemons",
+			body:             `{"id": 4, "name": "charmander"}`,
+			expectedCode:     http.StatusCreated,
+			expectedLocation: "/pokemons/4",
+		},
+		{
+			name:         "create invalid pokemon",
+			method:       http.MethodPost,
+			path:         "/pokemons",
+			body:         `{"id": 4, "name": ""}`,
+			useCaseError: fmt.Errorf("%w: empty name", model.ErrInvalidPokemon),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "create taken id",
+			method:       http.MethodPost,
+			path:         "/pokemons",
+			body:         `{"id": 1, "name": "charmander"}`,
+			useCaseError: fmt.Errorf("%w: id 1 is taken", model.ErrPokemonExists),
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "create malformed body",
+			method:       http.MethodPost,
+			path:         "/pokemons",
+			body:         `{"id": `,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "update pokemon",
+			method:       http.MethodPut,
+			path:         "/pokemons/1",
+			body:         `{"name": "bulbasaur"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "update mismatching id",
+			method:       http.MethodPut,
+			path:         "/pokemons/1",
+			body:         `{"id": 2, "name": "bulbasaur"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "update unexisting pokemon",
+			method:       http.MethodPut,
+			path:         "/pokemons/4",
+			body:         `{"name": "charmander"}`,
+			useCaseError: fmt.Errorf("%w: id 4", model.ErrPokemonNotFound),
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "patch pokemon",
+			method:       http.MethodPatch,
+			path:         "/pokemons/1",
+			body:         `{"height": 7}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "patch mismatching id",
+			method:       http.MethodPatch,
+			path:         "/pokemons/1",
+			body:         `{"id": 2}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "patch malformed body",
+			method:       http.MethodPatch,
+			path:         "/pokemons/1",
+			body:         `{"height": `,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "patch unexisting pokemon",
+			method:       http.MethodPatch,
+			path:         "/pokemons/4",
+			body:         `{"height": 7}`,
+			useCaseError: fmt.Errorf("%w: id 4", model.ErrPokemonNotFound),
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "delete pokemon",
+			method:       http.MethodDelete,
+			path:         "/pokemons/1",
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "delete unparseable id",
+			method:       http.MethodDelete,
+			path:         "/pokemons/non-int",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "delete unexisting pokemon",
+			method:       http.MethodDelete,
+			path:         "/pokemons/4",
+			useCaseError: fmt.Errorf("%w: id 4", model.ErrPokemonNotFound),
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+
+			muc := &mockUseCase{}
+			muc.On("GetPokemonById").Return(&pokemons[0], nil)
+			muc.On("CreatePokemon").Return(tc.useCaseError)
+			muc.On("UpdatePokemon").Return(tc.useCaseError)
+			muc.On("PatchPokemon").Return(&pokemons[0], tc.useCaseError)
+			muc.On("DeletePokemon").Return(tc.useCaseError)
+
+			ctl := New(muc, testDefaults)
+
+			r.POST("/pokemons", ctl.CreatePokemon)
+			r.PUT("/pokemons/:id", ctl.UpdatePokemon)
+			r.PATCH("/pokemons/:id", ctl.PatchPokemon)
+			r.DELETE("/pokemons/:id", ctl.DeletePokemon)
+
+			req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedLocation, w.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/util/filter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Content type of error responses, as defined by RFC 7807
+const mimeProblem = "application/problem+json"
+
+// Header carrying the request id, taken from the client when it sends one
+const requestIDHeader = "X-Request-ID"
+
+// Key of the request id in the gin context
+const requestIDKey = "request_id"
+
+// HTTP status and machine readable code of every kind of error. Anything else is an internal error
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{model.ErrValidation, http.StatusBadRequest, "validation_error"},
+	{model.ErrNotFound, http.StatusNotFound, "not_found"},
+	{model.ErrConflict, http.StatusConflict, "conflict"},
+	{model.ErrUpstreamUnavailable, http.StatusBadGateway, "upstream_unavailable"},
+	{model.ErrPersistence, http.StatusInternalServerError, "persistence_error"},
+	{model.ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests"},
+	{model.ErrOverloaded, http.StatusServiceUnavailable, "overloaded"},
+	{model.ErrShuttingDown, http.StatusServiceUnavailable, "shutting_down"},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, "timeout"},
+}
+
+// RFC 7807 problem details, along with our own extension members
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	Position  *int   `json:"position,omitempty"` // Where a filter expression failed to parse
+}
+
+// Builds the problem details of err
+func newProblem(ctx *gin.Context, err error) problem {
+	status, code := http.StatusInternalServerError, "internal_error"
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			status, code = k.status, k.code
+			break
+		}
+	}
+
+	p := problem{
+		// No documentation page per problem, so the status title says it all
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    err.Error(),
+		Instance:  ctx.Request.URL.Path,
+		Code:      code,
+		RequestID: ctx.GetString(requestIDKey),
+	}
+
+	var parseErr *filter.ParseError
+	if errors.As(err, &parseErr) {
+		p.Position = &parseErr.Pos
+	}
+
+	return p
+}
+
+// Writes err as problem details, with the status code of its kind
+// JSON clients get application/problem+json, any other format is rendered as usual.
+// Errors telling when to try again set the Retry-After header
+func renderError(ctx *gin.Context, err error) {
+	p := newProblem(ctx, err)
+
+	var retry interface{ RetryAfter() time.Duration }
+	if errors.As(err, &retry) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.RetryAfter().Seconds()))))
+	}
+
+	if format, ok := responseFormat(ctx); ok && format != formatJSON && format != formatCompactJSON {
+		render(ctx, p.Status, p)
+		return
+	}
+	writeProblemJSON(ctx, p)
+}
+
+func writeProblemJSON(ctx *gin.Context, p problem) {
+	// gin keeps a content type that is already set
+	ctx.Header("Content-Type", mimeProblem)
+	ctx.IndentedJSON(p.Status, p)
+}
+
+// Writes a validation error about a request param
+func badParam(ctx *gin.Context, format string, args ...interface{}) {
+	renderError(ctx, model.Errorf(model.ErrValidation, format, args...))
+}
+
+// RequestID - middleware giving every request an id, echoed in the X-Request-ID header and in error responses
+func (c Controller) RequestID(ctx *gin.Context) {
+	id := ctx.GetHeader(requestIDHeader)
+	// Never trust clients to send something sensible
+	if id == "" || len(id) > 128 {
+		id = newRequestID()
+	}
+	ctx.Set(requestIDKey, id)
+	ctx.Header(requestIDHeader, id)
+	ctx.Next()
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
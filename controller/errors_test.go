@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_renderError - Validates every kind of error is mapped to its status code and problem details
+func Test_renderError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		query           string
+		requestID       string
+		expectedProblem problem
+		expectedType    string
+		expectedBody    string
+	}{
+		{
+			name:      "validation error",
+			err:       model.Errorf(model.ErrValidation, "'limit' param error"),
+			requestID: "abc-123",
+			expectedProblem: problem{Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "'limit' param error", Instance: "/problem", Code: "validation_error", RequestID: "abc-123"},
+		},
+		{
+			name: "wrapped not found error",
+			err:  fmt.Errorf("%w: id 4", model.ErrPokemonNotFound),
+			expectedProblem: problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "pokemon not found: id 4", Instance: "/problem", Code: "not_found"},
+		},
+		{
+			name: "conflict error",
+			err:  model.Errorf(model.ErrConflict, "pokemon with 1 already exists"),
+			expectedProblem: problem{Type: "about:blank", Title: "Conflict", Status: http.StatusConflict,
+				Detail: "pokemon with 1 already exists", Instance: "/problem", Code: "conflict"},
+		},
+		{
+			name: "upstream error",
+			err:  model.Errorf(model.ErrUpstreamUnavailable, "fetching pokemons from the API: %w", errors.New("connection refused")),
+			expectedProblem: problem{Type: "about:blank", Title: "Bad Gateway", Status: http.StatusBadGateway,
+				Detail: "fetching pokemons from the API: connection refused", Instance: "/problem", Code: "upstream_unavailable"},
+		},
+		{
+			name: "persistence error",
+			err:  model.Errorf(model.ErrPersistence, "persisting pokemons into pokemons.csv: disk full"),
+			expectedProblem: problem{Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError,
+				Detail: "persisting pokemons into pokemons.csv: disk full", Instance: "/problem", Code: "persistence_error"},
+		},
+		{
+			name: "timeout",
+			err:  model.Errorf(context.DeadlineExceeded, "Timed out before finding the requested items"),
+			expectedProblem: problem{Type: "about:blank", Title: "Service Unavailable", Status: http.StatusServiceUnavailable,
+				Detail: "Timed out before finding the requested items", Instance: "/problem", Code: "timeout"},
+		},
+		{
+			name: "shutting down",
+			err:  model.Errorf(model.ErrShuttingDown, "worker pool stopped: shutting down"),
+			expectedProblem: problem{Type: "about:blank", Title: "Service Unavailable", Status: http.StatusServiceUnavailable,
+				Detail: "worker pool stopped: shutting down", Instance: "/problem", Code: "shutting_down"},
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("boom"),
+			expectedProblem: problem{Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError,
+				Detail: "boom", Instance: "/problem", Code: "internal_error"},
+		},
+		{
+			name:            "problem in another format",
+			err:             model.Errorf(model.ErrNotFound, "pokemon with 4 not found"),
+			query:           "?format=csv",
+			expectedProblem: problem{Status: http.StatusNotFound},
+			expectedType:    "text/csv; charset=utf-8",
+			expectedBody:    "type,title,status,detail,instance,code,request_id\nabout:blank,Not Found,404,pokemon with 4 not found,/problem,not_found,req-1\n",
+			requestID:       "req-1",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+
+			ctl := New(&mockUseCase{}, testDefaults)
+			r.Use(ctl.RequestID)
+			r.GET("/problem", func(ctx *gin.Context) {
+				renderError(ctx, tc.err)
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/problem"+tc.query, nil)
+			if tc.requestID != "" {
+				req.Header.Set(requestIDHeader, tc.requestID)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedProblem.Status, w.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedType, w.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedBody, w.Body.String())
+				return
+			}
+
+			assert.Equal(t, mimeProblem, w.Header().Get("Content-Type"))
+			var p problem
+			json.Unmarshal(w.Body.Bytes(), &p)
+			if tc.requestID == "" {
+				// Generated ids are random, just check they are the same everywhere
+				assert.Len(t, p.RequestID, 32)
+				tc.expectedProblem.RequestID = p.RequestID
+			}
+			assert.Equal(t, tc.expectedProblem, p)
+			assert.Equal(t, p.RequestID, w.Header().Get(requestIDHeader))
+		})
+	}
+}
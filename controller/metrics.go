@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"rincon-orlando/go-bootcamp/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics - middleware observing how long every request takes, by route
+func (c Controller) Metrics(ctx *gin.Context) {
+	metrics.RequestsInFlight.Inc()
+	defer metrics.RequestsInFlight.Dec()
+
+	started := time.Now()
+	ctx.Next()
+
+	// The route pattern rather than the path, so ids do not make a series each
+	route := ctx.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	metrics.RequestDuration.WithLabelValues(route, ctx.Request.Method, strconv.Itoa(ctx.Writer.Status())).
+		Observe(time.Since(started).Seconds())
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// Requests observed so far with the given labels
+func observedRequests(t *testing.T, route string, method string, status string) uint64 {
+	var m dto.Metric
+	assert.Nil(t, metrics.RequestDuration.WithLabelValues(route, method, status).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+// TestController_Metrics - Test requests are observed by route pattern rather than path
+func TestController_Metrics(t *testing.T) {
+	testCases := []struct {
+		name          string
+		path          string
+		expectedRoute string
+		expectedCode  string
+	}{
+		{
+			name:          "matched route",
+			path:          "/pokemons/1",
+			expectedRoute: "/pokemons/:id",
+			expectedCode:  "200",
+		},
+		{
+			name:          "unmatched route",
+			path:          "/digimons/1",
+			expectedRoute: "unmatched",
+			expectedCode:  "404",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			muc := &mockUseCase{}
+			muc.On("GetPokemonById").Return(&pokemons[0], nil)
+			ctl := New(muc, testDefaults)
+
+			r := gin.New()
+			r.Use(ctl.Metrics)
+			r.GET("/pokemons/:id", ctl.GetPokemonById)
+
+			before := observedRequests(t, tc.expectedRoute, http.MethodGet, tc.expectedCode)
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, before+1, observedRequests(t, tc.expectedRoute, http.MethodGet, tc.expectedCode))
+		})
+	}
+}
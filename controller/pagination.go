@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Biggest page a client can ask for
+const maxPageLimit = 1000
+
+// Paging settings of a list request
+type page struct {
+	offset int
+	limit  int  // 0 means no limit
+	cursor bool // Whether the client pages through cursors instead of offsets
+}
+
+// Parses the 'limit' and 'offset' or 'cursor' query params
+// Writes a bad request response and returns false if any of them is wrong
+func parsePage(ctx *gin.Context) (page, bool) {
+	var p page
+
+	if limit := ctx.Query("limit"); limit != "" {
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil || limitInt <= 0 || limitInt > maxPageLimit {
+			badParam(ctx, "'limit' param error. Must be an int between 1 and %d", maxPageLimit)
+			return page{}, false
+		}
+		p.limit = limitInt
+	}
+
+	offset, cursor := ctx.Query("offset"), ctx.Query("cursor")
+	switch {
+	case offset != "" && cursor != "":
+		badParam(ctx, "'offset' and 'cursor' params cannot be used together")
+		return page{}, false
+	case offset != "":
+		offsetInt, err := strconv.Atoi(offset)
+		if err != nil || offsetInt < 0 {
+			badParam(ctx, "'offset' param error. Cannot convert %s to a non negative int", offset)
+			return page{}, false
+		}
+		p.offset = offsetInt
+	case cursor != "":
+		offsetInt, err := decodeCursor(cursor)
+		if err != nil {
+			badParam(ctx, "'cursor' param error. Invalid cursor %s", cursor)
+			return page{}, false
+		}
+		p.offset = offsetInt
+		p.cursor = true
+	}
+
+	return p, true
+}
+
+// Cursors are opaque to clients, they just wrap the offset of the page
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(string(raw), "o:") {
+		return 0, fmt.Errorf("unknown cursor %s", raw)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "o:"))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("unknown cursor %s", raw)
+	}
+	return offset, nil
+}
+
+// Sets the X-Total-Count header and, for limited pages, the RFC 5988 Link header to the first, prev, next and last pages
+func setPageHeaders(ctx *gin.Context, p page, total int) {
+	ctx.Header("X-Total-Count", strconv.Itoa(total))
+	if p.limit == 0 {
+		return
+	}
+
+	link := func(offset int, rel string) string {
+		u := *ctx.Request.URL
+		q := u.Query()
+		q.Del("offset")
+		q.Del("cursor")
+		if p.cursor {
+			q.Set("cursor", encodeCursor(offset))
+		} else {
+			q.Set("offset", strconv.Itoa(offset))
+		}
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+
+	lastOffset := 0
+	if total > 0 {
+		lastOffset = (total - 1) / p.limit * p.limit
+	}
+
+	links := []string{link(0, "first")}
+	if p.offset > 0 {
+		prev := p.offset - p.limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if next := p.offset + p.limit; next < total {
+		links = append(links, link(next, "next"))
+		ctx.Header("X-Next-Cursor", encodeCursor(next))
+	}
+	links = append(links, link(lastOffset, "last"))
+
+	ctx.Header("Link", strings.Join(links, ", "))
+}
+
+// Keeps only the requested JSON fields of every pokemon, as given by the 'fields' query param
+// Returns the pokemons untouched if no fields were requested
+// Writes a bad request response and returns false if any field is unknown
+func projectFields(ctx *gin.Context, pokemons []model.Pokemon) (interface{}, bool) {
+	fields := ctx.Query("fields")
+	if fields == "" {
+		return pokemons, true
+	}
+
+	names := strings.Split(fields, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if !pokemonFields[names[i]] {
+			badParam(ctx, "'fields' param error. %s is not a pokemon field", names[i])
+			return nil, false
+		}
+	}
+
+	projected := make([]map[string]json.RawMessage, 0, len(pokemons))
+	for _, pokemon := range pokemons {
+		// Go through JSON so field names and omitempty rules are the same as the full output
+		data, err := json.Marshal(pokemon)
+		if err != nil {
+			renderError(ctx, err)
+			return nil, false
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			renderError(ctx, err)
+			return nil, false
+		}
+
+		entry := make(map[string]json.RawMessage, len(names))
+		for _, name := range names {
+			if value, ok := all[name]; ok {
+				entry[name] = value
+			}
+		}
+		projected = append(projected, entry)
+	}
+
+	return projected, true
+}
+
+// JSON field names of a pokemon that can be projected
+var pokemonFields = map[string]bool{
+	"id":              true,
+	"name":            true,
+	"types":           true,
+	"stats":           true,
+	"height":          true,
+	"weight":          true,
+	"base_experience": true,
+	"abilities":       true,
+}
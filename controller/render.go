@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats every endpoint supports
+const (
+	formatJSON        = "json"         // Indented JSON, the default
+	formatCompactJSON = "compact-json" // JSON without any whitespace
+	formatCSV         = "csv"
+	formatXML         = "xml"
+	formatYAML        = "yaml"
+)
+
+// MIME types answered for every format
+var formatMIMEs = map[string]string{
+	formatJSON:        gin.MIMEJSON,
+	formatCompactJSON: gin.MIMEJSON,
+	formatCSV:         "text/csv",
+	formatXML:         gin.MIMEXML,
+	formatYAML:        "application/yaml",
+}
+
+// Formats matching the MIME types a client may ask for through the Accept header, in order of preference
+var acceptedMIMEs = []struct {
+	mime   string
+	format string
+}{
+	{gin.MIMEJSON, formatJSON},
+	{"text/csv", formatCSV},
+	{gin.MIMEXML, formatXML},
+	{gin.MIMEXML2, formatXML},
+	{"application/yaml", formatYAML},
+	{gin.MIMEYAML, formatYAML},
+	{"text/yaml", formatYAML},
+}
+
+// Tells which format the client asked for. The 'format' param wins over the Accept header,
+// and anything that cannot be negotiated falls back to JSON
+// Returns false along with the unknown value when the 'format' param is wrong
+func responseFormat(ctx *gin.Context) (string, bool) {
+	if format := ctx.Query("format"); format != "" {
+		format = strings.ToLower(format)
+		if _, ok := formatMIMEs[format]; !ok {
+			return format, false
+		}
+		return format, true
+	}
+
+	offered := make([]string, len(acceptedMIMEs))
+	for i, accepted := range acceptedMIMEs {
+		offered[i] = accepted.mime
+	}
+	negotiated := ctx.NegotiateFormat(offered...)
+	for _, accepted := range acceptedMIMEs {
+		if accepted.mime == negotiated {
+			return accepted.format, true
+		}
+	}
+	return formatJSON, true
+}
+
+// Writes obj with the given status code, in the format the client asked for
+// A wrong 'format' param turns any response into a bad request problem, written as JSON
+func render(ctx *gin.Context, code int, obj interface{}) {
+	contentType, data, ok := encode(ctx, obj)
+	if !ok {
+		return
+	}
+
+	ctx.Data(code, contentType, data)
+}
+
+// Encodes obj in the format the client asked for, returning its content type along with it
+// Writes a problem response and returns false if obj cannot be encoded
+func encode(ctx *gin.Context, obj interface{}) (string, []byte, bool) {
+	format, ok := responseFormat(ctx)
+	if !ok {
+		badParam(ctx, "'format' param error. %s is not a valid input. Must be either 'json', 'compact-json', 'csv', 'xml' or 'yaml'", format)
+		return "", nil, false
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case formatJSON:
+		data, err = json.MarshalIndent(obj, "", "    ")
+	case formatCompactJSON:
+		data, err = json.Marshal(obj)
+	default:
+		// Every other format is built out of the JSON one, so field names and omitempty rules are the same everywhere
+		var tree interface{}
+		tree, err = toTree(obj)
+		if err == nil {
+			switch format {
+			case formatCSV:
+				data, err = treeToCSV(tree)
+			case formatXML:
+				data, err = treeToXML(tree)
+			case formatYAML:
+				data, err = yaml.Marshal(treeToYAML(tree))
+			}
+		}
+	}
+	if err != nil {
+		// Never render again, the failure could be the format itself
+		writeProblemJSON(ctx, newProblem(ctx, err))
+		return "", nil, false
+	}
+
+	return formatMIMEs[format] + "; charset=utf-8", data, true
+}
+
+// JSON object keeping its keys in document order, unlike a map
+type object []field
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// Turns obj into objects, []interface{} and scalars (json.Number, string, bool or nil), going through its JSON encoding
+func toTree(obj interface{}) (interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decodeTree(decoder)
+}
+
+func decodeTree(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		obj := object{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeTree(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{key.(string), value})
+		}
+		_, err := decoder.Token() // Closing brace
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeTree(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token() // Closing bracket
+		return list, err
+	}
+
+	return token, nil
+}
+
+// Writes a list of objects as one row each, or a single object as one row. The header is every key, in order of appearance
+// Nested values are flattened the way the persisted csv does: lists joined by '|', objects' values joined by ':'
+func treeToCSV(tree interface{}) ([]byte, error) {
+	var rows []object
+	switch v := tree.(type) {
+	case object:
+		rows = []object{v}
+	case []interface{}:
+		for _, item := range v {
+			row, ok := item.(object)
+			if !ok {
+				row = object{{"value", item}}
+			}
+			rows = append(rows, row)
+		}
+	default:
+		rows = []object{{{"value", v}}}
+	}
+
+	var header []string
+	columns := make(map[string]int)
+	for _, row := range rows {
+		for _, f := range row {
+			if _, ok := columns[f.key]; !ok {
+				columns[f.key] = len(header)
+				header = append(header, f.key)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if len(header) > 0 {
+		writer.Write(header)
+	}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for _, f := range row {
+			record[columns[f.key]] = csvCell(f.value)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+func csvCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case object:
+		parts := make([]string, len(v))
+		for i, f := range v {
+			parts[i] = csvCell(f.value)
+		}
+		return strings.Join(parts, ":")
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = csvCell(item)
+		}
+		return strings.Join(parts, "|")
+	case json.Number:
+		return v.String()
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return ""
+}
+
+// Writes the tree under a 'response' element. Object keys become elements and list entries 'item' elements
+func treeToXML(tree interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "    ")
+	if err := encodeXML(encoder, "response", tree); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXML(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case object:
+		for _, f := range v {
+			if err := encodeXML(encoder, f.key, f.value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXML(encoder, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := encoder.EncodeToken(xml.CharData(csvCell(v))); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
+
+// Turns the tree into values yaml keeps in order, with numbers as numbers
+func treeToYAML(tree interface{}) interface{} {
+	switch v := tree.(type) {
+	case object:
+		slice := make(yaml.MapSlice, len(v))
+		for i, f := range v {
+			slice[i] = yaml.MapItem{Key: f.key, Value: treeToYAML(f.value)}
+		}
+		return slice
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = treeToYAML(item)
+		}
+		return list
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	}
+	return tree
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_render - Validates every output format for lists, single items and error bodies
+func Test_render(t *testing.T) {
+	detailed := []model.Pokemon{
+		{ID: 1, Name: "bulbasaur", Types: []string{"grass", "poison"}, Stats: []model.Stat{{Name: "hp", BaseStat: 45}}},
+		{ID: 2, Name: "ivysaur"},
+	}
+
+	testCases := []struct {
+		name                string
+		query               string
+		accept              string
+		obj                 interface{}
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "default json",
+			obj:                 detailed[1],
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        "{\n    \"id\": 2,\n    \"name\": \"ivysaur\"\n}",
+		},
+		{
+			name:                "compact json by param",
+			query:               "?format=compact-json",
+			obj:                 detailed[1],
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"id":2,"name":"ivysaur"}`,
+		},
+		{
+			name:                "csv list by accept header",
+			accept:              "text/csv",
+			obj:                 detailed,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id,name,types,stats\n1,bulbasaur,grass|poison,hp:45\n2,ivysaur,,\n",
+		},
+		{
+			name:                "csv single item",
+			query:               "?format=csv",
+			obj:                 detailed[1],
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id,name\n2,ivysaur\n",
+		},
+		{
+			name:                "csv error body",
+			query:               "?format=CSV",
+			obj:                 gin.H{"message": "pokemon with 4 not found"},
+			expectedCode:        http.StatusNotFound,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "message\npokemon with 4 not found\n",
+		},
+		{
+			name:                "xml list",
+			accept:              "application/xml",
+			obj:                 detailed,
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>
+<response>
+    <item>
+        <id>1</id>
+        <name>bulbasaur</name>
+        <types>
+            <item>grass</item>
+            <item>poison</item>
+        </types>
+        <stats>
+            <item>
+                <name>hp</name>
+                <base_stat>45</base_stat>
+            </item>
+        </stats>
+    </item>
+    <item>
+        <id>2</id>
+        <name>ivysaur</name>
+    </item>
+</response>`,
+		},
+		{
+			name:                "yaml single item",
+			query:               "?format=yaml",
+			accept:              "application/xml",
+			obj:                 detailed[0],
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/yaml; charset=utf-8",
+			expectedBody:        "id: 1\nname: bulbasaur\ntypes:\n- grass\n- poison\nstats:\n- name: hp\n  base_stat: 45\n",
+		},
+		{
+			name:                "unknown accept falls back to json",
+			accept:              "image/png",
+			obj:                 gin.H{"message": "ok"},
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        "{\n    \"message\": \"ok\"\n}",
+		},
+		{
+			name:                "wrong format param",
+			query:               "?format=html",
+			obj:                 detailed,
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/problem+json",
+			expectedBody: `{
+    "type": "about:blank",
+    "title": "Bad Request",
+    "status": 400,
+    "detail": "'format' param error. html is not a valid input. Must be either 'json', 'compact-json', 'csv', 'xml' or 'yaml'",
+    "instance": "/render",
+    "code": "validation_error"
+}`,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+
+			code := tc.expectedCode
+			if code == http.StatusBadRequest {
+				code = http.StatusOK
+			}
+			r.GET("/render", func(ctx *gin.Context) {
+				render(ctx, code, tc.obj)
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/render"+tc.query, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
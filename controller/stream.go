@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/workerpool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Streaming content types supported by the worker pool endpoints
+const (
+	mimeNDJSON = "application/x-ndjson"
+	mimeSSE    = "text/event-stream"
+)
+
+// Last entry of every stream, telling why it ended
+type streamSummary struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Items  int    `json:"items"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Function running a worker pool, handing every pokemon found to emit
+type streamFunc func(emit func(model.Pokemon)) (workerpool.StopReason, error)
+
+// Tells which streaming format the client asked for through the Accept header, if any
+func streamFormat(ctx *gin.Context) string {
+	switch ctx.NegotiateFormat(gin.MIMEJSON, mimeNDJSON, mimeSSE) {
+	case mimeNDJSON:
+		return mimeNDJSON
+	case mimeSSE:
+		return mimeSSE
+	}
+	return ""
+}
+
+// Writes every pokemon as soon as run emits it, ending with a summary entry
+// NDJSON writes one JSON document per line, SSE writes 'pokemon' events and a final 'summary' event.
+// The stream starts with the first entry, so a pool failing with a known kind of error before finding
+// anything, i.e. refused for lack of workers, still gets a proper error response
+func writeStream(ctx *gin.Context, format string, run streamFunc) {
+	started := false
+	start := func() {
+		started = true
+		ctx.Header("Content-Type", format)
+		ctx.Header("Cache-Control", "no-cache")
+		// Keep reverse proxies from buffering the stream
+		ctx.Header("X-Accel-Buffering", "no")
+		ctx.Status(http.StatusOK)
+	}
+
+	write := func(event string, v interface{}) {
+		if !started {
+			start()
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		if format == mimeSSE {
+			fmt.Fprintf(ctx.Writer, "event: %s\ndata: %s\n\n", event, data)
+		} else {
+			fmt.Fprintf(ctx.Writer, "%s\n", data)
+		}
+		ctx.Writer.Flush()
+	}
+
+	items := 0
+	reason, err := run(func(pokemon model.Pokemon) {
+		items++
+		write("pokemon", pokemon)
+	})
+
+	var kindErr *model.Error
+	if !started && errors.As(err, &kindErr) {
+		renderError(ctx, err)
+		return
+	}
+
+	summary := streamSummary{Type: "summary", Reason: string(reason), Items: items}
+	if err != nil {
+		summary.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			summary.Reason = "timeout"
+		}
+	}
+	write("summary", summary)
+}
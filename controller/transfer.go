@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"net/http"
+
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportPokemons - handler that replaces every pokemon with the ones in an uploaded CSV file
+// The file goes in the 'file' multipart form field. The 'on_error' param tells whether invalid rows
+// reject the whole file ('reject', the default) or are skipped ('skip'). Responds with a validation report
+func (c Controller) ImportPokemons(ctx *gin.Context) {
+	mode, err := enum.ParseImportMode(ctx.DefaultQuery("on_error", "reject"))
+	if err != nil {
+		badParam(ctx, "'on_error' param error. %s", err)
+		return
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		badParam(ctx, "'file' form field error. %s", err)
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		badParam(ctx, "'file' form field error. %s", err)
+		return
+	}
+	defer file.Close()
+
+	report, err := c.uc.ImportPokemons(file, mode)
+	if err != nil {
+		renderError(ctx, err)
+		return
+	}
+
+	// Nothing imported means the file was refused, the report tells why
+	if report.Imported == 0 {
+		render(ctx, http.StatusUnprocessableEntity, report)
+		return
+	}
+	render(ctx, http.StatusOK, report)
+}
+
+// ExportPokemons - handler that downloads every pokemon as a CSV file, ready to be imported back
+func (c Controller) ExportPokemons(ctx *gin.Context) {
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", `attachment; filename="pokemons.csv"`)
+
+	// The usecase writes nothing at all when it fails, so there is still room for an error response
+	if err := c.uc.ExportPokemons(ctx.Writer); err != nil {
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		renderError(ctx, err)
+	}
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Builds a multipart body holding content in the given form field
+func multipartBody(field string, content string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if field != "" {
+		part, _ := writer.CreateFormFile(field, "pokemons.csv")
+		part.Write([]byte(content))
+	}
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+// TestController_ImportPokemons - Test controller ImportPokemons method
+func TestController_ImportPokemons(t *testing.T) {
+	rejected := model.ImportReport{Skipped: 1, Issues: []model.ImportIssue{{Line: 2, Column: "id", Reason: "Error converting x to int"}}}
+
+	testCases := []struct {
+		name           string
+		query          string
+		field          string
+		mode           enum.ImportMode
+		useCaseReport  model.ImportReport
+		useCaseError   error
+		expectedCode   int
+		expectedReport *model.ImportReport
+	}{
+		{
+			name:           "import pokemons",
+			field:          "file",
+			mode:           enum.Reject,
+			useCaseReport:  model.ImportReport{Imported: 3, Issues: []model.ImportIssue{}},
+			expectedCode:   http.StatusOK,
+			expectedReport: &model.ImportReport{Imported: 3, Issues: []model.ImportIssue{}},
+		},
+		{
+			name:           "import skipping invalid rows",
+			query:          "?on_error=skip",
+			field:          "file",
+			mode:           enum.Skip,
+			useCaseReport:  model.ImportReport{Imported: 2, Skipped: 1, Issues: rejected.Issues},
+			expectedCode:   http.StatusOK,
+			expectedReport: &model.ImportReport{Imported: 2, Skipped: 1, Issues: rejected.Issues},
+		},
+		{
+			name:           "import rejected",
+			field:          "file",
+			mode:           enum.Reject,
+			useCaseReport:  rejected,
+			expectedCode:   http.StatusUnprocessableEntity,
+			expectedReport: &rejected,
+		},
+		{
+			name:         "import failed persisting",
+			field:        "file",
+			mode:         enum.Reject,
+			useCaseError: errors.New("disk full"),
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "wrong on_error param",
+			query:        "?on_error=ignore",
+			field:        "file",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "missing file",
+			field:        "",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+
+			muc := &mockUseCase{}
+			muc.On("ImportPokemons", tc.mode).Return(tc.useCaseReport, tc.useCaseError)
+
+			ctl := New(muc, testDefaults)
+			r.POST("/pokemons/import", ctl.ImportPokemons)
+
+			body, contentType := multipartBody(tc.field, "1,bulbasaur\n")
+			req, _ := http.NewRequest(http.MethodPost, "/pokemons/import"+tc.query, body)
+			req.Header.Set("Content-Type", contentType)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedReport != nil {
+				var report model.ImportReport
+				json.Unmarshal(w.Body.Bytes(), &report)
+				assert.Equal(t, *tc.expectedReport, report)
+			}
+		})
+	}
+}
+
+// TestController_ExportPokemons - Test controller ExportPokemons method
+func TestController_ExportPokemons(t *testing.T) {
+	testCases := []struct {
+		name                string
+		useCaseError        error
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "export pokemons",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id,name\n1,bulbasaur\n",
+		},
+		{
+			name:                "failed export",
+			useCaseError:        errors.New("broken"),
+			expectedCode:        http.StatusInternalServerError,
+			expectedContentType: "application/problem+json",
+			expectedBody:        "{\n    \"type\": \"about:blank\",\n    \"title\": \"Internal Server Error\",\n    \"status\": 500,\n    \"detail\": \"broken\",\n    \"instance\": \"/pokemons/export\",\n    \"code\": \"internal_error\"\n}",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+
+			muc := &mockUseCase{}
+			muc.On("ExportPokemons").Return(tc.useCaseError)
+
+			ctl := New(muc, testDefaults)
+			r.GET("/pokemons/export", ctl.ExportPokemons)
+
+			req, _ := http.NewRequest(http.MethodGet, "/pokemons/export", nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
@@ -1,42 +1,143 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
 
 	"rincon-orlando/go-bootcamp/config"
 	"rincon-orlando/go-bootcamp/controller"
+	"rincon-orlando/go-bootcamp/metrics"
 	"rincon-orlando/go-bootcamp/repository"
 	"rincon-orlando/go-bootcamp/service"
 	"rincon-orlando/go-bootcamp/usecase"
+	"rincon-orlando/go-bootcamp/util/enum"
+	"rincon-orlando/go-bootcamp/workerpool"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/pflag"
 )
 
 func main() {
-	cfg, err := config.New(".")
+	// Flags win over env vars, which win over app.env. i.e. go run . --server-port=9000
+	cfg, err := config.New(".", os.Args[1:])
+	if errors.Is(err, pflag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatal("cannot load config:", err)
+		log.Fatal("cannot load config: ", err)
 	}
 
 	// Dependency injection
 	// Clean architecture layer order:
 	// router -> controller -> usecase -> service / repository
-	db := repository.New()
-	service := service.New(cfg.POKEMON_API_URL)
-	usecase, err := usecase.New(&db, cfg.CSV_FILENAME, service)
+	backend, err := enum.ParseBackend(cfg.STORAGE_BACKEND)
+	if err != nil {
+		log.Fatal("Error reading STORAGE_BACKEND: " + err.Error())
+	}
+	db, err := repository.Open(backend, cfg.STORAGE_PATH)
+	if err != nil {
+		log.Fatal("Error opening storage: " + err.Error())
+	}
+	defer db.Close()
+
+	service := service.New(cfg.POKEMON_API_URL, cfg.POKEMON_API_LIMIT, cfg.POKEMON_API_WORKERS, cfg.POKEMON_API_MAX_IN_FLIGHT, service.ClientConfig{
+		Timeout:          cfg.POKEMON_API_TIMEOUT,
+		MaxRetries:       cfg.POKEMON_API_MAX_RETRIES,
+		BackoffBase:      cfg.POKEMON_API_BACKOFF_BASE,
+		BackoffMax:       cfg.POKEMON_API_BACKOFF_MAX,
+		BreakerThreshold: cfg.POKEMON_API_BREAKER_THRESHOLD,
+		BreakerCooldown:  cfg.POKEMON_API_BREAKER_COOLDOWN,
+		CacheDir:         cfg.POKEMON_API_CACHE_DIR,
+	})
+	// Shared by every filter and search, so concurrent requests cannot start workers without bounds
+	var budget *workerpool.Budget
+	if cfg.WORKER_POOL_CAPACITY > 0 {
+		budget = workerpool.NewBudget(cfg.WORKER_POOL_CAPACITY, cfg.WORKER_POOL_MAX_PER_REQUEST, cfg.WORKER_POOL_QUEUE_SIZE, cfg.WORKER_POOL_QUEUE_TIMEOUT)
+	}
+	usecase, err := usecase.New(db, cfg.CSV_FILENAME, cfg.CSV_BACKUP, service, budget)
 	if err != nil {
 		log.Fatal("Error starting up database" + err.Error())
 	}
 
-	controller := controller.New(usecase)
+	controller := controller.New(usecase, poolDefaults(cfg))
+
+	// Keep the pokemons up to date in the background
+	// The reloader changes its own copy of the configuration, so reloads never race the reads below
+	running := *cfg
+	reloader := &reloader{
+		configDir:   ".",
+		args:        os.Args[1:],
+		cfg:         &running,
+		csvFilename: cfg.CSV_FILENAME,
+		usecase:     usecase,
+		controller:  controller,
+	}
+	if err := reloader.startRefresh(); err != nil {
+		log.Fatal("Error starting the scheduled refresh: " + err.Error())
+	}
+	// Shut down on Ctrl+C or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Apply changes to app.env and the csv file without a restart
+	watch(ctx, filepath.Join(reloader.configDir, "app.env"), reloader.reloadConfig)
+	watch(ctx, cfg.CSV_FILENAME, reloader.reloadCsv)
 
 	// Configure router
 	router := gin.Default()
+	router.Use(controller.Metrics, controller.RequestID)
 	router.GET("/pokemons", controller.GetAllPokemons)
 	router.GET("/pokemons/:id", controller.GetPokemonById)
+	router.POST("/pokemons", controller.CreatePokemon)
+	router.PUT("/pokemons/:id", controller.UpdatePokemon)
+	router.PATCH("/pokemons/:id", controller.PatchPokemon)
+	router.DELETE("/pokemons/:id", controller.DeletePokemon)
 	router.GET("/pokemons/fetch", controller.FetchPokemonsFromApi)
+	router.POST("/pokemons/import", controller.ImportPokemons)
+	router.GET("/pokemons/export", controller.ExportPokemons)
 	router.GET("/pokemons/filter", controller.FilterPokemonsConcurrently)
+	router.GET("/pokemons/search", controller.SearchPokemonsConcurrently)
+	router.GET("/refresh/status", controller.GetRefreshStatus)
+	router.GET("/upstream/status", controller.GetUpstreamStatus)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	// Start server
-	router.Run("localhost:8082")
+	server := &http.Server{
+		Addr:              net.JoinHostPort(cfg.SERVER_HOST, strconv.Itoa(cfg.SERVER_PORT)),
+		Handler:           router,
+		ReadHeaderTimeout: cfg.SERVER_READ_HEADER_TIMEOUT,
+		ReadTimeout:       cfg.SERVER_READ_TIMEOUT,
+		WriteTimeout:      cfg.SERVER_WRITE_TIMEOUT,
+		IdleTimeout:       cfg.SERVER_IDLE_TIMEOUT,
+		MaxHeaderBytes:    cfg.SERVER_MAX_HEADER_BYTES,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	log.Println("Listening on " + server.Addr)
+
+	select {
+	case err := <-serverErr:
+		log.Fatal("Error running server: " + err.Error())
+	case <-ctx.Done():
+	}
+
+	// A second signal kills the app right away
+	stop()
+	log.Printf("Shutting down, waiting up to %s for in-flight work", cfg.SERVER_SHUTDOWN_GRACE_PERIOD)
+	if err := shutdown(server, usecase, reloader, cfg.SERVER_SHUTDOWN_GRACE_PERIOD); err != nil {
+		log.Println("Shutdown grace period exceeded: " + err.Error())
+		db.Close()
+		os.Exit(1)
+	}
+	log.Println("Shut down cleanly")
 }
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry - Every metric of the app, along with the Go runtime and process ones
+var Registry = prometheus.NewRegistry()
+
+// HTTP server
+var (
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time to serve HTTP requests, streams included, by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	RequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests being served.",
+	})
+)
+
+// Worker pools running filters and searches
+var (
+	PoolActiveWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "workerpool_active_workers",
+		Help: "Workers running, across every pool.",
+	})
+	PoolQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "workerpool_queue_depth",
+		Help: "Pokemons scheduled but not yet picked by a worker, across every pool.",
+	})
+	PoolItemsFound = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "workerpool_items_found_total",
+		Help: "Pokemons found by worker pools.",
+	})
+	PoolRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "workerpool_runs_total",
+		Help: "Worker pools run, by the reason they stopped.",
+	}, []string{"reason"})
+	BudgetQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "workerpool_budget_queue_depth",
+		Help: "Pools waiting for the worker budget to have room for them.",
+	})
+)
+
+// Pokemon API client
+var (
+	UpstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_requests_total",
+		Help: "Requests to the pokemon API by status code, 'error' when there was no response and 'circuit_open' when the breaker refused them.",
+	}, []string{"status"})
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upstream_request_duration_seconds",
+		Help:    "Time to get the response headers of the pokemon API, by status code or 'error'.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"status"})
+)
+
+// Storage
+var (
+	PersistDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csv_persist_duration_seconds",
+		Help:    "Time to write the pokemons into the csv file, by outcome: success or failure.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+	RepositorySize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "repository_pokemons",
+		Help: "Pokemons in the repository.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration, RequestsInFlight,
+		PoolActiveWorkers, PoolQueueDepth, PoolItemsFound, PoolRuns, BudgetQueueDepth,
+		UpstreamRequests, UpstreamDuration,
+		PersistDuration, RepositorySize,
+	)
+}
+
+// Handler - Serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
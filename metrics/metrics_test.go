@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandler - Validates every metric is exposed, along with the Go runtime ones
+func TestHandler(t *testing.T) {
+	RequestDuration.WithLabelValues("/pokemons", "GET", "200").Observe(0.1)
+	PoolRuns.WithLabelValues("eof").Inc()
+	UpstreamRequests.WithLabelValues("200").Inc()
+	UpstreamDuration.WithLabelValues("200").Observe(0.2)
+	PersistDuration.WithLabelValues("success").Observe(0.01)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(t, 200, w.Code)
+	for _, name := range []string{
+		"go_goroutines",
+		`http_request_duration_seconds_bucket{method="GET",route="/pokemons",status="200",le="0.25"}`,
+		"http_requests_in_flight 0",
+		"workerpool_active_workers 0",
+		"workerpool_queue_depth 0",
+		"workerpool_items_found_total",
+		`workerpool_runs_total{reason="eof"}`,
+		"workerpool_budget_queue_depth 0",
+		`upstream_requests_total{status="200"}`,
+		`upstream_request_duration_seconds_count{status="200"}`,
+		`csv_persist_duration_seconds_count{outcome="success"}`,
+		"repository_pokemons 0",
+	} {
+		assert.Contains(t, string(body), name)
+	}
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of failure every layer reports, so the controller can tell them apart with errors.Is
+// whatever the error message says
+var (
+	ErrValidation          = errors.New("validation error")
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrPersistence         = errors.New("persistence error")
+	ErrTooManyRequests     = errors.New("too many requests") // Try again later, there is no room to even wait
+	ErrOverloaded          = errors.New("overloaded")        // Try again later, waiting took too long
+	ErrShuttingDown        = errors.New("shutting down")     // Try again later, the app is on its way out
+)
+
+// Errors of the single pokemon operations
+var (
+	ErrInvalidPokemon  = Errorf(ErrValidation, "invalid pokemon")
+	ErrPokemonExists   = Errorf(ErrConflict, "pokemon already exists")
+	ErrPokemonNotFound = Errorf(ErrNotFound, "pokemon not found")
+)
+
+// Error - Error of a given kind, keeping its own message and cause
+type Error struct {
+	Kind error // One of the kinds above
+	err  error
+}
+
+// Errorf - Builds an error of the given kind. As with fmt.Errorf, %w wraps the cause
+func Errorf(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+// Error - The message, without the kind
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap - The cause, if any
+func (e *Error) Unwrap() error {
+	return errors.Unwrap(e.err)
+}
+
+// Is - Tells whether the error is of the given kind
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestErrorf - Validates errors keep their message while telling their kind and cause
+func TestErrorf(t *testing.T) {
+	cause := errors.New("connection refused")
+
+	testCases := []struct {
+		name            string
+		err             error
+		expectedMessage string
+		is              []error
+		isNot           []error
+	}{
+		{
+			name:            "plain error of a kind",
+			err:             Errorf(ErrNotFound, "pokemon with %d not found", 4),
+			expectedMessage: "pokemon with 4 not found",
+			is:              []error{ErrNotFound},
+			isNot:           []error{ErrValidation, ErrPokemonNotFound},
+		},
+		{
+			name:            "error wrapping a cause",
+			err:             Errorf(ErrUpstreamUnavailable, "fetching pokemons: %w", cause),
+			expectedMessage: "fetching pokemons: connection refused",
+			is:              []error{ErrUpstreamUnavailable, cause},
+			isNot:           []error{ErrPersistence},
+		},
+		{
+			name:            "wrapped pokemon error",
+			err:             fmt.Errorf("%w: id 4", ErrPokemonNotFound),
+			expectedMessage: "pokemon not found: id 4",
+			is:              []error{ErrPokemonNotFound, ErrNotFound},
+			isNot:           []error{ErrPokemonExists, ErrConflict},
+		},
+		{
+			name:            "any error can be a kind",
+			err:             Errorf(context.DeadlineExceeded, "timed out"),
+			expectedMessage: "timed out",
+			is:              []error{context.DeadlineExceeded},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.EqualError(t, tc.err, tc.expectedMessage)
+			for _, target := range tc.is {
+				assert.ErrorIs(t, tc.err, target)
+			}
+			for _, target := range tc.isNot {
+				assert.False(t, errors.Is(tc.err, target))
+			}
+		})
+	}
+}
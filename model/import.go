@@ -0,0 +1,15 @@
+package model
+
+// ImportIssue - Reason a CSV row could not be imported
+type ImportIssue struct {
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport - Outcome of a CSV import
+type ImportReport struct {
+	Imported int           `json:"imported"`
+	Skipped  int           `json:"skipped"`
+	Issues   []ImportIssue `json:"issues"`
+}
@@ -4,8 +4,20 @@ import "fmt"
 
 // Pokemon - General information about a Pokemon
 type Pokemon struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID             int      `json:"id"`
+	Name           string   `json:"name"`
+	Types          []string `json:"types,omitempty"`
+	Stats          []Stat   `json:"stats,omitempty"`
+	Height         int      `json:"height,omitempty"`          // In decimetres
+	Weight         int      `json:"weight,omitempty"`          // In hectograms
+	BaseExperience int      `json:"base_experience,omitempty"` // Experience gained by defeating this pokemon
+	Abilities      []string `json:"abilities,omitempty"`
+}
+
+// Stat - Base value of a single Pokemon stat (hp, attack, defense...)
+type Stat struct {
+	Name     string `json:"name"`
+	BaseStat int    `json:"base_stat"`
 }
 
 // IsEven - Identifies whether a pokemon is ever or odd
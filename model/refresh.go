@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// What started a refresh
+const (
+	ManualRefresh    = "manual"
+	ScheduledRefresh = "scheduled"
+)
+
+// RefreshStatus - State of the refreshes of the pokemons from the API, manual or scheduled
+type RefreshStatus struct {
+	Schedule string      `json:"schedule,omitempty"` // Empty when refreshes are only manual
+	Running  bool        `json:"running"`
+	LastRun  *RefreshRun `json:"last_run,omitempty"`
+	NextRun  *time.Time  `json:"next_run,omitempty"` // Missing while a scheduled refresh runs, or without a schedule
+}
+
+// RefreshRun - How a refresh went. Dry runs change nothing, so they are not recorded
+type RefreshRun struct {
+	Trigger   string      `json:"trigger"` // manual or scheduled
+	Mode      string      `json:"mode"`
+	StartedAt time.Time   `json:"started_at"`
+	Duration  string      `json:"duration"`
+	Outcome   string      `json:"outcome"` // success or failure
+	Error     string      `json:"error,omitempty"`
+	Report    *SyncReport `json:"report,omitempty"`
+}
@@ -0,0 +1,11 @@
+package model
+
+// SyncReport - Ids touched by syncing the local pokemons with the ones fetched from the API, sorted
+type SyncReport struct {
+	Mode      string `json:"mode"`
+	DryRun    bool   `json:"dry_run"` // Whether the diff is only a preview, nothing was changed
+	Added     []int  `json:"added"`
+	Updated   []int  `json:"updated"`
+	Unchanged []int  `json:"unchanged"`
+	Removed   []int  `json:"removed"`
+}
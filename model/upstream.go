@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// UpstreamStatus - Health of the external API as seen by its client circuit breaker
+type UpstreamStatus struct {
+	State               string     `json:"state"` // closed, open or half-open
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"` // When an open breaker lets calls through again
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"rincon-orlando/go-bootcamp/config"
+	"rincon-orlando/go-bootcamp/controller"
+	"rincon-orlando/go-bootcamp/scheduler"
+	"rincon-orlando/go-bootcamp/usecase"
+	"rincon-orlando/go-bootcamp/util/enum"
+	"rincon-orlando/go-bootcamp/watcher"
+)
+
+// How long a file has to go quiet before reloading it, so a single save reloads it once
+const reloadDelay = 100 * time.Millisecond
+
+// Applies the changes to app.env and the csv file while serving
+type reloader struct {
+	configDir   string
+	args        []string
+	cfg         *config.Config
+	csvFilename string
+	usecase     *usecase.UseCase
+	controller  controller.Controller
+
+	mu          sync.Mutex         // Serializes config reloads with stop
+	stopped     bool               // Set once the app shuts down, changes are not applied anymore
+	stopRefresh context.CancelFunc // Stops the scheduled refresh. nil when there is none
+}
+
+// Watches the file at path until ctx is done, calling reload on every change
+func watch(ctx context.Context, path string, reload func()) {
+	w, err := watcher.New(path, reloadDelay)
+	if err != nil {
+		log.Println("Cannot watch " + path + " for changes: " + err.Error())
+		return
+	}
+	go w.Run(ctx, reload)
+}
+
+// Loads app.env again and applies the settings that may change while serving
+// An invalid configuration is rejected as a whole, the current one stays in place
+func (r *reloader) reloadConfig() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+
+	next, err := config.New(r.configDir, r.args)
+	if err != nil {
+		log.Println("Rejected app.env change, keeping the current configuration: " + err.Error())
+		return
+	}
+	applied, restart, err := r.cfg.Reload(next)
+	if err != nil {
+		log.Println("Rejected app.env change, keeping the current configuration: " + err.Error())
+		return
+	}
+	r.apply(applied, restart)
+}
+
+func (r *reloader) apply(applied []string, restart []string) {
+	if len(restart) > 0 {
+		log.Println("Reloaded app.env. Restart to apply " + strings.Join(restart, ", "))
+	}
+	if len(applied) == 0 {
+		if len(restart) == 0 {
+			log.Println("Reloaded app.env, nothing changed")
+		}
+		return
+	}
+
+	r.controller.SetPoolDefaults(poolDefaults(r.cfg))
+	for _, key := range applied {
+		if strings.HasPrefix(key, "REFRESH_") {
+			if err := r.startRefresh(); err != nil {
+				log.Println("Error restarting the scheduled refresh: " + err.Error())
+			}
+			break
+		}
+	}
+	log.Println("Reloaded app.env. Applied " + strings.Join(applied, ", "))
+}
+
+// Replaces the pokemons being served with the csv file contents. A bad file is rejected, the pokemons stay as they are
+func (r *reloader) reloadCsv() {
+	reloaded, err := r.usecase.ReloadCsv()
+	if err != nil {
+		log.Println("Rejected " + r.csvFilename + " change, still serving the previous pokemons: " + err.Error())
+		return
+	}
+	if !reloaded {
+		return
+	}
+	pokemons, err := r.usecase.GetAllPokemons()
+	if err != nil {
+		log.Println("Reloaded " + r.csvFilename + ", but the pokemons cannot be read back: " + err.Error())
+		return
+	}
+	log.Printf("Reloaded %d pokemons from %s", len(pokemons), r.csvFilename)
+}
+
+// Stops the scheduled refresh, and applying config changes
+func (r *reloader) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	if r.stopRefresh != nil {
+		r.stopRefresh()
+		r.stopRefresh = nil
+	}
+}
+
+// Runs the scheduled refresh the configuration asks for, if any, stopping the previous one
+// Called on startup, and with mu held afterwards
+func (r *reloader) startRefresh() error {
+	if r.stopRefresh != nil {
+		r.stopRefresh()
+		r.stopRefresh = nil
+	}
+	if r.cfg.REFRESH_SCHEDULE == "" {
+		return nil
+	}
+
+	schedule, err := scheduler.Parse(r.cfg.REFRESH_SCHEDULE)
+	if err != nil {
+		return err
+	}
+	mode, err := enum.ParseSyncMode(r.cfg.REFRESH_MODE)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stopRefresh = cancel
+	go r.usecase.RunScheduledRefresh(ctx, r.cfg.REFRESH_SCHEDULE, schedule, mode)
+	return nil
+}
+
+func poolDefaults(cfg *config.Config) controller.PoolDefaults {
+	return controller.PoolDefaults{
+		Workers:        cfg.DEFAULT_FILTER_NUM_WORKERS,
+		MaxWorkers:     cfg.MaxWorkersPerRequest(),
+		Items:          cfg.DEFAULT_FILTER_ITEMS,
+		ItemsPerWorker: cfg.DEFAULT_FILTER_ITEMS_PER_WORKER,
+	}
+}
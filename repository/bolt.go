@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket holding every pokemon as JSON, keyed by its id
+var pokemonsBucket = []byte("pokemons")
+
+// Bolt - Pokemon repository backed by an embedded bbolt key/value file
+type Bolt struct {
+	db *bolt.DB
+}
+
+// NewBolt - Bolt factory, opening the database file at path and creating its bucket if needed
+func NewBolt(path string) (*Bolt, error) {
+	// bbolt locks the file, fail instead of hanging if another process holds it
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(pokemonsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Bolt{db}, nil
+}
+
+// GetAllPokemons - Returns a slice of all Pokemons available in this repository, sorted by ID
+func (b *Bolt) GetAllPokemons() ([]model.Pokemon, error) {
+	v := []model.Pokemon{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		// Keys are big endian ids, so the cursor walks them in id order
+		return tx.Bucket(pokemonsBucket).ForEach(func(_, value []byte) error {
+			var pokemon model.Pokemon
+			if err := json.Unmarshal(value, &pokemon); err != nil {
+				return err
+			}
+			v = append(v, pokemon)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// GetPokemonById - Returns a pokemon given its id
+func (b *Bolt) GetPokemonById(id int) (*model.Pokemon, error) {
+	var pokemon *model.Pokemon
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(pokemonsBucket).Get(boltKey(id))
+		if value == nil {
+			return errNotFound(id)
+		}
+		pokemon = &model.Pokemon{}
+		return json.Unmarshal(value, pokemon)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pokemon, nil
+}
+
+// SetPokemons - Replaces every pokemon at once, in a single transaction
+func (b *Bolt) SetPokemons(pokemons []model.Pokemon) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(pokemonsBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(pokemonsBucket)
+		if err != nil {
+			return err
+		}
+		for _, pokemon := range pokemons {
+			if err := putPokemon(bucket, pokemon); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreatePokemon - Adds a new pokemon, failing if its id is already taken
+func (b *Bolt) CreatePokemon(pokemon model.Pokemon) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pokemonsBucket)
+		if bucket.Get(boltKey(pokemon.ID)) != nil {
+			return errExists(pokemon.ID)
+		}
+		return putPokemon(bucket, pokemon)
+	})
+}
+
+// UpdatePokemon - Replaces an existing pokemon, matching it by id
+func (b *Bolt) UpdatePokemon(pokemon model.Pokemon) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pokemonsBucket)
+		if bucket.Get(boltKey(pokemon.ID)) == nil {
+			return errNotFound(pokemon.ID)
+		}
+		return putPokemon(bucket, pokemon)
+	})
+}
+
+// DeletePokemon - Removes a pokemon given its id
+func (b *Bolt) DeletePokemon(id int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pokemonsBucket)
+		if bucket.Get(boltKey(id)) == nil {
+			return errNotFound(id)
+		}
+		return bucket.Delete(boltKey(id))
+	})
+}
+
+// Close - Closes the database file
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// Stores a pokemon as JSON under its id
+func putPokemon(bucket *bolt.Bucket, pokemon model.Pokemon) error {
+	value, err := json.Marshal(pokemon)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(boltKey(pokemon.ID), value)
+}
+
+// Big endian keys sort the same as the ids they hold
+// The sign bit is flipped, otherwise negative ids would come after every positive one
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id)^1<<63)
+	return key
+}
@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Every backend must behave exactly like the others, so they all run the same suite
+var backends = []struct {
+	name    string
+	backend enum.Backend
+	durable bool // Whether pokemons survive closing and opening the store again
+}{
+	{name: "memory", backend: enum.MemoryBackend},
+	{name: "sqlite", backend: enum.SQLiteBackend, durable: true},
+	{name: "bolt", backend: enum.BoltBackend, durable: true},
+}
+
+var fullPokemon = model.Pokemon{
+	ID:             25,
+	Name:           "pikachu",
+	Types:          []string{"electric"},
+	Stats:          []model.Stat{{Name: "hp", BaseStat: 35}, {Name: "speed", BaseStat: 90}},
+	Height:         4,
+	Weight:         60,
+	BaseExperience: 112,
+	Abilities:      []string{"static", "lightning-rod"},
+}
+
+// Opens a fresh store of the given backend, closed once the test is over
+func openStore(t *testing.T, backend enum.Backend, path string) Store {
+	store, err := Open(backend, path)
+	require.Nil(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// Returns every pokemon in the store, failing the test if they cannot be read
+func allPokemons(t *testing.T, store Store) []model.Pokemon {
+	all, err := store.GetAllPokemons()
+	assert.Nil(t, err)
+	return all
+}
+
+// TestStore_Conformance - Runs the same operations against every backend
+func TestStore_Conformance(t *testing.T) {
+	testCases := []struct {
+		name string
+		run  func(t *testing.T, store Store)
+	}{
+		{
+			name: "starts empty",
+			run: func(t *testing.T, store Store) {
+				assert.Empty(t, allPokemons(t, store))
+				_, err := store.GetPokemonById(1)
+				assert.EqualError(t, err, "pokemon with 1 not found")
+			},
+		},
+		{
+			name: "set and get all sorted by id",
+			run: func(t *testing.T, store Store) {
+				shuffled := []model.Pokemon{pokemons[4], pokemons[1], pokemons[3], pokemons[0], pokemons[2]}
+				require.Nil(t, store.SetPokemons(shuffled))
+				assert.Equal(t, pokemons, allPokemons(t, store))
+			},
+		},
+		{
+			name: "sorted by id across signs",
+			run: func(t *testing.T, store Store) {
+				// Ids coming from the csv file or the API are not validated, so they may be anything
+				signed := []model.Pokemon{{ID: 1, Name: "a"}, {ID: -1, Name: "b"}, {ID: math.MaxInt64, Name: "c"}, {ID: 0, Name: "d"}, {ID: math.MinInt64, Name: "e"}, {ID: -300, Name: "f"}, {ID: 256, Name: "g"}}
+				require.Nil(t, store.SetPokemons(signed))
+				expected := []model.Pokemon{signed[4], signed[5], signed[1], signed[3], signed[0], signed[6], signed[2]}
+				assert.Equal(t, expected, allPokemons(t, store))
+				for _, pokemon := range signed {
+					stored, err := store.GetPokemonById(pokemon.ID)
+					require.Nil(t, err)
+					assert.Equal(t, pokemon, *stored)
+				}
+			},
+		},
+		{
+			name: "set replaces every pokemon",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+				require.Nil(t, store.SetPokemons(pokemons[:2]))
+				assert.Equal(t, pokemons[:2], allPokemons(t, store))
+			},
+		},
+		{
+			name: "keeps every field",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons([]model.Pokemon{fullPokemon}))
+				pokemon, err := store.GetPokemonById(fullPokemon.ID)
+				require.Nil(t, err)
+				assert.Equal(t, fullPokemon, *pokemon)
+			},
+		},
+		{
+			name: "create",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+				assert.Nil(t, store.CreatePokemon(fullPokemon))
+				pokemon, err := store.GetPokemonById(fullPokemon.ID)
+				require.Nil(t, err)
+				assert.Equal(t, fullPokemon, *pokemon)
+				assert.Len(t, allPokemons(t, store), len(pokemons)+1)
+			},
+		},
+		{
+			name: "create taken id",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+				err := store.CreatePokemon(model.Pokemon{ID: 1, Name: "Geodude"})
+				assert.EqualError(t, err, "pokemon with 1 already exists")
+				pokemon, _ := store.GetPokemonById(1)
+				assert.Equal(t, pokemons[0], *pokemon)
+			},
+		},
+		{
+			name: "update",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+				updated := model.Pokemon{ID: 1, Name: "Steelix", Types: []string{"steel", "ground"}}
+				assert.Nil(t, store.UpdatePokemon(updated))
+				pokemon, _ := store.GetPokemonById(1)
+				assert.Equal(t, updated, *pokemon)
+			},
+		},
+		{
+			name: "update unexisting pokemon",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+				err := store.UpdatePokemon(model.Pokemon{ID: 3, Name: "Venusaur"})
+				assert.EqualError(t, err, "pokemon with 3 not found")
+				assert.Equal(t, pokemons, allPokemons(t, store))
+			},
+		},
+		{
+			name: "delete",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+				assert.Nil(t, store.DeletePokemon(5))
+				_, err := store.GetPokemonById(5)
+				assert.EqualError(t, err, "pokemon with 5 not found")
+				assert.Len(t, allPokemons(t, store), len(pokemons)-1)
+			},
+		},
+		{
+			name: "delete unexisting pokemon",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+				assert.EqualError(t, store.DeletePokemon(3), "pokemon with 3 not found")
+				assert.Equal(t, pokemons, allPokemons(t, store))
+			},
+		},
+		{
+			name: "reads during writes",
+			run: func(t *testing.T, store Store) {
+				require.Nil(t, store.SetPokemons(pokemons))
+
+				done := make(chan struct{})
+				var wg sync.WaitGroup
+				for i := 0; i < 4; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for {
+							select {
+							case <-done:
+								return
+							default:
+							}
+							// Writers only ever add and remove the same pokemon
+							all := allPokemons(t, store)
+							assert.True(t, len(all) == len(pokemons) || len(all) == len(pokemons)+1)
+							_, err := store.GetPokemonById(1)
+							assert.Nil(t, err)
+						}
+					}()
+				}
+
+				for i := 0; i < 20; i++ {
+					assert.Nil(t, store.CreatePokemon(fullPokemon))
+					assert.Nil(t, store.UpdatePokemon(fullPokemon))
+					assert.Nil(t, store.DeletePokemon(fullPokemon.ID))
+				}
+				close(done)
+				wg.Wait()
+
+				assert.Equal(t, pokemons, allPokemons(t, store))
+			},
+		},
+	}
+
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					store := openStore(t, b.backend, filepath.Join(t.TempDir(), "pokemons.db"))
+					tc.run(t, store)
+				})
+			}
+		})
+	}
+}
+
+// TestStore_Reopen - Checks durable backends keep their pokemons across restarts
+func TestStore_Reopen(t *testing.T) {
+	for _, b := range backends {
+		if !b.durable {
+			continue
+		}
+		t.Run(b.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pokemons.db")
+
+			store, err := Open(b.backend, path)
+			require.Nil(t, err)
+			require.Nil(t, store.SetPokemons(pokemons))
+			require.Nil(t, store.CreatePokemon(fullPokemon))
+			require.Nil(t, store.Close())
+
+			reopened := openStore(t, b.backend, path)
+			// Pikachu goes right before Snorlax, the last one
+			expected := []model.Pokemon{pokemons[0], pokemons[1], pokemons[2], pokemons[3], fullPokemon, pokemons[4]}
+			assert.Equal(t, expected, allPokemons(t, reopened))
+		})
+	}
+}
+
+// TestStore_Open - Validates unknown backends are refused
+func TestStore_Open(t *testing.T) {
+	store, err := Open(enum.UndefinedBackend, "")
+	assert.Nil(t, store)
+	assert.EqualError(t, err, "unknown storage backend 0")
+}
+
+// TestStore_ReadError - Checks durable backends report read failures, instead of passing for an empty store
+func TestStore_ReadError(t *testing.T) {
+	for _, b := range backends {
+		if !b.durable {
+			continue
+		}
+		t.Run(b.name, func(t *testing.T) {
+			store, err := Open(b.backend, filepath.Join(t.TempDir(), "pokemons.db"))
+			require.Nil(t, err)
+			require.Nil(t, store.SetPokemons(pokemons))
+			require.Nil(t, store.Close())
+
+			all, err := store.GetAllPokemons()
+			assert.NotNil(t, err)
+			assert.Nil(t, all)
+		})
+	}
+}
@@ -1,45 +1,140 @@
 package repository
 
 import (
-	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 
 	"rincon-orlando/go-bootcamp/model"
 )
 
 // DB - Definition of a Pokemon repository, simulating a Database
+// Safe for concurrent use: readers load an immutable snapshot without locking,
+// writers build a new snapshot out of a copy of the current one and swap it in
 type DB struct {
+	mu       sync.Mutex   // Serializes writers so none of them loses the changes of another
+	snapshot atomic.Value // Holds a *snapshot, never modified once stored
+}
+
+// Immutable view of the repository contents
+type snapshot struct {
 	pokeMap map[int]model.Pokemon
+	sorted  []model.Pokemon // Same pokemons as pokeMap, sorted by ID
 }
 
 // New - DB factory
-func New() DB {
-	return DB{}
+func New() *DB {
+	db := &DB{}
+	db.snapshot.Store(newSnapshot(map[int]model.Pokemon{}))
+	return db
+}
+
+// Builds a snapshot owning the given map, which must not be modified afterwards
+func newSnapshot(pokeMap map[int]model.Pokemon) *snapshot {
+	sorted := make([]model.Pokemon, 0, len(pokeMap))
+	for _, value := range pokeMap {
+		sorted = append(sorted, value)
+	}
+	// Map iteration order is random, keep the output stable
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	return &snapshot{pokeMap: pokeMap, sorted: sorted}
 }
 
-// GetAllPokemons - Returns a slice of all Pokemons available in this repository
-func (db DB) GetAllPokemons() []model.Pokemon {
-	// Convert map to array so we do not give unnecessary keys as output
-	v := make([]model.Pokemon, 0, len(db.pokeMap))
-	for _, value := range db.pokeMap {
-		v = append(v, value)
+// Returns the current snapshot, an empty one for a zero value DB
+func (db *DB) load() *snapshot {
+	if s, ok := db.snapshot.Load().(*snapshot); ok {
+		return s
 	}
-	return v
+	return &snapshot{pokeMap: map[int]model.Pokemon{}}
+}
+
+// Runs change over a copy of the current pokemons and publishes the result, unless change fails
+func (db *DB) write(change func(pokeMap map[int]model.Pokemon) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	current := db.load().pokeMap
+	pokeMap := make(map[int]model.Pokemon, len(current))
+	for id, pokemon := range current {
+		pokeMap[id] = pokemon
+	}
+	if err := change(pokeMap); err != nil {
+		return err
+	}
+
+	db.snapshot.Store(newSnapshot(pokeMap))
+	return nil
+}
+
+// GetAllPokemons - Returns a slice of all Pokemons available in this repository, sorted by ID
+// Never fails, the error is there to comply with Store
+func (db *DB) GetAllPokemons() ([]model.Pokemon, error) {
+	// Callers are free to sort the result, so never hand out the snapshot slice itself
+	sorted := db.load().sorted
+	v := make([]model.Pokemon, len(sorted))
+	copy(v, sorted)
+	return v, nil
 }
 
 // GetPokemonById - Returns a pokemon given its id
-func (db DB) GetPokemonById(id int) (*model.Pokemon, error) {
-	if val, ok := db.pokeMap[id]; ok {
+func (db *DB) GetPokemonById(id int) (*model.Pokemon, error) {
+	if val, ok := db.load().pokeMap[id]; ok {
 		return &val, nil
 	}
 
-	return nil, fmt.Errorf("pokemon with %d not found", id)
+	return nil, errNotFound(id)
 }
 
-// SetPokemons - Build a pokemon map out of the pokemon slice
-// Pointer as receiver so internal db.data can be modified
-func (db *DB) SetPokemons(pokemons []model.Pokemon) {
-	db.pokeMap = make(map[int]model.Pokemon)
+// SetPokemons - Build a pokemon map out of the pokemon slice, replacing every pokemon at once
+// Never fails, the error is there to comply with Store
+func (db *DB) SetPokemons(pokemons []model.Pokemon) error {
+	pokeMap := make(map[int]model.Pokemon, len(pokemons))
 	for _, pokemon := range pokemons {
-		db.pokeMap[pokemon.ID] = pokemon
+		pokeMap[pokemon.ID] = pokemon
 	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.snapshot.Store(newSnapshot(pokeMap))
+	return nil
+}
+
+// CreatePokemon - Adds a new pokemon, failing if its id is already taken
+func (db *DB) CreatePokemon(pokemon model.Pokemon) error {
+	return db.write(func(pokeMap map[int]model.Pokemon) error {
+		if _, ok := pokeMap[pokemon.ID]; ok {
+			return errExists(pokemon.ID)
+		}
+		pokeMap[pokemon.ID] = pokemon
+		return nil
+	})
+}
+
+// UpdatePokemon - Replaces an existing pokemon, matching it by id
+func (db *DB) UpdatePokemon(pokemon model.Pokemon) error {
+	return db.write(func(pokeMap map[int]model.Pokemon) error {
+		if _, ok := pokeMap[pokemon.ID]; !ok {
+			return errNotFound(pokemon.ID)
+		}
+		pokeMap[pokemon.ID] = pokemon
+		return nil
+	})
+}
+
+// DeletePokemon - Removes a pokemon given its id
+func (db *DB) DeletePokemon(id int) error {
+	return db.write(func(pokeMap map[int]model.Pokemon) error {
+		if _, ok := pokeMap[id]; !ok {
+			return errNotFound(id)
+		}
+		delete(pokeMap, id)
+		return nil
+	})
+}
+
+// Close - Nothing to release for an in-memory DB
+func (db *DB) Close() error {
+	return nil
 }
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"errors"
+	"sort"
+	"sync"
 	"testing"
 
 	"rincon-orlando/go-bootcamp/model"
@@ -30,7 +32,7 @@ func TestDB_New(t *testing.T) {
 	// There is really one test to execute
 	db := New()
 	for range testCases {
-		assert.Zero(t, len(db.pokeMap))
+		assert.Zero(t, len(db.load().pokeMap))
 	}
 }
 
@@ -71,8 +73,8 @@ func TestDB_SetAllPokemons(t *testing.T) {
 	db := New()
 	db.SetPokemons(pokemons)
 	for _, tc := range testCases {
-		assert.Contains(t, db.pokeMap, tc.expectedId)
-		assert.EqualValues(t, tc.expectedPokemon, db.pokeMap[tc.expectedId])
+		assert.Contains(t, db.load().pokeMap, tc.expectedId)
+		assert.EqualValues(t, tc.expectedPokemon, db.load().pokeMap[tc.expectedId])
 	}
 }
 
@@ -98,7 +100,26 @@ func TestDB_GetAllPokemons(t *testing.T) {
 	db := New()
 	for _, tc := range testCases {
 		db.SetPokemons(tc.givenPokemons)
-		assert.Equal(t, tc.expectedLength, len(db.GetAllPokemons()))
+		assert.Equal(t, tc.expectedLength, len(allPokemons(t, db)))
+	}
+}
+
+// TestDB_GetAllPokemons_Order - Test pokemons are always returned sorted by id
+func TestDB_GetAllPokemons_Order(t *testing.T) {
+	db := New()
+	db.SetPokemons([]model.Pokemon{
+		{ID: 100, Name: "Snorlax"},
+		{ID: 5, Name: "Pikachu"},
+		{ID: 11, Name: "Bulbasur"},
+		{ID: 1, Name: "Onix"},
+	})
+
+	for i := 0; i < 10; i++ {
+		ids := make([]int, 0)
+		for _, p := range allPokemons(t, db) {
+			ids = append(ids, p.ID)
+		}
+		assert.Equal(t, []int{1, 5, 11, 100}, ids)
 	}
 }
 
@@ -138,3 +159,178 @@ func TestDB_GetPokemonById(t *testing.T) {
 		}
 	}
 }
+
+// TestDB_CreatePokemon - Test adding pokemons, rejecting taken ids
+func TestDB_CreatePokemon(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pokemon  model.Pokemon
+		hasError bool
+		error    error
+	}{
+		{
+			name:     "create new pokemon",
+			pokemon:  model.Pokemon{ID: 3, Name: "Venusaur"},
+			hasError: false,
+			error:    nil,
+		},
+		{
+			name:     "create taken id",
+			pokemon:  model.Pokemon{ID: 1, Name: "Venusaur"},
+			hasError: true,
+			error:    errors.New("pokemon with 1 already exists"),
+		},
+	}
+
+	for _, tc := range testCases {
+		db := New()
+		db.SetPokemons(pokemons)
+		err := db.CreatePokemon(tc.pokemon)
+		if tc.hasError {
+			assert.EqualError(t, err, tc.error.Error())
+			assert.NotEqual(t, tc.pokemon, db.load().pokeMap[tc.pokemon.ID])
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, tc.pokemon, db.load().pokeMap[tc.pokemon.ID])
+		}
+	}
+
+	// An empty DB takes new pokemons too
+	db := New()
+	assert.Nil(t, db.CreatePokemon(model.Pokemon{ID: 1, Name: "Onix"}))
+	assert.Len(t, db.load().pokeMap, 1)
+}
+
+// TestDB_UpdatePokemon - Test replacing pokemons, rejecting unknown ids
+func TestDB_UpdatePokemon(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pokemon  model.Pokemon
+		hasError bool
+		error    error
+	}{
+		{
+			name:     "update existing pokemon",
+			pokemon:  model.Pokemon{ID: 1, Name: "Steelix"},
+			hasError: false,
+			error:    nil,
+		},
+		{
+			name:     "update unexisting pokemon",
+			pokemon:  model.Pokemon{ID: 3, Name: "Venusaur"},
+			hasError: true,
+			error:    errors.New("pokemon with 3 not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		db := New()
+		db.SetPokemons(pokemons)
+		err := db.UpdatePokemon(tc.pokemon)
+		if tc.hasError {
+			assert.EqualError(t, err, tc.error.Error())
+			assert.NotContains(t, db.load().pokeMap, tc.pokemon.ID)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, tc.pokemon, db.load().pokeMap[tc.pokemon.ID])
+		}
+	}
+}
+
+// TestDB_DeletePokemon - Test removing pokemons, rejecting unknown ids
+func TestDB_DeletePokemon(t *testing.T) {
+	testCases := []struct {
+		name     string
+		id       int
+		hasError bool
+		error    error
+	}{
+		{
+			name:     "delete existing pokemon",
+			id:       1,
+			hasError: false,
+			error:    nil,
+		},
+		{
+			name:     "delete unexisting pokemon",
+			id:       3,
+			hasError: true,
+			error:    errors.New("pokemon with 3 not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		db := New()
+		db.SetPokemons(pokemons)
+		err := db.DeletePokemon(tc.id)
+		if tc.hasError {
+			assert.EqualError(t, err, tc.error.Error())
+			assert.Len(t, db.load().pokeMap, len(pokemons))
+		} else {
+			assert.Nil(t, err)
+			assert.NotContains(t, db.load().pokeMap, tc.id)
+		}
+	}
+}
+
+// TestDB_Concurrency - Hammers reads while pokemons are being replaced, created, updated and deleted
+// Meant to be run with -race
+func TestDB_Concurrency(t *testing.T) {
+	db := New()
+	db.SetPokemons(pokemons)
+
+	const readers = 8
+	const writes = 200
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				all := allPokemons(t, db)
+				// A snapshot is never seen half written
+				assert.True(t, len(all) == len(pokemons) || len(all) == len(pokemons)+1)
+				assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool { return all[i].ID < all[j].ID }))
+				// Sorting the result must not affect other readers
+				sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+				db.GetPokemonById(1)
+			}
+		}()
+	}
+
+	for i := 0; i < writes; i++ {
+		db.SetPokemons(pokemons)
+		assert.Nil(t, db.CreatePokemon(model.Pokemon{ID: 1000, Name: "Mew"}))
+		assert.Nil(t, db.UpdatePokemon(model.Pokemon{ID: 1000, Name: "Mew"}))
+		assert.Nil(t, db.DeletePokemon(1000))
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, pokemons, allPokemons(t, db))
+}
+
+// TestDB_ConcurrentWriters - Checks no write is lost when many writers run at once
+func TestDB_ConcurrentWriters(t *testing.T) {
+	db := New()
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			assert.Nil(t, db.CreatePokemon(model.Pokemon{ID: id, Name: "Ditto"}))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, allPokemons(t, db), writers)
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	// Pure Go driver, registered as "sqlite", so no cgo toolchain is needed
+	_ "modernc.org/sqlite"
+)
+
+// Created on open when missing. Multi-valued fields are kept as JSON arrays
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS pokemons (
+	id              INTEGER PRIMARY KEY,
+	name            TEXT    NOT NULL,
+	types           TEXT    NOT NULL DEFAULT '[]',
+	height          INTEGER NOT NULL DEFAULT 0,
+	weight          INTEGER NOT NULL DEFAULT 0,
+	base_experience INTEGER NOT NULL DEFAULT 0,
+	abilities       TEXT    NOT NULL DEFAULT '[]',
+	stats           TEXT    NOT NULL DEFAULT '[]'
+)`
+
+const sqliteColumns = "id, name, types, height, weight, base_experience, abilities, stats"
+
+// SQLite - Pokemon repository backed by an embedded SQLite database file
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite - SQLite factory, opening the database file at path and creating its schema if needed
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serializes writers, so they never fail with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL", "PRAGMA synchronous = NORMAL", sqliteSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &SQLite{db}, nil
+}
+
+// GetAllPokemons - Returns a slice of all Pokemons available in this repository, sorted by ID
+func (s *SQLite) GetAllPokemons() ([]model.Pokemon, error) {
+	rows, err := s.db.Query("SELECT " + sqliteColumns + " FROM pokemons ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	v := []model.Pokemon{}
+	for rows.Next() {
+		pokemon, err := scanPokemon(rows)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, pokemon)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// GetPokemonById - Returns a pokemon given its id
+func (s *SQLite) GetPokemonById(id int) (*model.Pokemon, error) {
+	pokemon, err := scanPokemon(s.db.QueryRow("SELECT "+sqliteColumns+" FROM pokemons WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pokemon, nil
+}
+
+// SetPokemons - Replaces every pokemon at once, in a single transaction
+func (s *SQLite) SetPokemons(pokemons []model.Pokemon) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM pokemons"); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO pokemons (" + sqliteColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, pokemon := range pokemons {
+		args, err := pokemonArgs(pokemon)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreatePokemon - Adds a new pokemon, failing if its id is already taken
+func (s *SQLite) CreatePokemon(pokemon model.Pokemon) error {
+	args, err := pokemonArgs(pokemon)
+	if err != nil {
+		return err
+	}
+
+	// Ignoring the conflict tells it apart from other failures without parsing driver errors
+	res, err := s.db.Exec("INSERT OR IGNORE INTO pokemons ("+sqliteColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)", args...)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, errExists(pokemon.ID))
+}
+
+// UpdatePokemon - Replaces an existing pokemon, matching it by id
+func (s *SQLite) UpdatePokemon(pokemon model.Pokemon) error {
+	args, err := pokemonArgs(pokemon)
+	if err != nil {
+		return err
+	}
+
+	// Same column order as sqliteColumns, with the id moved to the WHERE clause
+	res, err := s.db.Exec("UPDATE pokemons SET name = ?, types = ?, height = ?, weight = ?, base_experience = ?, abilities = ?, stats = ? WHERE id = ?",
+		append(args[1:], args[0])...)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, errNotFound(pokemon.ID))
+}
+
+// DeletePokemon - Removes a pokemon given its id
+func (s *SQLite) DeletePokemon(id int) error {
+	res, err := s.db.Exec("DELETE FROM pokemons WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res, errNotFound(id))
+}
+
+// Close - Closes the database file
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// Returns notAffected when the statement did not touch any row
+func expectOneRow(res sql.Result, notAffected error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notAffected
+	}
+	return nil
+}
+
+// Either a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Reads a pokemon out of a row selecting sqliteColumns
+func scanPokemon(row scanner) (model.Pokemon, error) {
+	var pokemon model.Pokemon
+	var types, abilities, stats string
+	err := row.Scan(&pokemon.ID, &pokemon.Name, &types, &pokemon.Height, &pokemon.Weight, &pokemon.BaseExperience, &abilities, &stats)
+	if err != nil {
+		return model.Pokemon{}, err
+	}
+
+	if err := json.Unmarshal([]byte(types), &pokemon.Types); err != nil {
+		return model.Pokemon{}, err
+	}
+	if err := json.Unmarshal([]byte(abilities), &pokemon.Abilities); err != nil {
+		return model.Pokemon{}, err
+	}
+	if err := json.Unmarshal([]byte(stats), &pokemon.Stats); err != nil {
+		return model.Pokemon{}, err
+	}
+
+	return pokemon, nil
+}
+
+// Query arguments of a pokemon, in sqliteColumns order
+func pokemonArgs(pokemon model.Pokemon) ([]interface{}, error) {
+	types, err := json.Marshal(pokemon.Types)
+	if err != nil {
+		return nil, err
+	}
+	abilities, err := json.Marshal(pokemon.Abilities)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := json.Marshal(pokemon.Stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{pokemon.ID, pokemon.Name, string(types), pokemon.Height, pokemon.Weight, pokemon.BaseExperience, string(abilities), string(stats)}, nil
+}
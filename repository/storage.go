@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"fmt"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/util/enum"
+)
+
+// Store - Operations every storage backend supports
+type Store interface {
+	GetAllPokemons() ([]model.Pokemon, error)
+	GetPokemonById(id int) (*model.Pokemon, error)
+	SetPokemons(pokemons []model.Pokemon) error
+	CreatePokemon(pokemon model.Pokemon) error
+	UpdatePokemon(pokemon model.Pokemon) error
+	DeletePokemon(id int) error
+	Close() error
+}
+
+// Open - Store factory, returning the given backend. path is the database file, unused by the memory backend
+func Open(backend enum.Backend, path string) (Store, error) {
+	switch backend {
+	case enum.MemoryBackend:
+		return New(), nil
+	case enum.SQLiteBackend:
+		return NewSQLite(path)
+	case enum.BoltBackend:
+		return NewBolt(path)
+	}
+
+	return nil, fmt.Errorf("unknown storage backend %d", backend)
+}
+
+// Errors shared by every backend, so they all behave the same for the usecase layer
+func errExists(id int) error {
+	return model.Errorf(model.ErrConflict, "pokemon with %d already exists", id)
+}
+
+func errNotFound(id int) error {
+	return model.Errorf(model.ErrNotFound, "pokemon with %d not found", id)
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule - Tells when a job runs next, given the current time
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+// Runs every interval, counting from the end of the previous run
+type every time.Duration
+
+func (e every) Next(now time.Time) time.Time {
+	return now.Add(time.Duration(e))
+}
+
+// Parse - Takes either a duration (i.e. 30m, 6h) or a standard 5 field cron expression (i.e. "0 */6 * * *", "@daily")
+// Cron expressions are evaluated in local time, unless prefixed with CRON_TZ=
+func Parse(spec string) (Schedule, error) {
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval <= 0 {
+			return nil, errors.New(spec + " is not a valid schedule. Intervals must be positive")
+		}
+		return every(interval), nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, errors.New(spec + " is not a valid schedule. Must be either a duration or a cron expression: " + err.Error())
+	}
+	return schedule, nil
+}
+
+// Scheduler - Runs a job over and over following a schedule, one run at a time
+type Scheduler struct {
+	schedule Schedule
+	job      func(ctx context.Context)
+	now      func() time.Time
+	after    func(d time.Duration) <-chan time.Time
+
+	mu      sync.Mutex
+	nextRun time.Time // Zero while the job runs, or the scheduler does not
+}
+
+// New - Scheduler factory
+func New(schedule Schedule, job func(ctx context.Context)) *Scheduler {
+	return &Scheduler{schedule: schedule, job: job, now: time.Now, after: time.After}
+}
+
+// Run - Runs the job on schedule until ctx is done. A run taking longer than the schedule delays the next one,
+// runs never overlap. ctx is handed to the job, so it can stop early too
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.setNextRun(time.Time{})
+
+	for {
+		next := s.schedule.Next(s.now())
+		if next.IsZero() {
+			// Cron expressions that can never match
+			return
+		}
+		s.setNextRun(next)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.after(next.Sub(s.now())):
+		}
+
+		s.setNextRun(time.Time{})
+		s.job(ctx)
+	}
+}
+
+// NextRun - Returns when the job runs next
+// The zero time means it is running right now, or the scheduler is not running at all
+func (s *Scheduler) NextRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextRun
+}
+
+func (s *Scheduler) setNextRun(next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextRun = next
+}
@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParse - Validates intervals and cron expressions are both accepted
+func TestParse(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 34, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		spec         string
+		expectedNext time.Time
+		error        string
+	}{
+		{
+			name:         "interval",
+			spec:         "90m",
+			expectedNext: now.Add(90 * time.Minute),
+		},
+		{
+			name:         "cron expression",
+			spec:         "CRON_TZ=UTC 0 */6 * * *",
+			expectedNext: time.Date(2021, 10, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "cron descriptor",
+			spec:         "CRON_TZ=UTC @daily",
+			expectedNext: time.Date(2021, 10, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "negative interval",
+			spec:  "-5m",
+			error: "-5m is not a valid schedule. Intervals must be positive",
+		},
+		{
+			name:  "garbage",
+			spec:  "every now and then",
+			error: "every now and then is not a valid schedule. Must be either a duration or a cron expression: expected exactly 5 fields, found 4: [every now and then]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := Parse(tc.spec)
+			if tc.error != "" {
+				assert.EqualError(t, err, tc.error)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedNext, schedule.Next(now).UTC())
+		})
+	}
+}
+
+// Fake clock shared by the test and the scheduler goroutine
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// TestScheduler_Run - Validates the job runs on schedule, one run at a time, until the context is done
+func TestScheduler_Run(t *testing.T) {
+	c := &clock{now: time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)}
+	ticks := make(chan time.Time)
+	waits := make(chan time.Duration)
+	runs := make(chan time.Time)
+
+	var s *Scheduler
+	s = New(every(time.Hour), func(ctx context.Context) {
+		assert.True(t, s.NextRun().IsZero())
+		runs <- c.Now()
+		// The job takes a while, the next run counts from its end
+		c.Advance(10 * time.Minute)
+	})
+	s.now = c.Now
+	s.after = func(d time.Duration) <-chan time.Time {
+		waits <- d
+		return ticks
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	assert.Equal(t, time.Hour, <-waits)
+	assert.Equal(t, time.Date(2021, 10, 1, 13, 0, 0, 0, time.UTC), s.NextRun())
+
+	for _, expectedNext := range []time.Time{
+		time.Date(2021, 10, 1, 14, 10, 0, 0, time.UTC),
+		time.Date(2021, 10, 1, 15, 20, 0, 0, time.UTC),
+	} {
+		ticks <- c.Advance(time.Hour)
+		<-runs
+		assert.Equal(t, time.Hour, <-waits)
+		assert.Equal(t, expectedNext, s.NextRun())
+	}
+
+	cancel()
+	<-done
+	assert.True(t, s.NextRun().IsZero())
+}
+
+// Schedule that never comes
+type never struct{}
+
+func (never) Next(time.Time) time.Time {
+	return time.Time{}
+}
+
+// TestScheduler_RunNever - Validates a schedule without next run stops the scheduler
+func TestScheduler_RunNever(t *testing.T) {
+	s := New(never{}, func(ctx context.Context) {
+		t.Error("job should never run")
+	})
+
+	s.Run(context.Background())
+	assert.True(t, s.NextRun().IsZero())
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+)
+
+// ErrCircuitOpen - Returned without calling the API while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open, not calling the API")
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // Calls go through
+	BreakerOpen     = "open"      // Calls fail fast until the cooldown is over
+	BreakerHalfOpen = "half-open" // A single probe call decides whether to close or open again
+)
+
+// Stops calling the API after too many consecutive failures, giving it time to recover
+type breaker struct {
+	mu        sync.Mutex
+	threshold int           // Consecutive failures opening the breaker. 0 disables it
+	cooldown  time.Duration // Time the breaker stays open before letting a probe through
+	now       func() time.Time
+
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool // Whether the half-open probe is still running
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now, state: BreakerClosed}
+}
+
+// Tells whether a call may go through
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Records a call the API answered
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Records a call the API failed, opening the breaker once there are too many in a row
+func (b *breaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Records a call its caller gave up on, which tells nothing about the API
+// Lets another call probe it if this one was the half-open probe
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Snapshot of the breaker, for clients to know whether fetching makes sense
+func (b *breaker) status() model.UpstreamStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := model.UpstreamStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state == BreakerOpen {
+		retryAt := b.openedAt.Add(b.cooldown)
+		status.RetryAt = &retryAt
+	}
+	return status
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rincon-orlando/go-bootcamp/util/atomicfile"
+)
+
+// Upstream response kept on disk, along with what is needed to revalidate it
+type cacheEntry struct {
+	URL          string          `json:"url"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	StoredAt     time.Time       `json:"stored_at"`
+	MaxAge       time.Duration   `json:"max_age"` // As told by Cache-Control. 0 means always revalidate
+	Body         json.RawMessage `json:"body"`
+}
+
+// Whether the entry can still be used without asking the API
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return e.MaxAge > 0 && now.Before(e.StoredAt.Add(e.MaxAge))
+}
+
+// Adds the conditional headers letting the API answer 304 Not Modified
+func (e *cacheEntry) revalidate(req *http.Request) {
+	if e.ETag != "" {
+		req.Header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		req.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// On-disk cache of upstream responses keyed by url, one file per url
+// A nil cache stores nothing
+type cache struct {
+	dir string
+	now func() time.Time
+}
+
+func newCache(dir string) *cache {
+	if dir == "" {
+		return nil
+	}
+	return &cache{dir: dir, now: time.Now}
+}
+
+// Returns the cached response of url, or nil if there is none
+// A corrupt entry is as good as a missing one, the next response replaces it
+func (c *cache) load(url string) *cacheEntry {
+	if c == nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url {
+		return nil
+	}
+	return &entry
+}
+
+// Keeps a response body, unless Cache-Control says no-store
+// Only JSON bodies are stored, which is all the API answers with
+func (c *cache) store(url string, header http.Header, body []byte) error {
+	if c == nil {
+		return nil
+	}
+
+	maxAge, noStore := parseCacheControl(header.Get("Cache-Control"))
+	if noStore {
+		return nil
+	}
+	if !json.Valid(body) {
+		return errors.New("not caching " + url + ": body is not JSON")
+	}
+
+	return c.write(&cacheEntry{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		StoredAt:     c.now(),
+		MaxAge:       maxAge,
+		Body:         body,
+	})
+}
+
+// Marks a cached response as fresh again after a 304 Not Modified, picking up any new validators
+func (c *cache) refresh(entry *cacheEntry, header http.Header) error {
+	if c == nil {
+		return nil
+	}
+
+	entry.StoredAt = c.now()
+	entry.MaxAge, _ = parseCacheControl(header.Get("Cache-Control"))
+	if etag := header.Get("ETag"); etag != "" {
+		entry.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		entry.LastModified = lastModified
+	}
+	return c.write(entry)
+}
+
+func (c *cache) write(entry *cacheEntry) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	return atomicfile.Write(c.path(entry.URL), false, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(entry)
+	})
+}
+
+// Urls are hashed into file names, as they are full of characters file systems do not like
+func (c *cache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Reads the max-age and no-store directives of a Cache-Control header. no-cache means a max-age of 0
+func parseCacheControl(value string) (maxAge time.Duration, noStore bool) {
+	noCache := false
+	for _, directive := range strings.Split(value, ",") {
+		name, arg := strings.TrimSpace(directive), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, arg = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), `"`)
+		}
+
+		switch strings.ToLower(name) {
+		case "no-store":
+			noStore = true
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(arg); err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	if noCache {
+		maxAge = 0
+	}
+	return maxAge, noStore
+}
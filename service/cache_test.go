@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Upstream answer of a cache test step
+type cacheStep struct {
+	status  int
+	body    string
+	headers []string
+}
+
+// TestApiClient_Cache - Validates fresh responses skip the API, stale ones are revalidated and served while it is down
+func TestApiClient_Cache(t *testing.T) {
+	testCases := []struct {
+		name string
+		// One getJson call per step. The API answers the requests reaching it with the steps, in order
+		steps []cacheStep
+		// Time passing before every step but the first
+		elapsed time.Duration
+		// Headers of the requests reaching the API
+		expectedRequests []http.Header
+		expectedNames    []string
+		expectedErr      string
+	}{
+		{
+			name:             "fresh response skips the api",
+			steps:            []cacheStep{{200, `{"name": "bulbasaur"}`, []string{"Cache-Control", "public, max-age=60"}}, {}},
+			elapsed:          59 * time.Second,
+			expectedRequests: []http.Header{{}},
+			expectedNames:    []string{"bulbasaur", "bulbasaur"},
+		},
+		{
+			name: "expired response is refetched",
+			steps: []cacheStep{
+				{200, `{"name": "bulbasaur"}`, []string{"Cache-Control", "max-age=60"}},
+				{200, `{"name": "ivysaur"}`, nil},
+			},
+			elapsed:          time.Minute,
+			expectedRequests: []http.Header{{}, {}},
+			expectedNames:    []string{"bulbasaur", "ivysaur"},
+		},
+		{
+			name: "revalidate etag",
+			steps: []cacheStep{
+				{200, `{"name": "bulbasaur"}`, []string{"ETag", `"v1"`}},
+				{304, "", []string{"Cache-Control", "max-age=60"}},
+				{},
+			},
+			elapsed:          time.Second,
+			expectedRequests: []http.Header{{}, {"If-None-Match": {`"v1"`}}},
+			expectedNames:    []string{"bulbasaur", "bulbasaur", "bulbasaur"},
+		},
+		{
+			name: "revalidate last modified",
+			steps: []cacheStep{
+				{200, `{"name": "bulbasaur"}`, []string{"Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT", "Cache-Control", "no-cache"}},
+				{304, "", []string{"ETag", `"v2"`}},
+				{200, `{"name": "ivysaur"}`, nil},
+			},
+			elapsed: time.Second,
+			expectedRequests: []http.Header{
+				{},
+				{"If-Modified-Since": {"Wed, 21 Oct 2015 07:28:00 GMT"}},
+				{"If-Modified-Since": {"Wed, 21 Oct 2015 07:28:00 GMT"}, "If-None-Match": {`"v2"`}},
+			},
+			expectedNames: []string{"bulbasaur", "bulbasaur", "ivysaur"},
+		},
+		{
+			name: "serve stale while the api is down",
+			steps: []cacheStep{
+				{200, `{"name": "bulbasaur"}`, []string{"Cache-Control", "max-age=60"}},
+				{503, "down", nil},
+			},
+			elapsed:          time.Hour,
+			expectedRequests: []http.Header{{}, {}},
+			expectedNames:    []string{"bulbasaur", "bulbasaur"},
+		},
+		{
+			name: "no store",
+			steps: []cacheStep{
+				{200, `{"name": "bulbasaur"}`, []string{"Cache-Control", "no-store", "ETag", `"v1"`}},
+				{503, "down", nil},
+			},
+			elapsed:          time.Second,
+			expectedRequests: []http.Header{{}, {}},
+			expectedNames:    []string{"bulbasaur"},
+			expectedErr:      "503 Service Unavailable",
+		},
+		{
+			name: "client errors are not hidden by stale responses",
+			steps: []cacheStep{
+				{200, `{"name": "bulbasaur"}`, nil},
+				{404, "not found", nil},
+			},
+			elapsed:          time.Second,
+			expectedRequests: []http.Header{{}, {}},
+			expectedNames:    []string{"bulbasaur"},
+			expectedErr:      "404 Not Found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				step := tc.steps[len(requests)]
+				requests = append(requests, conditionalHeaders(r.Header))
+				for i := 0; i+1 < len(step.headers); i += 2 {
+					w.Header().Set(step.headers[i], step.headers[i+1])
+				}
+				w.WriteHeader(step.status)
+				fmt.Fprint(w, step.body)
+			}))
+			defer server.Close()
+
+			client := newApiClient(ClientConfig{CacheDir: t.TempDir()})
+			now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+			client.cache.now = func() time.Time { return now }
+
+			var names []string
+			var err error
+			for i := range tc.steps {
+				if i > 0 {
+					now = now.Add(tc.elapsed)
+				}
+				var target struct {
+					Name string `json:"name"`
+				}
+				if err = client.getJson(context.Background(), server.URL, &target); err != nil {
+					break
+				}
+				names = append(names, target.Name)
+			}
+
+			assert.Equal(t, tc.expectedRequests, requests)
+			assert.Equal(t, tc.expectedNames, names)
+			if tc.expectedErr != "" {
+				assert.Contains(t, fmt.Sprint(err), tc.expectedErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+// Keeps only the conditional request headers, the ones the cache sends
+func conditionalHeaders(header http.Header) http.Header {
+	kept := http.Header{}
+	for _, name := range []string{"If-None-Match", "If-Modified-Since"} {
+		if value := header.Get(name); value != "" {
+			kept.Set(name, value)
+		}
+	}
+	return kept
+}
+
+// TestApiClient_CacheOnDisk - Validates cached responses outlive the client, and corrupt entries are ignored
+func TestApiClient_CacheOnDisk(t *testing.T) {
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"name": "bulbasaur"}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var target struct {
+		Name string `json:"name"`
+	}
+	assert.Nil(t, newApiClient(ClientConfig{CacheDir: dir}).getJson(context.Background(), server.URL, &target))
+
+	// A new client, as after a restart, works offline
+	up = false
+	target.Name = ""
+	assert.Nil(t, newApiClient(ClientConfig{CacheDir: dir}).getJson(context.Background(), server.URL, &target))
+	assert.Equal(t, "bulbasaur", target.Name)
+
+	// A corrupt entry is a cache miss
+	client := newApiClient(ClientConfig{CacheDir: dir})
+	assert.Nil(t, ioutil.WriteFile(client.cache.path(server.URL), []byte("{not json"), 0o644))
+	assert.Contains(t, fmt.Sprint(client.getJson(context.Background(), server.URL, &target)), "502 Bad Gateway")
+
+	// No temp files are left behind
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 1)
+}
+
+// Test_parseCacheControl - Validates the Cache-Control directives the cache cares about
+func Test_parseCacheControl(t *testing.T) {
+	testCases := []struct {
+		value           string
+		expectedMaxAge  time.Duration
+		expectedNoStore bool
+	}{
+		{"", 0, false},
+		{"public, max-age=86400", 24 * time.Hour, false},
+		{`max-age="30"`, 30 * time.Second, false},
+		{"Max-Age=30, No-Cache", 0, false},
+		{"max-age=soon", 0, false},
+		{"private, no-store", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			maxAge, noStore := parseCacheControl(tc.value)
+			assert.Equal(t, tc.expectedMaxAge, maxAge)
+			assert.Equal(t, tc.expectedNoStore, noStore)
+		})
+	}
+}
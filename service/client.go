@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rincon-orlando/go-bootcamp/metrics"
+)
+
+// ClientConfig - Settings of the http client calling the API. The zero value means no timeout, no retries and no breaker
+type ClientConfig struct {
+	Timeout          time.Duration // Timeout of every single request
+	MaxRetries       int           // Retries of a request failing with a network error, a 5xx or a 429
+	BackoffBase      time.Duration // Wait before the first retry, doubling on every other one
+	BackoffMax       time.Duration // Longest wait between retries, Retry-After included
+	BreakerThreshold int           // Consecutive failures opening the circuit breaker. 0 disables it
+	BreakerCooldown  time.Duration // Time the breaker stays open before probing the API again
+	CacheDir         string        // Directory keeping API responses to revalidate and fall back on. Empty disables the cache
+}
+
+// Calls the API retrying transient failures, and stops calling it while it looks down
+// With a cache, fresh responses are served without calling the API and stale ones while it is down
+type apiClient struct {
+	http    *http.Client
+	config  ClientConfig
+	breaker *breaker
+	cache   *cache
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+func newApiClient(config ClientConfig) *apiClient {
+	return &apiClient{
+		http:    &http.Client{Timeout: config.Timeout},
+		config:  config,
+		breaker: newBreaker(config.BreakerThreshold, config.BreakerCooldown),
+		cache:   newCache(config.CacheDir),
+		sleep:   sleep,
+	}
+}
+
+// Error status answered by the API
+type statusError struct {
+	url        string
+	status     string
+	retryable  bool
+	retryAfter time.Duration // As told by the Retry-After header, 0 if missing
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.url, e.status)
+}
+
+// GETs an url and decodes its JSON body into target, retrying transient failures
+// Falls back on the cached response, however old, if the API cannot be reached
+func (c *apiClient) getJson(ctx context.Context, url string, target interface{}) error {
+	cached := c.cache.load(url)
+	if cached != nil && cached.fresh(c.cache.now()) {
+		return json.Unmarshal(cached.Body, target)
+	}
+
+	body, err := c.getRetrying(ctx, url, cached)
+	if err != nil {
+		if cached == nil || !unavailable(ctx, err) {
+			return err
+		}
+		log.Printf("Serving stale response of %s: %s", url, err)
+		body = cached.Body
+	}
+	return json.Unmarshal(body, target)
+}
+
+// Tells whether err means the API could not be reached, rather than a problem with the request
+func unavailable(ctx context.Context, err error) bool {
+	statusErr, isStatus := err.(*statusError)
+	return ctx.Err() == nil && (!isStatus || statusErr.retryable)
+}
+
+// GETs an url, retrying transient failures
+func (c *apiClient) getRetrying(ctx context.Context, url string, cached *cacheEntry) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := c.get(ctx, url, cached)
+		if err == nil {
+			return body, nil
+		}
+
+		if err == ErrCircuitOpen || !unavailable(ctx, err) || attempt >= c.config.MaxRetries {
+			return nil, err
+		}
+
+		wait := c.backoff(attempt)
+		if statusErr, isStatus := err.(*statusError); isStatus && statusErr.retryAfter > 0 {
+			wait = statusErr.retryAfter
+			if c.config.BackoffMax > 0 && wait > c.config.BackoffMax {
+				wait = c.config.BackoffMax
+			}
+		}
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Single GET through the circuit breaker, returning the body of successful responses
+// A cached response is revalidated, and kept as the body if the API answers 304 Not Modified
+func (c *apiClient) get(ctx context.Context, url string, cached *cacheEntry) ([]byte, error) {
+	// Built before asking the breaker, so a wrong url never takes the half-open probe without reporting back
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.breaker.allow(); err != nil {
+		metrics.UpstreamRequests.WithLabelValues("circuit_open").Inc()
+		return nil, err
+	}
+
+	if cached != nil {
+		cached.revalidate(req)
+	}
+	started := time.Now()
+	response, err := c.http.Do(req)
+	observe(started, response, err)
+	if err != nil {
+		// The caller giving up tells nothing about the API, as retryable already knows
+		if ctx.Err() != nil {
+			c.breaker.release()
+			return nil, err
+		}
+		c.breaker.failure()
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests {
+		c.breaker.failure()
+		return nil, &statusError{url, response.Status, true, parseRetryAfter(response.Header.Get("Retry-After"))}
+	}
+
+	// Anything else means the API is up, even if it does not like this particular request
+	c.breaker.success()
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		if err := c.cache.refresh(cached, response.Header); err != nil {
+			log.Printf("Error caching %s: %s", url, err)
+		}
+		return cached.Body, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, &statusError{url: url, status: response.Status}
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	// The cache is only an optimization, failing to write it does not fail the request
+	if err := c.cache.store(url, response.Header, body); err != nil {
+		log.Printf("Error caching %s: %s", url, err)
+	}
+	return body, nil
+}
+
+// Records an API call, labelled with the response status code or 'error' if there was none
+func observe(started time.Time, response *http.Response, err error) {
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(response.StatusCode)
+	}
+	metrics.UpstreamRequests.WithLabelValues(status).Inc()
+	metrics.UpstreamDuration.WithLabelValues(status).Observe(time.Since(started).Seconds())
+}
+
+// Exponential backoff with full jitter, so concurrent workers do not retry in lockstep
+func (c *apiClient) backoff(attempt int) time.Duration {
+	max := c.config.BackoffBase << attempt
+	if max <= 0 || (c.config.BackoffMax > 0 && max > c.config.BackoffMax) {
+		max = c.config.BackoffMax
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// Reads a Retry-After header, given either in seconds or as an http date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Waits for d, unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
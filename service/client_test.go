@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rincon-orlando/go-bootcamp/metrics"
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers every request with the next response, repeating the last one once they run out
+func scriptedServer(t *testing.T, responses ...func(w http.ResponseWriter)) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1)) - 1
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		responses[n](w)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func respond(status int, body string, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}
+}
+
+// TestApiClient_getJson - Validates retries, Retry-After and which failures are retried
+func TestApiClient_getJson(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        ClientConfig
+		responses     []func(w http.ResponseWriter)
+		expectedCalls int32
+		expectedWaits []time.Duration // Only checked when set, jittered waits are random
+		expectedErr   string
+	}{
+		{
+			name:          "first try",
+			config:        ClientConfig{MaxRetries: 3},
+			responses:     []func(w http.ResponseWriter){respond(200, `{"name": "bulbasaur"}`)},
+			expectedCalls: 1,
+		},
+		{
+			name:          "retry server errors",
+			config:        ClientConfig{MaxRetries: 3, BackoffBase: time.Millisecond},
+			responses:     []func(w http.ResponseWriter){respond(500, "oops"), respond(503, "<html>down</html>"), respond(200, `{"name": "bulbasaur"}`)},
+			expectedCalls: 3,
+		},
+		{
+			name:          "honour retry after",
+			config:        ClientConfig{MaxRetries: 1, BackoffBase: time.Millisecond, BackoffMax: time.Minute},
+			responses:     []func(w http.ResponseWriter){respond(429, "slow down", "Retry-After", "7"), respond(200, `{"name": "bulbasaur"}`)},
+			expectedCalls: 2,
+			expectedWaits: []time.Duration{7 * time.Second},
+		},
+		{
+			name:          "retry after capped by backoff max",
+			config:        ClientConfig{MaxRetries: 1, BackoffBase: time.Millisecond, BackoffMax: 2 * time.Second},
+			responses:     []func(w http.ResponseWriter){respond(429, "slow down", "Retry-After", "120"), respond(200, `{"name": "bulbasaur"}`)},
+			expectedCalls: 2,
+			expectedWaits: []time.Duration{2 * time.Second},
+		},
+		{
+			name:          "give up after max retries",
+			config:        ClientConfig{MaxRetries: 2},
+			responses:     []func(w http.ResponseWriter){respond(502, "bad gateway")},
+			expectedCalls: 3,
+			expectedErr:   "502 Bad Gateway",
+		},
+		{
+			name:          "never retry client errors",
+			config:        ClientConfig{MaxRetries: 3},
+			responses:     []func(w http.ResponseWriter){respond(404, "not found")},
+			expectedCalls: 1,
+			expectedErr:   "404 Not Found",
+		},
+		{
+			name:          "never retry invalid json",
+			config:        ClientConfig{MaxRetries: 3},
+			responses:     []func(w http.ResponseWriter){respond(200, "<html>maintenance</html>")},
+			expectedCalls: 1,
+			expectedErr:   "invalid character '<' looking for beginning of value",
+		},
+		{
+			name:   "retry timeouts",
+			config: ClientConfig{Timeout: 20 * time.Millisecond, MaxRetries: 1},
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { time.Sleep(200 * time.Millisecond) },
+				respond(200, `{"name": "bulbasaur"}`),
+			},
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := scriptedServer(t, tc.responses...)
+
+			client := newApiClient(tc.config)
+			var waits []time.Duration
+			client.sleep = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			var target struct {
+				Name string `json:"name"`
+			}
+			err := client.getJson(context.Background(), server.URL, &target)
+
+			assert.Equal(t, tc.expectedCalls, atomic.LoadInt32(calls))
+			if tc.expectedErr != "" {
+				assert.Contains(t, fmt.Sprint(err), tc.expectedErr)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, "bulbasaur", target.Name)
+			}
+			if tc.expectedWaits != nil {
+				assert.Equal(t, tc.expectedWaits, waits)
+			}
+			for _, wait := range waits {
+				if tc.config.BackoffMax > 0 {
+					assert.LessOrEqual(t, wait, tc.config.BackoffMax)
+				}
+			}
+		})
+	}
+}
+
+// TestApiClient_Breaker - Validates the breaker fails fast while the API is down and probes it after the cooldown
+// Every call, and every call refused, is counted by status
+func TestApiClient_Breaker(t *testing.T) {
+	counted := func(status string) float64 {
+		return testutil.ToFloat64(metrics.UpstreamRequests.WithLabelValues(status))
+	}
+	failed, refused, succeeded := counted("500"), counted("circuit_open"), counted("200")
+
+	var healthy int32
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	client := newApiClient(ClientConfig{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	client.breaker.now = func() time.Time { return now }
+
+	get := func() error {
+		var target struct{}
+		return client.getJson(context.Background(), server.URL, &target)
+	}
+
+	// Failures below the threshold keep the breaker closed
+	assert.NotNil(t, get())
+	assert.Equal(t, model.UpstreamStatus{State: BreakerClosed, ConsecutiveFailures: 1}, client.breaker.status())
+
+	// Reaching it opens the breaker, which then fails fast
+	assert.NotNil(t, get())
+	retryAt := now.Add(time.Minute)
+	assert.Equal(t, model.UpstreamStatus{State: BreakerOpen, ConsecutiveFailures: 2, RetryAt: &retryAt}, client.breaker.status())
+	assert.True(t, errors.Is(get(), ErrCircuitOpen))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// A failing probe after the cooldown opens it again
+	now = now.Add(time.Minute)
+	assert.NotNil(t, get())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, BreakerOpen, client.breaker.status().State)
+	assert.True(t, errors.Is(get(), ErrCircuitOpen))
+
+	// A request that cannot even be built is no probe
+	now = now.Add(time.Minute)
+	var target struct{}
+	assert.NotNil(t, client.getJson(context.Background(), "http://[::1", &target))
+	assert.Equal(t, BreakerOpen, client.breaker.status().State)
+
+	// A successful probe closes it
+	atomic.StoreInt32(&healthy, 1)
+	assert.Nil(t, get())
+	assert.Equal(t, model.UpstreamStatus{State: BreakerClosed}, client.breaker.status())
+	assert.Nil(t, get())
+
+	assert.Equal(t, failed+3, counted("500"))
+	assert.Equal(t, refused+2, counted("circuit_open"))
+	assert.Equal(t, succeeded+2, counted("200"))
+}
+
+// TestApiClient_BreakerCancel - Validates calls given up by the caller neither open the breaker nor keep the probe
+func TestApiClient_BreakerCancel(t *testing.T) {
+	var healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	client := newApiClient(ClientConfig{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	client.breaker.now = func() time.Time { return now }
+
+	cancelled := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		var target struct{}
+		return client.getJson(ctx, server.URL, &target)
+	}
+
+	assert.ErrorIs(t, cancelled(), context.DeadlineExceeded)
+	assert.Equal(t, model.UpstreamStatus{State: BreakerClosed}, client.breaker.status())
+
+	// Nor does a cancelled probe keep others from probing
+	client.breaker.failure()
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, cancelled(), context.DeadlineExceeded)
+	assert.Equal(t, BreakerHalfOpen, client.breaker.status().State)
+
+	atomic.StoreInt32(&healthy, 1)
+	var target struct{}
+	assert.Nil(t, client.getJson(context.Background(), server.URL, &target))
+	assert.Equal(t, model.UpstreamStatus{State: BreakerClosed}, client.breaker.status())
+}
+
+// TestBreaker_HalfOpen - Validates a single probe goes through while half-open
+func TestBreaker_HalfOpen(t *testing.T) {
+	b := newBreaker(1, time.Second)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.failure()
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	now = now.Add(time.Second)
+	assert.Nil(t, b.allow())
+	assert.Equal(t, BreakerHalfOpen, b.status().State)
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	b.success()
+	assert.Nil(t, b.allow())
+}
+
+// Test_backoff - Validates the jittered backoff grows exponentially without going over the max
+func Test_backoff(t *testing.T) {
+	client := newApiClient(ClientConfig{BackoffBase: 100 * time.Millisecond, BackoffMax: time.Second})
+	for attempt := 0; attempt < 70; attempt++ {
+		limit := client.config.BackoffBase << attempt
+		if limit <= 0 || limit > client.config.BackoffMax {
+			limit = client.config.BackoffMax
+		}
+		for i := 0; i < 20; i++ {
+			wait := client.backoff(attempt)
+			assert.GreaterOrEqual(t, wait, time.Duration(0))
+			assert.LessOrEqual(t, wait, limit)
+		}
+	}
+}
+
+// Test_parseRetryAfter - Validates both Retry-After formats
+func Test_parseRetryAfter(t *testing.T) {
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT"))
+
+	wait := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, float64(time.Hour), float64(wait), float64(2*time.Second))
+}
@@ -1,21 +1,23 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"strconv"
-	"strings"
 
 	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/workerpool"
 )
 
+// Most list pages followed in a single fetch, so an API handing out endless 'next' links cannot keep us going forever
+const maxListPages = 1000
+
 // Useful doc
 // https://tutorialedge.net/golang/consuming-restful-api-with-go/
 
 // Response struct to map the entire Pokemon API response
 type apiResponse struct {
+	Count   int          `json:"count"`
+	Next    string       `json:"next"`
 	Results []apiPokemon `json:"results"`
 }
 
@@ -25,65 +27,155 @@ type apiPokemon struct {
 	Url  string `json:"url"`
 }
 
+// Detail struct to map the Pokemon detail endpoint response (dismiss everything else)
+type apiPokemonDetail struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	Height         int    `json:"height"`
+	Weight         int    `json:"weight"`
+	BaseExperience int    `json:"base_experience"`
+	Types          []struct {
+		Type apiNamedResource `json:"type"`
+	} `json:"types"`
+	Stats []struct {
+		BaseStat int              `json:"base_stat"`
+		Stat     apiNamedResource `json:"stat"`
+	} `json:"stats"`
+	Abilities []struct {
+		Ability apiNamedResource `json:"ability"`
+	} `json:"abilities"`
+}
+
+// Named resource as the API references types, stats and abilities
+type apiNamedResource struct {
+	Name string `json:"name"`
+}
+
 // Service - Definition of a service
 type Service struct {
-	url string
+	url         string
+	limit       int // Max number of pokemons to fetch. 0 means no limit
+	numWorkers  int // Workers fetching pokemon details concurrently
+	maxInFlight int // Max detail requests running at the same time. 0 means one per worker
+	client      *apiClient
 }
 
 // New - Service factory
-func New(url string) Service {
-	return Service{url}
+func New(url string, limit int, numWorkers int, maxInFlight int, clientConfig ClientConfig) Service {
+	return Service{url, limit, numWorkers, maxInFlight, newApiClient(clientConfig)}
+}
+
+// UpstreamStatus - Returns the state of the circuit breaker guarding the API
+func (s Service) UpstreamStatus() model.UpstreamStatus {
+	return s.client.breaker.status()
 }
 
 // FetchPokemonsFromApi - Utility method to try fetch Pokemons from a particular url
-func (s Service) FetchPokemonsFromApi() ([]model.Pokemon, error) {
-	response, err := http.Get(s.url)
+// First stage gets the pokemon list, second stage gets the details of every pokemon in the list
+// Every failure is reported as model.ErrUpstreamUnavailable. Retries, backoff and pending details stop once ctx is done
+func (s Service) FetchPokemonsFromApi(ctx context.Context) ([]model.Pokemon, error) {
+	entries, err := s.fetchList(ctx)
 	if err != nil {
-		return nil, err
+		return nil, model.Errorf(model.ErrUpstreamUnavailable, "fetching pokemons from the API: %w", err)
 	}
-	defer response.Body.Close()
 
-	responseData, err := ioutil.ReadAll(response.Body)
+	pokemons, err := s.fetchDetails(ctx, entries)
 	if err != nil {
-		return nil, err
+		return nil, model.Errorf(model.ErrUpstreamUnavailable, "fetching pokemons from the API: %w", err)
 	}
 
-	var responseObject apiResponse
-	err = json.Unmarshal(responseData, &responseObject)
-	if err != nil {
-		return nil, err
+	return pokemons, nil
+}
+
+// Follows the 'next' link of every page until the whole list is fetched or the limit is reached
+// Links back to a page already fetched, or more than maxListPages pages, fail the fetch
+func (s Service) fetchList(ctx context.Context) ([]apiPokemon, error) {
+	v := make([]apiPokemon, 0)
+	visited := make(map[string]bool)
+
+	for next := s.url; next != ""; {
+		if visited[next] {
+			return nil, fmt.Errorf("'next' link %s points to an already fetched page", next)
+		}
+		if len(visited) == maxListPages {
+			return nil, fmt.Errorf("the list has more than %d pages", maxListPages)
+		}
+		visited[next] = true
+
+		var page apiResponse
+		if err := s.client.getJson(ctx, next, &page); err != nil {
+			return nil, err
+		}
+
+		v = append(v, page.Results...)
+
+		// Stop as soon as we have enough pokemons
+		if s.limit > 0 && len(v) >= s.limit {
+			return v[:s.limit], nil
+		}
+
+		next = page.Next
 	}
 
-	return mapPokemons(responseObject.Results), nil
+	return v, nil
 }
 
-// Utility method to extract the Pokemon ID out of the Url
-func match(s string) (int, error) {
-	i := strings.LastIndex(s, "/")
-	if i >= 0 {
-		j := strings.LastIndex(s[:i-1], "/")
-		if j >= 0 {
-			return strconv.Atoi(s[j+1 : i])
+// Fetches the detail url of every list entry concurrently and maps it into our own model
+func (s Service) fetchDetails(ctx context.Context, entries []apiPokemon) ([]model.Pokemon, error) {
+	jobs := make([]workerpool.FetchJob, len(entries))
+	for i, entry := range entries {
+		url := entry.Url
+		jobs[i] = func() (interface{}, error) {
+			return s.fetchDetail(ctx, url)
+		}
+	}
+
+	results, err := workerpool.FetchAll(ctx, s.numWorkers, s.maxInFlight, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	v := make([]model.Pokemon, 0, len(entries))
+	// Results keep the list order, so the first error reported is the first failing entry
+	for _, result := range results {
+		if result.Err != nil {
+			return nil, result.Err
 		}
+		v = append(v, result.Value.(model.Pokemon))
 	}
 
-	return -1, nil
+	return v, nil
 }
 
-// Map API Pokemons into our own model Pokemons
-func mapPokemons(pokemons []apiPokemon) []model.Pokemon {
-	var v []model.Pokemon
+// Fetches a single pokemon detail
+func (s Service) fetchDetail(ctx context.Context, url string) (model.Pokemon, error) {
+	var detail apiPokemonDetail
+	if err := s.client.getJson(ctx, url, &detail); err != nil {
+		return model.Pokemon{}, err
+	}
 
-	for _, pokemon := range pokemons {
-		id, err := match(pokemon.Url)
-		// This is an error we can tolerate, swallow it
-		if err != nil {
-			fmt.Printf("Error extracting Pokemon ID from url: %s", err)
-			continue
-		}
+	return mapPokemon(detail), nil
+}
 
-		v = append(v, model.Pokemon{ID: id, Name: pokemon.Name})
+// Map an API Pokemon detail into our own model Pokemon
+func mapPokemon(detail apiPokemonDetail) model.Pokemon {
+	pokemon := model.Pokemon{
+		ID:             detail.ID,
+		Name:           detail.Name,
+		Height:         detail.Height,
+		Weight:         detail.Weight,
+		BaseExperience: detail.BaseExperience,
+	}
+
+	for _, t := range detail.Types {
+		pokemon.Types = append(pokemon.Types, t.Type.Name)
+	}
+	for _, s := range detail.Stats {
+		pokemon.Stats = append(pokemon.Stats, model.Stat{Name: s.Stat.Name, BaseStat: s.BaseStat})
+	}
+	for _, a := range detail.Abilities {
+		pokemon.Abilities = append(pokemon.Abilities, a.Ability.Name)
 	}
 
-	return v
+	return pokemon
 }
@@ -1,30 +1,35 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"rincon-orlando/go-bootcamp/model"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// Every {{host}} is replaced with the fake server url
 const validFakeResponse string = `
 {
 	"count": 1118,
-	"next": "https://pokeapi.co/api/v2/pokemon/?offset=20&limit=20",
+	"next": null,
 	"previous": null,
 	"results": [
 	{
 	"name": "bulbasaur",
-	"url": "https://pokeapi.co/api/v2/pokemon/1/"
+	"url": "{{host}}/pokemon/1/"
 	},
 	{
 	"name": "ivysaur",
-	"url": "https://pokeapi.co/api/v2/pokemon/2/"
+	"url": "{{host}}/pokemon/2/"
 	}
 	]
 	}
@@ -33,20 +38,74 @@ const validFakeResponse string = `
 const invalidFakeResponse string = `
 {
 	"count": 1118,
-	"next": "https://pokeapi.co/api/v2/pokemon/?offset=20&limit=20",
+	"next": null,
 	"previous": null,
 	"results": [
 	{
 	"name": "bulbasaur",
-	"url": "https://pokeapi.co/api/v2/pokemon/1/"
+	"url": "{{host}}/pokemon/1/"
 	},
 	{
 	"name": "ivysaur",
-	"url": "https://pokeapi.co/api/v2/pokemon/2/"
+	"url": "{{host}}/pokemon/2/"
 	}
 	]
 `
 
+const fakeDetailResponse string = `
+{
+	"id": %d,
+	"name": "%s",
+	"height": 7,
+	"weight": 69,
+	"base_experience": 64,
+	"types": [
+	{"slot": 1, "type": {"name": "grass", "url": "{{host}}/type/12/"}},
+	{"slot": 2, "type": {"name": "poison", "url": "{{host}}/type/4/"}}
+	],
+	"stats": [
+	{"base_stat": 45, "effort": 0, "stat": {"name": "hp", "url": "{{host}}/stat/1/"}},
+	{"base_stat": 49, "effort": 0, "stat": {"name": "attack", "url": "{{host}}/stat/2/"}}
+	],
+	"abilities": [
+	{"ability": {"name": "overgrow", "url": "{{host}}/ability/65/"}, "is_hidden": false, "slot": 1}
+	]
+}
+`
+
+var fakeNames = []string{"bulbasaur", "ivysaur", "venusaur", "charmander", "charmeleon"}
+
+// Builds the pokemon we expect out of fakeDetailResponse
+func expectedPokemon(id int) model.Pokemon {
+	return model.Pokemon{
+		ID:             id,
+		Name:           fakeNames[id-1],
+		Types:          []string{"grass", "poison"},
+		Stats:          []model.Stat{{Name: "hp", BaseStat: 45}, {Name: "attack", BaseStat: 49}},
+		Height:         7,
+		Weight:         69,
+		BaseExperience: 64,
+		Abilities:      []string{"overgrow"},
+	}
+}
+
+// Serves the detail endpoint of the fake API. Returns false if the request was not for a detail
+func serveDetail(w http.ResponseWriter, r *http.Request, host string) bool {
+	if !strings.HasPrefix(r.URL.Path, "/pokemon/") {
+		return false
+	}
+
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/pokemon/"), "/"))
+	if err != nil || id < 1 || id > len(fakeNames) {
+		w.WriteHeader(http.StatusNotFound)
+		return true
+	}
+
+	body := fmt.Sprintf(fakeDetailResponse, id, fakeNames[id-1])
+	fmt.Fprintln(w, strings.ReplaceAll(body, "{{host}}", host))
+	return true
+}
+
 // TestService_FetchPokemonsFromApi - Test client to get pokemons from external API
 func TestService_FetchPokemonsFromApi(t *testing.T) {
 	testCases := []struct {
@@ -57,43 +116,241 @@ func TestService_FetchPokemonsFromApi(t *testing.T) {
 		error            error
 	}{
 		{
-			name:           "fetch pokemon OK",
-			serverResponse: validFakeResponse,
-			expectedPokemons: []model.Pokemon{
-				{
-					ID: 1, Name: "bulbasaur",
-				},
-				{
-					ID: 2, Name: "ivysaur",
-				},
-			},
-			hasError: false,
-			error:    nil,
+			name:             "fetch pokemon OK",
+			serverResponse:   validFakeResponse,
+			expectedPokemons: []model.Pokemon{expectedPokemon(1), expectedPokemon(2)},
+			hasError:         false,
+			error:            nil,
 		},
 		{
 			name:             "fetch pokemon wrong response",
 			serverResponse:   invalidFakeResponse,
 			expectedPokemons: []model.Pokemon{},
 			hasError:         true,
-			error:            errors.New("unexpected end of JSON input"),
+			error:            errors.New("fetching pokemons from the API: unexpected end of JSON input"),
 		},
 	}
 
 	for _, tc := range testCases {
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			fmt.Fprintln(w, tc.serverResponse)
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if serveDetail(w, r, server.URL) {
+				return
+			}
+			fmt.Fprintln(w, strings.ReplaceAll(tc.serverResponse, "{{host}}", server.URL))
 		}))
 
 		defer server.Close()
 
-		service := New(server.URL)
+		service := New(server.URL, 0, 2, 0, ClientConfig{})
 
-		pokemons, err := service.FetchPokemonsFromApi()
+		pokemons, err := service.FetchPokemonsFromApi(context.Background())
 		if tc.hasError {
 			assert.EqualError(t, err, tc.error.Error())
+			assert.ErrorIs(t, err, model.ErrUpstreamUnavailable)
 		} else {
 			assert.EqualValues(t, tc.expectedPokemons, pokemons)
 		}
 	}
 
 }
+
+// TestService_FetchPokemonsFromApi_DetailError - Test a failing detail aborts the whole fetch
+func TestService_FetchPokemonsFromApi_DetailError(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pokemon/2/" {
+			fmt.Fprintln(w, "not a json")
+			return
+		}
+		if serveDetail(w, r, server.URL) {
+			return
+		}
+		fmt.Fprintln(w, strings.ReplaceAll(validFakeResponse, "{{host}}", server.URL))
+	}))
+	defer server.Close()
+
+	service := New(server.URL, 0, 2, 0, ClientConfig{})
+
+	pokemons, err := service.FetchPokemonsFromApi(context.Background())
+	assert.Nil(t, pokemons)
+	assert.ErrorIs(t, err, model.ErrUpstreamUnavailable)
+}
+
+// TestService_FetchPokemonsFromApi_StatusError - Test an error status from the API is not taken as a response
+func TestService_FetchPokemonsFromApi_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintln(w, `{"results": []}`)
+	}))
+	defer server.Close()
+
+	service := New(server.URL, 0, 2, 0, ClientConfig{})
+
+	pokemons, err := service.FetchPokemonsFromApi(context.Background())
+	assert.Nil(t, pokemons)
+	assert.EqualError(t, err, "fetching pokemons from the API: GET "+server.URL+": 502 Bad Gateway")
+	assert.ErrorIs(t, err, model.ErrUpstreamUnavailable)
+}
+
+// Builds a page of the fake API, linking to the next page if any
+func fakePage(host string, count int, next string, ids ...int) string {
+	results := make([]string, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, fmt.Sprintf(`{"name": "%s", "url": "%s/pokemon/%d/"}`, fakeNames[id-1], host, id))
+	}
+
+	nextJson := "null"
+	if next != "" {
+		nextJson = `"` + next + `"`
+	}
+
+	return fmt.Sprintf(`{"count": %d, "next": %s, "previous": null, "results": [%s]}`, count, nextJson, strings.Join(results, ","))
+}
+
+// TestService_FetchPokemonsFromApi_Pagination - Test client follows the 'next' links of the external API
+func TestService_FetchPokemonsFromApi_Pagination(t *testing.T) {
+	testCases := []struct {
+		name             string
+		limit            int
+		expectedPokemons []string
+		expectedPages    int
+	}{
+		{
+			name:             "fetch all pages",
+			limit:            0,
+			expectedPokemons: []string{"bulbasaur", "ivysaur", "venusaur", "charmander", "charmeleon"},
+			expectedPages:    3,
+		},
+		{
+			name:             "fetch until limit in the middle of a page",
+			limit:            3,
+			expectedPokemons: []string{"bulbasaur", "ivysaur", "venusaur"},
+			expectedPages:    2,
+		},
+		{
+			name:             "fetch until limit at the end of a page",
+			limit:            2,
+			expectedPokemons: []string{"bulbasaur", "ivysaur"},
+			expectedPages:    1,
+		},
+		{
+			name:             "limit greater than the whole list",
+			limit:            100,
+			expectedPokemons: []string{"bulbasaur", "ivysaur", "venusaur", "charmander", "charmeleon"},
+			expectedPages:    3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pages := 0
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if serveDetail(w, r, server.URL) {
+					return
+				}
+				pages++
+				switch r.URL.Query().Get("offset") {
+				case "":
+					fmt.Fprintln(w, fakePage(server.URL, 5, server.URL+"/?offset=2&limit=2", 1, 2))
+				case "2":
+					fmt.Fprintln(w, fakePage(server.URL, 5, server.URL+"/?offset=4&limit=2", 3, 4))
+				case "4":
+					fmt.Fprintln(w, fakePage(server.URL, 5, "", 5))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			service := New(server.URL, tc.limit, 2, 0, ClientConfig{})
+
+			pokemons, err := service.FetchPokemonsFromApi(context.Background())
+			assert.Nil(t, err)
+			names := make([]string, 0, len(pokemons))
+			for _, p := range pokemons {
+				names = append(names, p.Name)
+			}
+			assert.Equal(t, tc.expectedPokemons, names)
+			assert.Equal(t, tc.expectedPages, pages)
+		})
+	}
+}
+
+// TestService_FetchPokemonsFromApi_PaginationError - Test a failing page aborts the whole fetch
+func TestService_FetchPokemonsFromApi_PaginationError(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serveDetail(w, r, server.URL) {
+			return
+		}
+		if r.URL.Query().Get("offset") == "" {
+			fmt.Fprintln(w, fakePage(server.URL, 4, server.URL+"/?offset=2&limit=2", 1, 2))
+			return
+		}
+		fmt.Fprintln(w, "not a json")
+	}))
+	defer server.Close()
+
+	service := New(server.URL, 0, 2, 0, ClientConfig{})
+
+	pokemons, err := service.FetchPokemonsFromApi(context.Background())
+	assert.Nil(t, pokemons)
+	assert.Error(t, err)
+}
+
+// TestService_FetchPokemonsFromApi_PaginationLoop - Test pages linking to each other fail the fetch instead of looping forever
+func TestService_FetchPokemonsFromApi_PaginationLoop(t *testing.T) {
+	pages := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serveDetail(w, r, server.URL) {
+			return
+		}
+		pages++
+		if r.URL.Query().Get("offset") == "2" {
+			fmt.Fprintln(w, fakePage(server.URL, 4, server.URL+"/?offset=0&limit=2", 3, 4))
+			return
+		}
+		fmt.Fprintln(w, fakePage(server.URL, 4, server.URL+"/?offset=2&limit=2", 1, 2))
+	}))
+	defer server.Close()
+
+	service := New(server.URL+"/?offset=0&limit=2", 0, 2, 0, ClientConfig{})
+
+	pokemons, err := service.FetchPokemonsFromApi(context.Background())
+	assert.Nil(t, pokemons)
+	assert.ErrorIs(t, err, model.ErrUpstreamUnavailable)
+	assert.EqualError(t, err, "fetching pokemons from the API: 'next' link "+server.URL+"/?offset=0&limit=2 points to an already fetched page")
+	assert.Equal(t, 2, pages)
+}
+
+// TestService_FetchPokemonsFromApi_Cancel - Test retries and pending details stop once the context is done
+func TestService_FetchPokemonsFromApi_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pokemon/2/" {
+			// The caller goes away while the API keeps failing
+			cancel()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if serveDetail(w, r, server.URL) {
+			return
+		}
+		fmt.Fprintln(w, strings.ReplaceAll(validFakeResponse, "{{host}}", server.URL))
+	}))
+	defer server.Close()
+
+	service := New(server.URL, 0, 2, 0, ClientConfig{MaxRetries: 5, BackoffBase: time.Minute, BackoffMax: time.Minute})
+
+	started := time.Now()
+	pokemons, err := service.FetchPokemonsFromApi(ctx)
+	assert.Nil(t, pokemons)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, int64(time.Since(started)), int64(5*time.Second))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rincon-orlando/go-bootcamp/usecase"
+)
+
+// Winds the app down: stops taking connections and cancels the worker pools, then waits for the in-flight requests,
+// the scheduled refresh and the csv writes to finish. Gives up once the grace period is over
+func shutdown(server *http.Server, uc *usecase.UseCase, r *reloader, grace time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	// Pools answer right away, so their requests do not hold the shutdown back
+	uc.StopPools()
+	r.stop()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("waiting for in-flight requests: %w", err)
+	}
+	if err := uc.Close(ctx); err != nil {
+		return fmt.Errorf("waiting for csv writes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"rincon-orlando/go-bootcamp/model"
+)
+
+// Pokemon names as the API spells them, i.e. "bulbasaur", "mr-mime" or "porygon2"
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9]+(-[a-zA-Z0-9]+)*$`)
+
+const maxNameLength = 50
+
+// Validates the fields of a pokemon coming from a client
+func validatePokemon(pokemon model.Pokemon) error {
+	if pokemon.ID <= 0 {
+		return fmt.Errorf("%w: id must be a positive int", model.ErrInvalidPokemon)
+	}
+	if len(pokemon.Name) == 0 || len(pokemon.Name) > maxNameLength {
+		return fmt.Errorf("%w: name must have between 1 and %d characters", model.ErrInvalidPokemon, maxNameLength)
+	}
+	if !namePattern.MatchString(pokemon.Name) {
+		return fmt.Errorf("%w: name %q must only have letters and digits, optionally separated by single hyphens", model.ErrInvalidPokemon, pokemon.Name)
+	}
+	return nil
+}
+
+// CreatePokemon - Validates and adds a new pokemon, persisting the change into the csv file
+// A zero id means the next free one is assigned. The pokemon is removed again if persisting it fails.
+// The id is picked and checked under the same lock as the insert, so concurrent creates never get the same one
+func (uc *UseCase) CreatePokemon(pokemon model.Pokemon) (*model.Pokemon, error) {
+	err := uc.write(func() error {
+		if pokemon.ID == 0 {
+			var err error
+			if pokemon.ID, err = uc.nextId(); err != nil {
+				return err
+			}
+		}
+		if err := validatePokemon(pokemon); err != nil {
+			return err
+		}
+		if _, err := uc.repo.GetPokemonById(pokemon.ID); err == nil {
+			return fmt.Errorf("%w: id %d is taken", model.ErrPokemonExists, pokemon.ID)
+		}
+
+		if err := uc.repo.CreatePokemon(pokemon); err != nil {
+			if errors.Is(err, model.ErrConflict) {
+				return fmt.Errorf("%w: id %d is taken", model.ErrPokemonExists, pokemon.ID)
+			}
+			return storageError(err)
+		}
+		return nil
+	}, func() error {
+		return uc.repo.DeletePokemon(pokemon.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pokemon, nil
+}
+
+// UpdatePokemon - Validates and replaces an existing pokemon, persisting the change into the csv file
+// The previous version is restored if persisting the change fails
+func (uc *UseCase) UpdatePokemon(pokemon model.Pokemon) error {
+	if err := validatePokemon(pokemon); err != nil {
+		return err
+	}
+
+	var previous *model.Pokemon
+	return uc.write(func() error {
+		var err error
+		if previous, err = uc.repo.GetPokemonById(pokemon.ID); err != nil {
+			return notFoundError(err, pokemon.ID)
+		}
+		if err := uc.repo.UpdatePokemon(pokemon); err != nil {
+			return notFoundError(err, pokemon.ID)
+		}
+		return nil
+	}, func() error {
+		return uc.repo.UpdatePokemon(*previous)
+	})
+}
+
+// PatchPokemon - Applies patch to the pokemon under id, then validates and persists the outcome into the csv file
+// The pokemon is read, patched and written under the write lock, so concurrent patches never lose each other's changes.
+// Errors returned by patch are given back as they are. The previous version is restored if persisting the change fails
+func (uc *UseCase) PatchPokemon(id int, patch func(pokemon *model.Pokemon) error) (*model.Pokemon, error) {
+	var previous *model.Pokemon
+	var pokemon model.Pokemon
+	err := uc.write(func() error {
+		var err error
+		if previous, err = uc.repo.GetPokemonById(id); err != nil {
+			return notFoundError(err, id)
+		}
+		pokemon = clonePokemon(*previous)
+		if err := patch(&pokemon); err != nil {
+			return err
+		}
+		if pokemon.ID != id {
+			return fmt.Errorf("%w: id %d cannot be changed to %d", model.ErrInvalidPokemon, id, pokemon.ID)
+		}
+		if err := validatePokemon(pokemon); err != nil {
+			return err
+		}
+		if err := uc.repo.UpdatePokemon(pokemon); err != nil {
+			return notFoundError(err, id)
+		}
+		return nil
+	}, func() error {
+		return uc.repo.UpdatePokemon(*previous)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pokemon, nil
+}
+
+// DeletePokemon - Removes a pokemon given its id, persisting the change into the csv file
+// The pokemon is added back if persisting the change fails
+func (uc *UseCase) DeletePokemon(id int) error {
+	var previous *model.Pokemon
+	return uc.write(func() error {
+		var err error
+		if previous, err = uc.repo.GetPokemonById(id); err != nil {
+			return notFoundError(err, id)
+		}
+		if err := uc.repo.DeletePokemon(id); err != nil {
+			return notFoundError(err, id)
+		}
+		return nil
+	}, func() error {
+		return uc.repo.CreatePokemon(*previous)
+	})
+}
+
+// Copies a pokemon along with its slices, which the repository may share with what it stores
+func clonePokemon(pokemon model.Pokemon) model.Pokemon {
+	if pokemon.Types != nil {
+		pokemon.Types = append([]string{}, pokemon.Types...)
+	}
+	if pokemon.Stats != nil {
+		pokemon.Stats = append([]model.Stat{}, pokemon.Stats...)
+	}
+	if pokemon.Abilities != nil {
+		pokemon.Abilities = append([]string{}, pokemon.Abilities...)
+	}
+	return pokemon
+}
+
+// Tells a missing pokemon apart from a storage failure
+func notFoundError(err error, id int) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return fmt.Errorf("%w: id %d", model.ErrPokemonNotFound, id)
+	}
+	return storageError(err)
+}
+
+// First id after the biggest one in the repository
+func (uc UseCase) nextId() (int, error) {
+	pokemons, err := uc.GetAllPokemons()
+	if err != nil {
+		return 0, err
+	}
+	max := 0
+	for _, p := range pokemons {
+		if p.ID > max {
+			max = p.ID
+		}
+	}
+	return max + 1, nil
+}
@@ -0,0 +1,344 @@
+package usecase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUseCase_CreatePokemon - Validates use case CreatePokemon method
+func TestUseCase_CreatePokemon(t *testing.T) {
+	testCases := []struct {
+		name            string
+		pokemon         model.Pokemon
+		existing        *model.Pokemon
+		expectedPokemon model.Pokemon
+		expectedCsv     string
+		hasError        bool
+		error           error
+	}{
+		{
+			name:            "create pokemon",
+			pokemon:         model.Pokemon{ID: 4, Name: "charmander"},
+			expectedPokemon: model.Pokemon{ID: 4, Name: "charmander"},
+			expectedCsv:     "1,bulbasaur,,0,0,0,,\n2,ivysaur,,0,0,0,,\n3,venusaur,,0,0,0,,\n",
+		},
+		{
+			name:            "create pokemon with next free id",
+			pokemon:         model.Pokemon{Name: "mr-mime"},
+			expectedPokemon: model.Pokemon{ID: 4, Name: "mr-mime"},
+			expectedCsv:     "1,bulbasaur,,0,0,0,,\n2,ivysaur,,0,0,0,,\n3,venusaur,,0,0,0,,\n",
+		},
+		{
+			name:     "create taken id",
+			pokemon:  model.Pokemon{ID: 1, Name: "charmander"},
+			existing: &pokemons[0],
+			hasError: true,
+			error:    model.ErrPokemonExists,
+		},
+		{
+			name:     "create wrong name",
+			pokemon:  model.Pokemon{ID: 4, Name: "char mander"},
+			hasError: true,
+			error:    model.ErrInvalidPokemon,
+		},
+		{
+			name:     "create negative id",
+			pokemon:  model.Pokemon{ID: -4, Name: "charmander"},
+			hasError: true,
+			error:    model.ErrInvalidPokemon,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(pokemons, nil)
+			if tc.existing != nil {
+				mr.On("GetPokemonById").Return(tc.existing, nil)
+			} else {
+				mr.On("GetPokemonById").Return((*model.Pokemon)(nil), errors.New("not found"))
+			}
+			mr.On("CreatePokemon").Return(nil)
+
+			csvPath := filepath.Join(t.TempDir(), "pokemons.csv")
+			uc := UseCase{repo: mr, csvFileName: csvPath, persistMu: &sync.Mutex{}}
+
+			created, err := uc.CreatePokemon(tc.pokemon)
+			if tc.hasError {
+				assert.ErrorIs(t, err, tc.error)
+				mr.AssertNotCalled(t, "CreatePokemon")
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedPokemon, *created)
+			// The mocked repository does not change, so the file has its pokemons
+			content, _ := os.ReadFile(csvPath)
+			assert.Equal(t, tc.expectedCsv, string(content))
+		})
+	}
+}
+
+// TestUseCase_UpdatePokemon - Validates use case UpdatePokemon method
+func TestUseCase_UpdatePokemon(t *testing.T) {
+	testCases := []struct {
+		name            string
+		pokemon         model.Pokemon
+		repositoryError error
+		hasError        bool
+		error           error
+	}{
+		{
+			name:    "update pokemon",
+			pokemon: model.Pokemon{ID: 1, Name: "bulbasaur"},
+		},
+		{
+			name:            "update unexisting pokemon",
+			pokemon:         model.Pokemon{ID: 4, Name: "charmander"},
+			repositoryError: model.Errorf(model.ErrNotFound, "pokemon with 4 not found"),
+			hasError:        true,
+			error:           model.ErrPokemonNotFound,
+		},
+		{
+			name:            "update failing storage",
+			pokemon:         model.Pokemon{ID: 1, Name: "bulbasaur"},
+			repositoryError: errors.New("disk I/O error"),
+			hasError:        true,
+			error:           model.ErrPersistence,
+		},
+		{
+			name:     "update wrong name",
+			pokemon:  model.Pokemon{ID: 1, Name: ""},
+			hasError: true,
+			error:    model.ErrInvalidPokemon,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(pokemons, nil)
+			mr.On("GetPokemonById").Return(&pokemons[0], nil)
+			mr.On("UpdatePokemon").Return(tc.repositoryError)
+
+			uc := UseCase{repo: mr, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+			err := uc.UpdatePokemon(tc.pokemon)
+			if tc.hasError {
+				assert.ErrorIs(t, err, tc.error)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+// TestUseCase_DeletePokemon - Validates use case DeletePokemon method
+func TestUseCase_DeletePokemon(t *testing.T) {
+	testCases := []struct {
+		name            string
+		id              int
+		repositoryError error
+		hasError        bool
+		error           error
+	}{
+		{
+			name: "delete pokemon",
+			id:   1,
+		},
+		{
+			name:            "delete unexisting pokemon",
+			id:              4,
+			repositoryError: model.Errorf(model.ErrNotFound, "pokemon with 4 not found"),
+			hasError:        true,
+			error:           model.ErrPokemonNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(pokemons, nil)
+			mr.On("GetPokemonById").Return(&pokemons[0], nil)
+			mr.On("DeletePokemon").Return(tc.repositoryError)
+
+			uc := UseCase{repo: mr, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+			err := uc.DeletePokemon(tc.id)
+			if tc.hasError {
+				assert.ErrorIs(t, err, tc.error)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+// TestUseCase_CreatePokemon_Concurrent - Validates concurrent creates without an id all get one of their own
+func TestUseCase_CreatePokemon_Concurrent(t *testing.T) {
+	db := repository.New()
+	require.Nil(t, db.SetPokemons(pokemons))
+	uc := &UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+	const creates = 20
+	var wg sync.WaitGroup
+	wg.Add(creates)
+	for i := 0; i < creates; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := uc.CreatePokemon(model.Pokemon{Name: "missingno"})
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	created := allPokemons(t, db)
+	require.Len(t, created, len(pokemons)+creates)
+	for i, pokemon := range created {
+		assert.Equal(t, i+1, pokemon.ID)
+	}
+}
+
+// TestUseCase_PatchPokemon - Validates use case PatchPokemon method
+func TestUseCase_PatchPokemon(t *testing.T) {
+	testCases := []struct {
+		name     string
+		id       int
+		patch    func(pokemon *model.Pokemon) error
+		expected model.Pokemon
+		error    error
+	}{
+		{
+			name:     "patch pokemon",
+			id:       1,
+			patch:    func(pokemon *model.Pokemon) error { pokemon.Height = 7; return nil },
+			expected: model.Pokemon{ID: 1, Name: "bulbasaur", Height: 7},
+		},
+		{
+			name:  "patch unexisting pokemon",
+			id:    4,
+			patch: func(pokemon *model.Pokemon) error { return nil },
+			error: model.ErrPokemonNotFound,
+		},
+		{
+			name:  "patch failing",
+			id:    1,
+			patch: func(pokemon *model.Pokemon) error { return model.Errorf(model.ErrValidation, "bad body") },
+			error: model.ErrValidation,
+		},
+		{
+			name:  "patch wrong name",
+			id:    1,
+			patch: func(pokemon *model.Pokemon) error { pokemon.Name = ""; return nil },
+			error: model.ErrInvalidPokemon,
+		},
+		{
+			name:  "patch id",
+			id:    1,
+			patch: func(pokemon *model.Pokemon) error { pokemon.ID = 2; return nil },
+			error: model.ErrInvalidPokemon,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := repository.New()
+			require.Nil(t, db.SetPokemons(pokemons))
+			uc := &UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+			pokemon, err := uc.PatchPokemon(tc.id, tc.patch)
+			if tc.error != nil {
+				assert.ErrorIs(t, err, tc.error)
+				assert.Nil(t, pokemon)
+				assert.Equal(t, pokemons, allPokemons(t, db))
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, *pokemon)
+			stored, err := db.GetPokemonById(tc.id)
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, *stored)
+		})
+	}
+}
+
+// TestUseCase_PatchPokemon_Concurrent - Validates concurrent patches of the same pokemon never lose each other's changes
+func TestUseCase_PatchPokemon_Concurrent(t *testing.T) {
+	db := repository.New()
+	require.Nil(t, db.SetPokemons(pokemons))
+	uc := &UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+	const patches = 20
+	var wg sync.WaitGroup
+	wg.Add(patches)
+	for i := 0; i < patches; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := uc.PatchPokemon(1, func(pokemon *model.Pokemon) error {
+				pokemon.Height++
+				return nil
+			})
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	pokemon, err := db.GetPokemonById(1)
+	require.Nil(t, err)
+	assert.Equal(t, patches, pokemon.Height)
+}
+
+// TestUseCase_WriteRollback - Validates changes that cannot be persisted are reverted, so the repository and the csv file agree
+func TestUseCase_WriteRollback(t *testing.T) {
+	testCases := []struct {
+		name  string
+		write func(uc *UseCase) error
+	}{
+		{
+			name: "create",
+			write: func(uc *UseCase) error {
+				_, err := uc.CreatePokemon(model.Pokemon{ID: 4, Name: "charmander"})
+				return err
+			},
+		},
+		{
+			name:  "update",
+			write: func(uc *UseCase) error { return uc.UpdatePokemon(model.Pokemon{ID: 1, Name: "bulbasaur-2"}) },
+		},
+		{
+			name: "patch",
+			write: func(uc *UseCase) error {
+				_, err := uc.PatchPokemon(1, func(pokemon *model.Pokemon) error { pokemon.Height = 7; return nil })
+				return err
+			},
+		},
+		{
+			name:  "delete",
+			write: func(uc *UseCase) error { return uc.DeletePokemon(2) },
+		},
+		{
+			name:  "set",
+			write: func(uc *UseCase) error { return uc.SetPokemons([]model.Pokemon{{ID: 4, Name: "charmander"}}) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := repository.New()
+			require.Nil(t, db.SetPokemons(pokemons))
+			uc := &UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "missing", "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+			assert.ErrorIs(t, tc.write(uc), model.ErrPersistence)
+			assert.Equal(t, pokemons, allPokemons(t, db))
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/util/enum"
+)
+
+// Column index of the header names, for header detection
+var columnsByName = func() map[string]int {
+	m := make(map[string]int, numColumns)
+	for col, name := range columnNames {
+		m[name] = col
+	}
+	return m
+}()
+
+// Marks a column of the uploaded file that does not map to any pokemon field
+const ignoredColumn = -1
+
+// ImportPokemons - Replaces every pokemon with the ones in a CSV file, reporting the rows that are not valid
+// The first row is taken as a header when it names the id and name columns, which may then come in any order.
+// Headerless files must follow the order of the persisted csv.
+// With enum.Reject any issue leaves the pokemons untouched, with enum.Skip only the invalid rows are left out.
+// Nothing is imported when the file has no valid rows. The error is only set for unreadable files or failed writes
+func (uc *UseCase) ImportPokemons(r io.Reader, mode enum.ImportMode) (model.ImportReport, error) {
+	report := model.ImportReport{Issues: []model.ImportIssue{}}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	// Until a header says otherwise, columns are in file order
+	columns := make([]int, numColumns)
+	for col := range columns {
+		columns[col] = col
+	}
+
+	var valid []model.Pokemon
+	seen := make(map[int]int) // Line where every id was first found
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Issues = append(report.Issues, model.ImportIssue{Line: parseErr.Line, Reason: parseErr.Err.Error()})
+			report.Skipped++
+			continue
+		}
+		if err != nil {
+			return model.ImportReport{}, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first {
+			// Spreadsheets like to start files with a byte order mark
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			if header, issues, ok := parseHeader(record, line); ok {
+				columns = header
+				report.Issues = append(report.Issues, issues...)
+				continue
+			}
+		}
+
+		pokemon, issues := parseRecord(record, columns, line)
+		if len(issues) == 0 {
+			if firstLine, ok := seen[pokemon.ID]; ok {
+				issues = append(issues, model.ImportIssue{Line: line, Column: columnNames[colID], Reason: fmt.Sprintf("id %d is already used at line %d", pokemon.ID, firstLine)})
+			}
+		}
+		if len(issues) > 0 {
+			report.Issues = append(report.Issues, issues...)
+			report.Skipped++
+			continue
+		}
+
+		seen[pokemon.ID] = line
+		valid = append(valid, pokemon)
+	}
+
+	if len(valid) == 0 {
+		report.Issues = append(report.Issues, model.ImportIssue{Reason: "no valid pokemons to import"})
+		return report, nil
+	}
+	if mode == enum.Reject && len(report.Issues) > 0 {
+		return report, nil
+	}
+
+	if err := uc.SetPokemons(valid); err != nil {
+		return report, err
+	}
+	report.Imported = len(valid)
+
+	return report, nil
+}
+
+// Tells whether a record is a header, naming at least the id and name columns
+// Returns the pokemon column of every record field, along with the issues of the header itself
+func parseHeader(record []string, line int) ([]int, []model.ImportIssue, bool) {
+	if _, err := strconv.Atoi(strings.TrimSpace(record[0])); err == nil {
+		return nil, nil, false
+	}
+
+	columns := make([]int, len(record))
+	found := make(map[int]bool)
+	var issues []model.ImportIssue
+	for i, name := range record {
+		name = strings.ToLower(strings.TrimSpace(name))
+		col, ok := columnsByName[name]
+		switch {
+		case !ok:
+			issues = append(issues, model.ImportIssue{Line: line, Column: name, Reason: "unknown column"})
+			col = ignoredColumn
+		case found[col]:
+			issues = append(issues, model.ImportIssue{Line: line, Column: name, Reason: "duplicated column"})
+			col = ignoredColumn
+		}
+		found[col] = true
+		columns[i] = col
+	}
+
+	if !found[colID] || !found[colName] {
+		return nil, nil, false
+	}
+	return columns, issues, true
+}
+
+// Builds a pokemon out of a record, given the pokemon column of every field
+func parseRecord(record []string, columns []int, line int) (model.Pokemon, []model.ImportIssue) {
+	var pokemon model.Pokemon
+	var issues []model.ImportIssue
+
+	if len(record) > len(columns) {
+		issues = append(issues, model.ImportIssue{Line: line, Reason: fmt.Sprintf("Expected at most %d columns, got %d", len(columns), len(record))})
+		record = record[:len(columns)]
+	}
+	for i, value := range record {
+		col := columns[i]
+		if col == ignoredColumn {
+			continue
+		}
+		if err := parseField(&pokemon, col, strings.TrimSpace(value)); err != nil {
+			issues = append(issues, model.ImportIssue{Line: line, Column: columnNames[col], Reason: err.Error()})
+		}
+	}
+	if len(issues) > 0 {
+		return model.Pokemon{}, issues
+	}
+
+	if err := validatePokemon(pokemon); err != nil {
+		column := columnNames[colName]
+		if pokemon.ID <= 0 {
+			column = columnNames[colID]
+		}
+		reason := strings.TrimPrefix(err.Error(), model.ErrInvalidPokemon.Error()+": ")
+		return model.Pokemon{}, []model.ImportIssue{{Line: line, Column: column, Reason: reason}}
+	}
+
+	return pokemon, nil
+}
+
+// ExportPokemons - Writes every pokemon as a CSV file, with a header row so it can be imported back
+func (uc UseCase) ExportPokemons(w io.Writer) error {
+	// Buffer the whole file so a failure never leaves a truncated download behind
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(columnNames[:]); err != nil {
+		return err
+	}
+	pokemons, err := uc.GetAllPokemons()
+	if err != nil {
+		return err
+	}
+	for _, pokemon := range pokemons {
+		if err := writer.Write(formatLine(pokemon)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
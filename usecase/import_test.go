@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/repository"
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUseCase_ImportPokemons - Validates CSV files are imported, or refused with a report
+func TestUseCase_ImportPokemons(t *testing.T) {
+	testCases := []struct {
+		name             string
+		csv              string
+		mode             enum.ImportMode
+		expectedReport   model.ImportReport
+		expectedPokemons []model.Pokemon
+	}{
+		{
+			name:           "headerless file",
+			csv:            "1,bulbasaur,grass|poison,7,69,64,overgrow,hp:45\n2,ivysaur\n",
+			mode:           enum.Reject,
+			expectedReport: model.ImportReport{Imported: 2, Issues: []model.ImportIssue{}},
+			expectedPokemons: []model.Pokemon{
+				{ID: 1, Name: "bulbasaur", Types: []string{"grass", "poison"}, Height: 7, Weight: 69, BaseExperience: 64, Abilities: []string{"overgrow"}, Stats: []model.Stat{{Name: "hp", BaseStat: 45}}},
+				{ID: 2, Name: "ivysaur"},
+			},
+		},
+		{
+			name:             "header with columns in any order",
+			csv:              "\ufeffName, Weight, ID\nbulbasaur,69,1\nivysaur,130,2\n",
+			mode:             enum.Reject,
+			expectedReport:   model.ImportReport{Imported: 2, Issues: []model.ImportIssue{}},
+			expectedPokemons: []model.Pokemon{{ID: 1, Name: "bulbasaur", Weight: 69}, {ID: 2, Name: "ivysaur", Weight: 130}},
+		},
+		{
+			name: "invalid rows reject the file",
+			csv:  "id,name,height\n1,bulbasaur,7\nx,ivysaur,10\n3,venu saur,20\n4,charmander,tall\n1,mew,4\n",
+			mode: enum.Reject,
+			expectedReport: model.ImportReport{Skipped: 4, Issues: []model.ImportIssue{
+				{Line: 3, Column: "id", Reason: "Error converting x to int"},
+				{Line: 4, Column: "name", Reason: `name "venu saur" must only have letters and digits, optionally separated by single hyphens`},
+				{Line: 5, Column: "height", Reason: "Error converting tall to int"},
+				{Line: 6, Column: "id", Reason: "id 1 is already used at line 2"},
+			}},
+			expectedPokemons: pokemons,
+		},
+		{
+			name: "invalid rows are skipped",
+			csv:  "id,name,height\n1,bulbasaur,7\nx,ivysaur,10\n4,charmander,10\n",
+			mode: enum.Skip,
+			expectedReport: model.ImportReport{Imported: 2, Skipped: 1, Issues: []model.ImportIssue{
+				{Line: 3, Column: "id", Reason: "Error converting x to int"},
+			}},
+			expectedPokemons: []model.Pokemon{{ID: 1, Name: "bulbasaur", Height: 7}, {ID: 4, Name: "charmander", Height: 10}},
+		},
+		{
+			name: "unknown header columns are reported",
+			csv:  "id,name,color\n1,bulbasaur,green\n",
+			mode: enum.Skip,
+			expectedReport: model.ImportReport{Imported: 1, Issues: []model.ImportIssue{
+				{Line: 1, Column: "color", Reason: "unknown column"},
+			}},
+			expectedPokemons: []model.Pokemon{{ID: 1, Name: "bulbasaur"}},
+		},
+		{
+			name: "malformed csv row",
+			csv:  "1,bulbasaur\n2,\"ivy\"saur\n",
+			mode: enum.Skip,
+			expectedReport: model.ImportReport{Imported: 1, Skipped: 1, Issues: []model.ImportIssue{
+				{Line: 2, Reason: `extraneous or missing " in quoted-field`},
+			}},
+			expectedPokemons: []model.Pokemon{{ID: 1, Name: "bulbasaur"}},
+		},
+		{
+			name: "empty file",
+			csv:  "",
+			mode: enum.Skip,
+			expectedReport: model.ImportReport{Issues: []model.ImportIssue{
+				{Reason: "no valid pokemons to import"},
+			}},
+			expectedPokemons: pokemons,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := repository.New()
+			require.Nil(t, db.SetPokemons(pokemons))
+			uc := UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+			report, err := uc.ImportPokemons(strings.NewReader(tc.csv), tc.mode)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedReport, report)
+			assert.Equal(t, tc.expectedPokemons, allPokemons(t, db))
+		})
+	}
+}
+
+// TestUseCase_ExportPokemons - Validates exported files have a header and can be imported back
+func TestUseCase_ExportPokemons(t *testing.T) {
+	exported := []model.Pokemon{
+		{ID: 1, Name: "bulbasaur", Types: []string{"grass", "poison"}, Height: 7, Weight: 69, BaseExperience: 64, Abilities: []string{"overgrow"}, Stats: []model.Stat{{Name: "hp", BaseStat: 45}}},
+		{ID: 2, Name: "ivysaur"},
+	}
+
+	db := repository.New()
+	require.Nil(t, db.SetPokemons(exported))
+	uc := UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, uc.ExportPokemons(&buf))
+	assert.Equal(t, "id,name,types,height,weight,base_experience,abilities,stats\n"+
+		"1,bulbasaur,grass|poison,7,69,64,overgrow,hp:45\n"+
+		"2,ivysaur,,0,0,0,,\n", buf.String())
+
+	// Round trip
+	require.Nil(t, db.SetPokemons(nil))
+	report, err := uc.ImportPokemons(&buf, enum.Reject)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, exported, allPokemons(t, db))
+}
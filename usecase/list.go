@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"sort"
+	"strings"
+
+	"rincon-orlando/go-bootcamp/model"
+)
+
+// Comparison between two pokemons for a given sort key, telling whether a goes before b
+type lessFunc func(a, b model.Pokemon) bool
+
+// Sort keys supported by ListPokemons
+var sortKeys = map[string]lessFunc{
+	"id":              func(a, b model.Pokemon) bool { return a.ID < b.ID },
+	"name":            func(a, b model.Pokemon) bool { return a.Name < b.Name },
+	"height":          func(a, b model.Pokemon) bool { return a.Height < b.Height },
+	"weight":          func(a, b model.Pokemon) bool { return a.Weight < b.Weight },
+	"base_experience": func(a, b model.Pokemon) bool { return a.BaseExperience < b.BaseExperience },
+}
+
+// ListPokemons - Returns a page of pokemons sorted by the given keys, along with the total amount of pokemons
+// sortBy is a comma separated list of keys, '-' prefixed for descending order, i.e. "-weight,name"
+// Ties, and an empty sortBy, fall back to id order. limit <= 0 means every pokemon from offset on
+func (uc UseCase) ListPokemons(sortBy string, offset int, limit int) ([]model.Pokemon, int, error) {
+	less, err := parseSort(sortBy)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The repository gives them sorted by id, a stable sort keeps that order for ties
+	all, err := uc.GetAllPokemons()
+	if err != nil {
+		return nil, 0, err
+	}
+	if less != nil {
+		sort.SliceStable(all, func(i, j int) bool {
+			return less(all[i], all[j])
+		})
+	}
+
+	total := len(all)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	return all[offset:end], total, nil
+}
+
+// Builds a single comparison out of a sort expression. nil means no sorting at all
+func parseSort(sortBy string) (lessFunc, error) {
+	if strings.TrimSpace(sortBy) == "" {
+		return nil, nil
+	}
+
+	var keys []lessFunc
+	for _, key := range strings.Split(sortBy, ",") {
+		key = strings.TrimSpace(key)
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+
+		less, ok := sortKeys[strings.ToLower(key)]
+		if !ok {
+			return nil, model.Errorf(model.ErrValidation, "%s is not a valid sort key. Must be one of id, name, height, weight or base_experience", key)
+		}
+		if desc {
+			asc := less
+			less = func(a, b model.Pokemon) bool { return asc(b, a) }
+		}
+		keys = append(keys, less)
+	}
+
+	// First key deciding the order wins
+	return func(a, b model.Pokemon) bool {
+		for _, less := range keys {
+			if less(a, b) {
+				return true
+			}
+			if less(b, a) {
+				return false
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var listPokemons = []model.Pokemon{
+	{ID: 1, Name: "bulbasaur", Weight: 69},
+	{ID: 2, Name: "ivysaur", Weight: 130},
+	{ID: 3, Name: "venusaur", Weight: 1000},
+	{ID: 4, Name: "charmander", Weight: 85},
+	{ID: 5, Name: "charmeleon", Weight: 85},
+}
+
+// TestUseCase_ListPokemons - Validates use case ListPokemons method
+func TestUseCase_ListPokemons(t *testing.T) {
+	testCases := []struct {
+		name          string
+		sortBy        string
+		offset        int
+		limit         int
+		expectedIds   []int
+		expectedTotal int
+		hasError      bool
+		error         error
+	}{
+		{
+			name:          "no sort, no limit",
+			expectedIds:   []int{1, 2, 3, 4, 5},
+			expectedTotal: 5,
+		},
+		{
+			name:          "sort by name",
+			sortBy:        "name",
+			expectedIds:   []int{1, 4, 5, 2, 3},
+			expectedTotal: 5,
+		},
+		{
+			name:          "sort by descending name",
+			sortBy:        "-name",
+			expectedIds:   []int{3, 2, 5, 4, 1},
+			expectedTotal: 5,
+		},
+		{
+			name:          "ties fall back to id",
+			sortBy:        "weight",
+			expectedIds:   []int{1, 4, 5, 2, 3},
+			expectedTotal: 5,
+		},
+		{
+			name:          "several keys",
+			sortBy:        "weight,-id",
+			expectedIds:   []int{1, 5, 4, 2, 3},
+			expectedTotal: 5,
+		},
+		{
+			name:          "page",
+			sortBy:        "-id",
+			offset:        1,
+			limit:         2,
+			expectedIds:   []int{4, 3},
+			expectedTotal: 5,
+		},
+		{
+			name:          "last partial page",
+			offset:        4,
+			limit:         2,
+			expectedIds:   []int{5},
+			expectedTotal: 5,
+		},
+		{
+			name:          "offset out of range",
+			offset:        10,
+			limit:         2,
+			expectedIds:   []int{},
+			expectedTotal: 5,
+		},
+		{
+			name:     "wrong sort key",
+			sortBy:   "id,color",
+			hasError: true,
+			error:    errors.New("color is not a valid sort key. Must be one of id, name, height, weight or base_experience"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := &mockRepository{}
+			// Hand a copy, sorting must not leak into the repository data
+			all := append([]model.Pokemon{}, listPokemons...)
+			mr.On("GetAllPokemons").Return(all, nil)
+
+			uc := UseCase{repo: mr}
+
+			page, total, err := uc.ListPokemons(tc.sortBy, tc.offset, tc.limit)
+			if tc.hasError {
+				assert.EqualError(t, err, tc.error.Error())
+				return
+			}
+
+			assert.Nil(t, err)
+			ids := make([]int, 0, len(page))
+			for _, p := range page {
+				ids = append(ids, p.ID)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+			assert.Equal(t, tc.expectedTotal, total)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/scheduler"
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Keeps refreshes from racing each other, and remembers how the last one went
+type refresher struct {
+	flight singleflight.Group // Refreshes asked for while an identical one runs share its outcome
+	run    sync.Mutex         // Serializes refreshes with different settings, so their reads and writes never interleave
+
+	mu        sync.Mutex
+	running   bool
+	lastRun   *model.RefreshRun
+	schedule  string
+	scheduler *scheduler.Scheduler
+}
+
+// RefreshPokemons - Fetches the pokemons from the API and syncs them with the local ones as mode says
+// A refresh asked for while an identical one runs, manual or scheduled, waits for it and gets its report.
+// The fetch stops once the ctx of the caller that started it is done, callers whose ctx is still alive start over
+func (uc *UseCase) RefreshPokemons(ctx context.Context, mode enum.SyncMode, dryRun bool) (model.SyncReport, error) {
+	return uc.refreshPokemons(ctx, model.ManualRefresh, mode, dryRun)
+}
+
+func (uc *UseCase) refreshPokemons(ctx context.Context, trigger string, mode enum.SyncMode, dryRun bool) (model.SyncReport, error) {
+	key := mode.String()
+	if dryRun {
+		key += "/dry-run"
+	}
+
+	for {
+		results := uc.refresh.flight.DoChan(key, func() (interface{}, error) {
+			uc.refresh.run.Lock()
+			defer uc.refresh.run.Unlock()
+
+			if dryRun {
+				return uc.fetchAndSync(ctx, mode, true)
+			}
+
+			uc.refresh.setRunning()
+			started := time.Now()
+			report, err := uc.fetchAndSync(ctx, mode, false)
+			uc.refresh.record(trigger, mode, started, report, err)
+			return report, err
+		})
+
+		var result singleflight.Result
+		select {
+		case result = <-results:
+		case <-ctx.Done():
+			return model.SyncReport{}, ctx.Err()
+		}
+
+		// The refresh joined was cancelled by the caller that started it, not by this one
+		if result.Shared && errors.Is(result.Err, context.Canceled) && ctx.Err() == nil && !uc.stopping() {
+			continue
+		}
+		if result.Err != nil {
+			return model.SyncReport{}, result.Err
+		}
+		return result.Val.(model.SyncReport), nil
+	}
+}
+
+// Shutting down stops the fetch like the pools, so it never holds Close back
+func (uc *UseCase) fetchAndSync(ctx context.Context, mode enum.SyncMode, dryRun bool) (model.SyncReport, error) {
+	if uc.stopping() {
+		return model.SyncReport{}, uc.stoppedError(context.Canceled)
+	}
+	ctx, cancel := uc.poolContext(ctx)
+	defer cancel()
+
+	fetched, err := uc.service.FetchPokemonsFromApi(ctx)
+	if err != nil {
+		return model.SyncReport{}, uc.stoppedError(err)
+	}
+
+	return uc.SyncPokemons(fetched, mode, dryRun)
+}
+
+// RunScheduledRefresh - Refreshes the pokemons with the given mode on schedule until ctx is done
+// spec is the schedule as configured, reported by RefreshStatus until ctx is done or another schedule replaces it.
+// Failed refreshes are logged, the next one still runs
+func (uc *UseCase) RunScheduledRefresh(ctx context.Context, spec string, schedule scheduler.Schedule, mode enum.SyncMode) {
+	s := scheduler.New(schedule, func(ctx context.Context) {
+		if _, err := uc.refreshPokemons(ctx, model.ScheduledRefresh, mode, false); err != nil {
+			log.Println("Scheduled refresh failed: " + err.Error())
+		}
+	})
+
+	uc.refresh.mu.Lock()
+	uc.refresh.schedule = spec
+	uc.refresh.scheduler = s
+	uc.refresh.mu.Unlock()
+
+	s.Run(ctx)
+
+	uc.refresh.mu.Lock()
+	defer uc.refresh.mu.Unlock()
+	if uc.refresh.scheduler == s {
+		uc.refresh.schedule = ""
+		uc.refresh.scheduler = nil
+	}
+}
+
+// RefreshStatus - Returns whether a refresh is running, how the last one went and when the next scheduled one is
+func (uc UseCase) RefreshStatus() model.RefreshStatus {
+	uc.refresh.mu.Lock()
+	defer uc.refresh.mu.Unlock()
+
+	status := model.RefreshStatus{Schedule: uc.refresh.schedule, Running: uc.refresh.running}
+	if uc.refresh.lastRun != nil {
+		lastRun := *uc.refresh.lastRun
+		status.LastRun = &lastRun
+	}
+	if uc.refresh.scheduler != nil {
+		if next := uc.refresh.scheduler.NextRun(); !next.IsZero() {
+			status.NextRun = &next
+		}
+	}
+	return status
+}
+
+func (r *refresher) setRunning() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = true
+}
+
+func (r *refresher) record(trigger string, mode enum.SyncMode, started time.Time, report model.SyncReport, err error) {
+	run := &model.RefreshRun{
+		Trigger:   trigger,
+		Mode:      mode.String(),
+		StartedAt: started.UTC(),
+		Duration:  time.Since(started).Round(time.Millisecond).String(),
+		Outcome:   "success",
+	}
+	if err != nil {
+		run.Outcome = "failure"
+		run.Error = err.Error()
+	} else {
+		run.Report = &report
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running = false
+	r.lastRun = run
+}
@@ -0,0 +1,226 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/repository"
+	"rincon-orlando/go-bootcamp/scheduler"
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newRefreshUseCase(t *testing.T, ms *mockService) *UseCase {
+	return &UseCase{
+		repo:        repository.New(),
+		csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"),
+		service:     ms,
+		refresh:     &refresher{},
+		persistMu:   &sync.Mutex{},
+	}
+}
+
+// TestUseCase_RefreshPokemons - Validates refreshes sync the fetched pokemons and record how they went
+func TestUseCase_RefreshPokemons(t *testing.T) {
+	testCases := []struct {
+		name            string
+		dryRun          bool
+		fetchError      error
+		expectedReport  model.SyncReport
+		expectedOutcome string
+		expectedRecord  bool
+	}{
+		{
+			name:            "refresh",
+			expectedReport:  model.SyncReport{Mode: "merge", Added: []int{1, 2, 3}, Updated: []int{}, Unchanged: []int{}, Removed: []int{}},
+			expectedOutcome: "success",
+			expectedRecord:  true,
+		},
+		{
+			name:            "failed refresh",
+			fetchError:      model.Errorf(model.ErrUpstreamUnavailable, "fetching pokemons from the API: %w", errors.New("502 Bad Gateway")),
+			expectedOutcome: "failure",
+			expectedRecord:  true,
+		},
+		{
+			name:           "dry runs are not recorded",
+			dryRun:         true,
+			expectedReport: model.SyncReport{Mode: "merge", DryRun: true, Added: []int{1, 2, 3}, Updated: []int{}, Unchanged: []int{}, Removed: []int{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ms := &mockService{}
+			ms.On("FetchPokemonsFromApi").Return(pokemons, tc.fetchError)
+			uc := newRefreshUseCase(t, ms)
+
+			before := time.Now().UTC()
+			report, err := uc.RefreshPokemons(context.Background(), enum.Merge, tc.dryRun)
+
+			assert.Equal(t, tc.fetchError, err)
+			assert.Equal(t, tc.expectedReport, report)
+
+			status := uc.RefreshStatus()
+			assert.False(t, status.Running)
+			assert.Nil(t, status.NextRun)
+			if !tc.expectedRecord {
+				assert.Nil(t, status.LastRun)
+				return
+			}
+			require.NotNil(t, status.LastRun)
+			assert.Equal(t, model.ManualRefresh, status.LastRun.Trigger)
+			assert.Equal(t, "merge", status.LastRun.Mode)
+			assert.Equal(t, tc.expectedOutcome, status.LastRun.Outcome)
+			assert.False(t, status.LastRun.StartedAt.Before(before.Truncate(time.Second)))
+			assert.NotEmpty(t, status.LastRun.Duration)
+			if tc.fetchError != nil {
+				assert.Equal(t, tc.fetchError.Error(), status.LastRun.Error)
+				assert.Nil(t, status.LastRun.Report)
+			} else {
+				assert.Empty(t, status.LastRun.Error)
+				assert.Equal(t, &tc.expectedReport, status.LastRun.Report)
+			}
+		})
+	}
+}
+
+// TestUseCase_RefreshPokemons_SingleFlight - Validates overlapping identical refreshes fetch once and share the report
+func TestUseCase_RefreshPokemons_SingleFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+
+	ms := &mockService{}
+	ms.On("FetchPokemonsFromApi").Run(func(mock.Arguments) {
+		once.Do(func() { close(started) })
+		<-release
+	}).Return(pokemons, nil)
+	uc := newRefreshUseCase(t, ms)
+
+	const callers = 5
+	reports := make([]model.SyncReport, callers)
+	var wg sync.WaitGroup
+	refresh := func(i int) {
+		defer wg.Done()
+		report, err := uc.RefreshPokemons(context.Background(), enum.Replace, false)
+		assert.Nil(t, err)
+		reports[i] = report
+	}
+
+	wg.Add(1)
+	go refresh(0)
+	<-started
+	assert.True(t, uc.RefreshStatus().Running)
+
+	wg.Add(callers - 1)
+	for i := 1; i < callers; i++ {
+		go refresh(i)
+	}
+	// Give the other callers time to join the running refresh
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	ms.AssertNumberOfCalls(t, "FetchPokemonsFromApi", 1)
+	for _, report := range reports {
+		assert.Equal(t, []int{1, 2, 3}, report.Added)
+	}
+	assert.False(t, uc.RefreshStatus().Running)
+}
+
+// Service whose first fetch hangs until its ctx is done, the next ones return pokemons
+type hangingService struct {
+	mockService
+	started chan struct{}
+	calls   int32
+}
+
+func newHangingService() *hangingService {
+	hs := &hangingService{started: make(chan struct{})}
+	hs.On("UpstreamStatus").Return(model.UpstreamStatus{})
+	return hs
+}
+
+func (hs *hangingService) FetchPokemonsFromApi(ctx context.Context) ([]model.Pokemon, error) {
+	if atomic.AddInt32(&hs.calls, 1) > 1 {
+		return pokemons, nil
+	}
+	close(hs.started)
+	<-ctx.Done()
+	return nil, model.Errorf(model.ErrUpstreamUnavailable, "fetching pokemons from the API: %w", ctx.Err())
+}
+
+// TestUseCase_RefreshPokemons_Cancel - Validates the caller starting a refresh stops it, and those joining it start over
+func TestUseCase_RefreshPokemons_Cancel(t *testing.T) {
+	hs := newHangingService()
+	uc := &UseCase{repo: repository.New(), csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), service: hs, refresh: &refresher{}, persistMu: &sync.Mutex{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan error, 1)
+	go func() {
+		_, err := uc.RefreshPokemons(ctx, enum.Replace, false)
+		started <- err
+	}()
+	<-hs.started
+
+	joined := make(chan model.SyncReport, 1)
+	go func() {
+		report, err := uc.RefreshPokemons(context.Background(), enum.Replace, false)
+		assert.Nil(t, err)
+		joined <- report
+	}()
+	// Give the other caller time to join the running refresh
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	assert.ErrorIs(t, <-started, context.Canceled)
+	assert.Equal(t, []int{1, 2, 3}, (<-joined).Added)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hs.calls))
+	assert.Equal(t, pokemons, allPokemons(t, uc))
+}
+
+// TestUseCase_RunScheduledRefresh - Validates scheduled refreshes run and report the schedule along with the next run
+func TestUseCase_RunScheduledRefresh(t *testing.T) {
+	ms := &mockService{}
+	ms.On("FetchPokemonsFromApi").Return(pokemons, nil)
+	uc := newRefreshUseCase(t, ms)
+
+	schedule, err := scheduler.Parse("10ms")
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		uc.RunScheduledRefresh(ctx, "10ms", schedule, enum.UpsertOnly)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		status := uc.RefreshStatus()
+		return status.LastRun != nil && status.NextRun != nil
+	}, time.Second, time.Millisecond)
+
+	status := uc.RefreshStatus()
+	assert.Equal(t, "10ms", status.Schedule)
+	assert.Equal(t, model.ScheduledRefresh, status.LastRun.Trigger)
+	assert.Equal(t, "upsert-only", status.LastRun.Mode)
+	assert.Equal(t, "success", status.LastRun.Outcome)
+	assert.Equal(t, pokemons, allPokemons(t, uc))
+
+	cancel()
+	<-done
+	status = uc.RefreshStatus()
+	assert.Equal(t, "", status.Schedule)
+	assert.Nil(t, status.NextRun)
+	assert.NotNil(t, status.LastRun)
+}
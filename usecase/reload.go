@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"fmt"
+
+	"rincon-orlando/go-bootcamp/metrics"
+	"rincon-orlando/go-bootcamp/model"
+)
+
+// ReloadCsv - Replaces the pokemons with the ones in the csv file, if it changed since it was last read or written
+// Returns whether the pokemons were replaced. A file that cannot be read or parsed
+// is rejected, leaving the pokemons being served untouched. Otherwise they are all swapped at once
+func (uc *UseCase) ReloadCsv() (bool, error) {
+	// Refreshes read the pokemons before writing them back, so a reload in between would be lost
+	uc.refresh.run.Lock()
+	defer uc.refresh.run.Unlock()
+
+	uc.persistMu.Lock()
+	defer uc.persistMu.Unlock()
+	if uc.closed() {
+		return false, model.Errorf(model.ErrShuttingDown, "reading %s: shutting down", uc.csvFileName)
+	}
+
+	pokemons, sum, err := readCsv(uc.csvFileName)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", uc.csvFileName, err)
+	}
+	if sum == uc.csv.sum {
+		return false, nil
+	}
+
+	if err := uc.repo.SetPokemons(pokemons); err != nil {
+		return false, storageError(err)
+	}
+	uc.csv.sum = sum
+	uc.touch()
+	metrics.RepositorySize.Set(float64(len(pokemons)))
+
+	return true, nil
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUseCase_ReloadCsv - Validates changed files replace the pokemons, while bad ones leave them untouched
+func TestUseCase_ReloadCsv(t *testing.T) {
+	testCases := []struct {
+		name             string
+		change           func(csvPath string)
+		expectedReloaded bool
+		expectedPokemons []model.Pokemon
+		error            string
+	}{
+		{
+			name:             "unchanged",
+			change:           func(string) {},
+			expectedPokemons: pokemons,
+		},
+		{
+			name: "changed",
+			change: func(csvPath string) {
+				require.Nil(t, os.WriteFile(csvPath, []byte("4,charmander\n5,charmeleon\n"), 0644))
+			},
+			expectedReloaded: true,
+			expectedPokemons: []model.Pokemon{{ID: 4, Name: "charmander"}, {ID: 5, Name: "charmeleon"}},
+		},
+		{
+			name: "emptied",
+			change: func(csvPath string) {
+				require.Nil(t, os.WriteFile(csvPath, nil, 0644))
+			},
+			expectedReloaded: true,
+			expectedPokemons: []model.Pokemon{},
+		},
+		{
+			name: "invalid row",
+			change: func(csvPath string) {
+				require.Nil(t, os.WriteFile(csvPath, []byte("4,charmander\nfive,charmeleon\n"), 0644))
+			},
+			expectedPokemons: pokemons,
+			error:            "Error converting five to int",
+		},
+		{
+			name: "removed",
+			change: func(csvPath string) {
+				require.Nil(t, os.Remove(csvPath))
+			},
+			expectedPokemons: pokemons,
+			error:            "no such file or directory",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			csvPath := filepath.Join(t.TempDir(), "pokemons.csv")
+			require.Nil(t, os.WriteFile(csvPath, []byte("1,bulbasaur\n2,ivysaur\n3,venusaur\n"), 0644))
+
+			uc, err := New(repository.New(), csvPath, false, &mockService{}, nil)
+			require.Nil(t, err)
+
+			tc.change(csvPath)
+			reloaded, err := uc.ReloadCsv()
+
+			if tc.error != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.error)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.expectedReloaded, reloaded)
+			assert.Equal(t, tc.expectedPokemons, allPokemons(t, uc))
+		})
+	}
+}
+
+// TestUseCase_ReloadCsv_OwnWrites - Validates the csv files written by the use case itself are not reloaded
+func TestUseCase_ReloadCsv_OwnWrites(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "pokemons.csv")
+	require.Nil(t, os.WriteFile(csvPath, []byte("1,bulbasaur\n"), 0644))
+
+	uc, err := New(repository.New(), csvPath, false, &mockService{}, nil)
+	require.Nil(t, err)
+	_, err = uc.CreatePokemon(model.Pokemon{ID: 2, Name: "ivysaur"})
+	require.Nil(t, err)
+
+	reloaded, err := uc.ReloadCsv()
+	assert.Nil(t, err)
+	assert.False(t, reloaded)
+}
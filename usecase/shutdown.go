@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"rincon-orlando/go-bootcamp/model"
+)
+
+// Lets the use case wind down its work when the app shuts down
+type lifecycle struct {
+	stopOnce sync.Once
+	stopping chan struct{} // Closed once worker pools must stop
+	closed   bool          // Set once csv writes must not start anymore, guarded by persistMu
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{stopping: make(chan struct{})}
+}
+
+// StopPools - Cancels every worker pool and API fetch running, and fails those started afterwards with a model.ErrShuttingDown
+// Pools waiting for workers give up too
+func (uc UseCase) StopPools() {
+	if uc.lifecycle == nil {
+		return
+	}
+	uc.lifecycle.stopOnce.Do(func() { close(uc.lifecycle.stopping) })
+}
+
+// Close - Waits for the refresh, reload and csv writes in progress, until ctx is done
+// Writes to the csv file fail with a model.ErrShuttingDown afterwards, so none gets cut short by the app exiting
+func (uc UseCase) Close(ctx context.Context) error {
+	if uc.lifecycle == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		uc.refresh.run.Lock()
+		defer uc.refresh.run.Unlock()
+		uc.persistMu.Lock()
+		defer uc.persistMu.Unlock()
+		uc.lifecycle.closed = true
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Tells whether csv writes must not start anymore. persistMu must be held
+func (uc UseCase) closed() bool {
+	return uc.lifecycle != nil && uc.lifecycle.closed
+}
+
+// Derives the context a worker pool runs with, cancelled as well once StopPools is called
+func (uc UseCase) poolContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if uc.lifecycle == nil {
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-uc.lifecycle.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Tells whether StopPools was called
+func (uc UseCase) stopping() bool {
+	if uc.lifecycle == nil {
+		return false
+	}
+	select {
+	case <-uc.lifecycle.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Tells pools and fetches cancelled by StopPools apart from those whose caller went away
+func (uc UseCase) stoppedError(err error) error {
+	if errors.Is(err, context.Canceled) && uc.stopping() {
+		return model.Errorf(model.ErrShuttingDown, "worker pool stopped: shutting down")
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/repository"
+	"rincon-orlando/go-bootcamp/util/enum"
+	"rincon-orlando/go-bootcamp/workerpool"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUseCase_StopPools - Validates pools stop once StopPools is called, telling shutting down apart from the caller leaving
+func TestUseCase_StopPools(t *testing.T) {
+	mr := &mockRepository{}
+	mr.On("GetAllPokemons").Return(pokemons, nil)
+
+	t.Run("caller went away", func(t *testing.T) {
+		uc := UseCase{repo: mr, lifecycle: newLifecycle()}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := uc.FilterPokemonsConcurrently(ctx, enum.Memory, enum.Odd, 2, 2, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, model.ErrShuttingDown)
+	})
+
+	t.Run("waiting for workers", func(t *testing.T) {
+		budget := workerpool.NewBudget(1, 1, 1, time.Minute)
+		release, err := budget.Acquire(context.Background(), 1)
+		require.Nil(t, err)
+		defer release()
+
+		uc := UseCase{repo: mr, budget: budget, lifecycle: newLifecycle()}
+		done := make(chan error, 1)
+		go func() {
+			_, err := uc.FilterPokemonsConcurrently(context.Background(), enum.Memory, enum.Odd, 1, 2, 1)
+			done <- err
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		uc.StopPools()
+
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, model.ErrShuttingDown)
+		case <-time.After(time.Second):
+			t.Fatal("pool still waiting for workers after StopPools")
+		}
+	})
+
+	t.Run("started afterwards", func(t *testing.T) {
+		uc := UseCase{repo: mr, lifecycle: newLifecycle()}
+		uc.StopPools()
+		uc.StopPools()
+
+		_, err := uc.FilterPokemonsConcurrently(context.Background(), enum.Memory, enum.Odd, 2, 2, 1)
+		assert.ErrorIs(t, err, model.ErrShuttingDown)
+		_, err = uc.StreamSearchPokemons(context.Background(), enum.Memory, func(model.Pokemon) bool { return true }, 2, 2, 1, func(model.Pokemon) {})
+		assert.ErrorIs(t, err, model.ErrShuttingDown)
+	})
+}
+
+// TestUseCase_StopPools_Refresh - Validates a refresh fetching from the API stops too, so Close does not wait for it
+func TestUseCase_StopPools_Refresh(t *testing.T) {
+	hs := newHangingService()
+	uc := &UseCase{repo: repository.New(), csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), service: hs,
+		refresh: &refresher{}, csv: &csvState{}, lifecycle: newLifecycle(), persistMu: &sync.Mutex{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := uc.refreshPokemons(ctx, model.ScheduledRefresh, enum.Replace, false)
+		done <- err
+	}()
+	<-hs.started
+
+	uc.StopPools()
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), time.Second)
+	defer closeCancel()
+	assert.Nil(t, uc.Close(closeCtx))
+	assert.ErrorIs(t, <-done, model.ErrShuttingDown)
+	assert.Equal(t, "failure", uc.RefreshStatus().LastRun.Outcome)
+
+	_, err := uc.RefreshPokemons(context.Background(), enum.Replace, false)
+	assert.ErrorIs(t, err, model.ErrShuttingDown)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hs.calls))
+}
+
+// TestUseCase_Close - Validates Close waits for the writes in progress, and no write starts afterwards
+func TestUseCase_Close(t *testing.T) {
+	mr := &mockRepository{}
+	mr.On("GetAllPokemons").Return(pokemons, nil)
+
+	csvPath := filepath.Join(t.TempDir(), "pokemons.csv")
+	require.Nil(t, os.WriteFile(csvPath, []byte("1,old\n"), 0644))
+	uc := UseCase{repo: mr, csvFileName: csvPath, refresh: &refresher{}, csv: &csvState{}, lifecycle: newLifecycle(), persistMu: &sync.Mutex{}}
+
+	// A refresh in progress
+	uc.refresh.run.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, uc.Close(ctx), context.DeadlineExceeded)
+
+	uc.refresh.run.Unlock()
+	assert.Nil(t, uc.Close(context.Background()))
+
+	assert.ErrorIs(t, uc.SetPokemons(pokemons), model.ErrShuttingDown)
+	_, err := uc.ReloadCsv()
+	assert.ErrorIs(t, err, model.ErrShuttingDown)
+	content, _ := os.ReadFile(csvPath)
+	assert.Equal(t, "1,old\n", string(content))
+}
+
+// Repository counting the changes it gets
+type countingRepository struct {
+	*repository.DB
+	changes int
+}
+
+func (cr *countingRepository) SetPokemons(pokemons []model.Pokemon) error {
+	cr.changes++
+	return cr.DB.SetPokemons(pokemons)
+}
+
+func (cr *countingRepository) CreatePokemon(pokemon model.Pokemon) error {
+	cr.changes++
+	return cr.DB.CreatePokemon(pokemon)
+}
+
+func (cr *countingRepository) UpdatePokemon(pokemon model.Pokemon) error {
+	cr.changes++
+	return cr.DB.UpdatePokemon(pokemon)
+}
+
+func (cr *countingRepository) DeletePokemon(id int) error {
+	cr.changes++
+	return cr.DB.DeletePokemon(id)
+}
+
+// TestUseCase_Close_Writes - Validates changes rejected once closed never reach the repository
+func TestUseCase_Close_Writes(t *testing.T) {
+	testCases := []struct {
+		name  string
+		write func(uc *UseCase) error
+	}{
+		{
+			name: "create",
+			write: func(uc *UseCase) error {
+				_, err := uc.CreatePokemon(model.Pokemon{ID: 4, Name: "charmander"})
+				return err
+			},
+		},
+		{
+			name:  "update",
+			write: func(uc *UseCase) error { return uc.UpdatePokemon(model.Pokemon{ID: 1, Name: "bulbasaur-2"}) },
+		},
+		{
+			name:  "delete",
+			write: func(uc *UseCase) error { return uc.DeletePokemon(2) },
+		},
+		{
+			name:  "set",
+			write: func(uc *UseCase) error { return uc.SetPokemons([]model.Pokemon{{ID: 4, Name: "charmander"}}) },
+		},
+		{
+			name: "sync",
+			write: func(uc *UseCase) error {
+				_, err := uc.SyncPokemons([]model.Pokemon{{ID: 4, Name: "charmander"}}, enum.Replace, false)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &countingRepository{DB: repository.New()}
+			require.Nil(t, db.DB.SetPokemons(pokemons))
+			uc := &UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{},
+				refresh: &refresher{}, lifecycle: newLifecycle()}
+			require.Nil(t, uc.Close(context.Background()))
+
+			assert.ErrorIs(t, tc.write(uc), model.ErrShuttingDown)
+			assert.Equal(t, 0, db.changes)
+			assert.Equal(t, pokemons, allPokemons(t, db))
+			_, err := os.Stat(uc.csvFileName)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"reflect"
+	"sort"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/util/enum"
+)
+
+// SyncPokemons - Combines the local pokemons with the fetched ones as mode says, reporting the ids it touches
+// With dryRun set the report is only a preview and nothing is changed. Nothing is written either when nothing changes.
+// The local pokemons are read, combined and written back under the write lock, so no change made meanwhile gets lost
+func (uc *UseCase) SyncPokemons(fetched []model.Pokemon, mode enum.SyncMode, dryRun bool) (model.SyncReport, error) {
+	if dryRun {
+		local, err := uc.GetAllPokemons()
+		if err != nil {
+			return model.SyncReport{}, err
+		}
+		report, _ := combine(local, fetched, mode)
+		report.DryRun = true
+		return report, nil
+	}
+
+	var report model.SyncReport
+	var previous []model.Pokemon
+	err := uc.write(func() error {
+		var err error
+		if previous, err = uc.GetAllPokemons(); err != nil {
+			return err
+		}
+		var pokemons []model.Pokemon
+		report, pokemons = combine(previous, fetched, mode)
+		if len(report.Added)+len(report.Updated)+len(report.Removed) == 0 {
+			return errUnchanged
+		}
+		if err := uc.repo.SetPokemons(pokemons); err != nil {
+			return storageError(err)
+		}
+		return nil
+	}, func() error {
+		return uc.repo.SetPokemons(previous)
+	})
+	if err != nil {
+		return model.SyncReport{}, err
+	}
+
+	return report, nil
+}
+
+// Combines the local pokemons with the fetched ones as mode says, returning the ids it touches along with the result
+func combine(local []model.Pokemon, fetched []model.Pokemon, mode enum.SyncMode) (model.SyncReport, []model.Pokemon) {
+	report := model.SyncReport{
+		Mode:      mode.String(),
+		Added:     []int{},
+		Updated:   []int{},
+		Unchanged: []int{},
+		Removed:   []int{},
+	}
+
+	result := make(map[int]model.Pokemon, len(local)+len(fetched))
+	for _, pokemon := range local {
+		result[pokemon.ID] = pokemon
+	}
+
+	fetchedIds := make(map[int]bool, len(fetched))
+	for _, pokemon := range fetched {
+		// The API listing a pokemon twice does not make it change twice
+		if fetchedIds[pokemon.ID] {
+			continue
+		}
+		fetchedIds[pokemon.ID] = true
+
+		current, ok := result[pokemon.ID]
+		if mode == enum.Merge && ok {
+			pokemon = mergePokemon(current, pokemon)
+		}
+
+		switch {
+		case !ok:
+			report.Added = append(report.Added, pokemon.ID)
+		case samePokemon(current, pokemon):
+			report.Unchanged = append(report.Unchanged, pokemon.ID)
+		default:
+			report.Updated = append(report.Updated, pokemon.ID)
+		}
+		result[pokemon.ID] = pokemon
+	}
+
+	if mode == enum.Replace {
+		for _, pokemon := range local {
+			if !fetchedIds[pokemon.ID] {
+				report.Removed = append(report.Removed, pokemon.ID)
+				delete(result, pokemon.ID)
+			}
+		}
+	}
+
+	for _, ids := range [][]int{report.Added, report.Updated, report.Unchanged, report.Removed} {
+		sort.Ints(ids)
+	}
+
+	pokemons := make([]model.Pokemon, 0, len(result))
+	for _, pokemon := range result {
+		pokemons = append(pokemons, pokemon)
+	}
+	return report, pokemons
+}
+
+// Compares every field, taking empty and missing lists as the same since backends do not agree on them
+func samePokemon(a model.Pokemon, b model.Pokemon) bool {
+	return reflect.DeepEqual(withoutEmptyLists(a), withoutEmptyLists(b))
+}
+
+func withoutEmptyLists(pokemon model.Pokemon) model.Pokemon {
+	if len(pokemon.Types) == 0 {
+		pokemon.Types = nil
+	}
+	if len(pokemon.Stats) == 0 {
+		pokemon.Stats = nil
+	}
+	if len(pokemon.Abilities) == 0 {
+		pokemon.Abilities = nil
+	}
+	return pokemon
+}
+
+// Takes the fetched pokemon, keeping the local values of the fields it leaves empty
+func mergePokemon(local model.Pokemon, fetched model.Pokemon) model.Pokemon {
+	if fetched.Name == "" {
+		fetched.Name = local.Name
+	}
+	if len(fetched.Types) == 0 {
+		fetched.Types = local.Types
+	}
+	if fetched.Height == 0 {
+		fetched.Height = local.Height
+	}
+	if fetched.Weight == 0 {
+		fetched.Weight = local.Weight
+	}
+	if fetched.BaseExperience == 0 {
+		fetched.BaseExperience = local.BaseExperience
+	}
+	if len(fetched.Abilities) == 0 {
+		fetched.Abilities = local.Abilities
+	}
+	if len(fetched.Stats) == 0 {
+		fetched.Stats = local.Stats
+	}
+	return fetched
+}
@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/repository"
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUseCase_SyncPokemons - Validates every sync mode, along with dry runs
+func TestUseCase_SyncPokemons(t *testing.T) {
+	local := []model.Pokemon{
+		{ID: 1, Name: "bulbasaur", Types: []string{"grass"}, Height: 7},
+		{ID: 2, Name: "ivysaur", Types: []string{}},
+		{ID: 1000, Name: "homebrew"}, // Local-only row
+	}
+	fetched := []model.Pokemon{
+		{ID: 1, Name: "bulbasaur", Weight: 69},
+		{ID: 2, Name: "ivysaur"},
+		{ID: 3, Name: "venusaur"},
+	}
+
+	testCases := []struct {
+		name             string
+		mode             enum.SyncMode
+		dryRun           bool
+		fetched          []model.Pokemon
+		expectedReport   model.SyncReport
+		expectedPokemons []model.Pokemon
+		expectedWrite    bool
+	}{
+		{
+			name:           "replace",
+			mode:           enum.Replace,
+			fetched:        fetched,
+			expectedReport: model.SyncReport{Mode: "replace", Added: []int{3}, Updated: []int{1}, Unchanged: []int{2}, Removed: []int{1000}},
+			expectedPokemons: []model.Pokemon{
+				{ID: 1, Name: "bulbasaur", Weight: 69},
+				{ID: 2, Name: "ivysaur"},
+				{ID: 3, Name: "venusaur"},
+			},
+			expectedWrite: true,
+		},
+		{
+			name:           "merge",
+			mode:           enum.Merge,
+			fetched:        fetched,
+			expectedReport: model.SyncReport{Mode: "merge", Added: []int{3}, Updated: []int{1}, Unchanged: []int{2}, Removed: []int{}},
+			expectedPokemons: []model.Pokemon{
+				{ID: 1, Name: "bulbasaur", Types: []string{"grass"}, Height: 7, Weight: 69},
+				{ID: 2, Name: "ivysaur", Types: []string{}}, // Unchanged rows are left as they were
+				{ID: 3, Name: "venusaur"},
+				{ID: 1000, Name: "homebrew"},
+			},
+			expectedWrite: true,
+		},
+		{
+			name:           "upsert only",
+			mode:           enum.UpsertOnly,
+			fetched:        fetched,
+			expectedReport: model.SyncReport{Mode: "upsert-only", Added: []int{3}, Updated: []int{1}, Unchanged: []int{2}, Removed: []int{}},
+			expectedPokemons: []model.Pokemon{
+				{ID: 1, Name: "bulbasaur", Weight: 69},
+				{ID: 2, Name: "ivysaur"},
+				{ID: 3, Name: "venusaur"},
+				{ID: 1000, Name: "homebrew"},
+			},
+			expectedWrite: true,
+		},
+		{
+			name:             "dry run",
+			mode:             enum.Replace,
+			dryRun:           true,
+			fetched:          fetched,
+			expectedReport:   model.SyncReport{Mode: "replace", DryRun: true, Added: []int{3}, Updated: []int{1}, Unchanged: []int{2}, Removed: []int{1000}},
+			expectedPokemons: local,
+		},
+		{
+			name:             "nothing to change",
+			mode:             enum.Merge,
+			fetched:          []model.Pokemon{{ID: 2, Name: "ivysaur"}, {ID: 1, Name: "bulbasaur"}, {ID: 2, Name: "ivysaur"}},
+			expectedReport:   model.SyncReport{Mode: "merge", Added: []int{}, Updated: []int{}, Unchanged: []int{1, 2}, Removed: []int{}},
+			expectedPokemons: local,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := repository.New()
+			require.Nil(t, db.SetPokemons(local))
+			csvPath := filepath.Join(t.TempDir(), "pokemons.csv")
+			uc := UseCase{repo: db, csvFileName: csvPath, persistMu: &sync.Mutex{}}
+
+			report, err := uc.SyncPokemons(tc.fetched, tc.mode, tc.dryRun)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedReport, report)
+			assert.Equal(t, tc.expectedPokemons, allPokemons(t, db))
+			_, err = os.Stat(csvPath)
+			assert.Equal(t, tc.expectedWrite, err == nil)
+		})
+	}
+}
+
+// Repository running a hook the first time the pokemons are read
+type hookRepository struct {
+	*repository.DB
+	once   sync.Once
+	onRead func()
+}
+
+func (hr *hookRepository) GetAllPokemons() ([]model.Pokemon, error) {
+	hr.once.Do(hr.onRead)
+	return hr.DB.GetAllPokemons()
+}
+
+// TestUseCase_SyncPokemons_ConcurrentWrite - Validates a change made while a sync runs is kept
+func TestUseCase_SyncPokemons_ConcurrentWrite(t *testing.T) {
+	for _, mode := range []enum.SyncMode{enum.Replace, enum.Merge, enum.UpsertOnly} {
+		t.Run(mode.String(), func(t *testing.T) {
+			db := &hookRepository{DB: repository.New()}
+			require.Nil(t, db.DB.SetPokemons(pokemons))
+			uc := &UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+			// The pokemon fetched is renamed while the sync holds the local ones
+			renamed := make(chan error, 1)
+			db.onRead = func() {
+				go func() { renamed <- uc.UpdatePokemon(model.Pokemon{ID: 2, Name: "ivysaur-renamed"}) }()
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			_, err := uc.SyncPokemons([]model.Pokemon{{ID: 1, Name: "bulbasaur"}, {ID: 2, Name: "ivysaur"}, {ID: 4, Name: "charmander"}}, mode, false)
+			require.Nil(t, err)
+			require.Nil(t, <-renamed)
+
+			renamedPokemon, err := db.GetPokemonById(2)
+			require.Nil(t, err)
+			assert.Equal(t, "ivysaur-renamed", renamedPokemon.Name)
+			_, err = db.GetPokemonById(4)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+// TestUseCase_SyncPokemons_PersistError - Validates persistence failures are reported instead of the diff
+func TestUseCase_SyncPokemons_PersistError(t *testing.T) {
+	db := repository.New()
+	uc := UseCase{repo: db, csvFileName: filepath.Join(t.TempDir(), "missing", "pokemons.csv"), persistMu: &sync.Mutex{}}
+
+	report, err := uc.SyncPokemons([]model.Pokemon{{ID: 1, Name: "bulbasaur"}}, enum.Replace, false)
+
+	assert.ErrorIs(t, err, model.ErrPersistence)
+	assert.Equal(t, model.SyncReport{}, report)
+}
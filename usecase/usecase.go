@@ -1,79 +1,151 @@
 package usecase
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"rincon-orlando/go-bootcamp/config"
+	"rincon-orlando/go-bootcamp/metrics"
 	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/util/atomicfile"
 	"rincon-orlando/go-bootcamp/util/enum"
+	"rincon-orlando/go-bootcamp/util/filter"
 	"rincon-orlando/go-bootcamp/workerpool"
 )
 
 type repo interface {
-	GetAllPokemons() []model.Pokemon
+	GetAllPokemons() ([]model.Pokemon, error)
 	GetPokemonById(id int) (*model.Pokemon, error)
-	SetPokemons(pokemons []model.Pokemon)
+	SetPokemons(pokemons []model.Pokemon) error
+	CreatePokemon(pokemon model.Pokemon) error
+	UpdatePokemon(pokemon model.Pokemon) error
+	DeletePokemon(id int) error
 }
 
 type service interface {
-	FetchPokemonsFromApi() ([]model.Pokemon, error)
+	FetchPokemonsFromApi(ctx context.Context) ([]model.Pokemon, error)
+	UpstreamStatus() model.UpstreamStatus
 }
 
 // UseCase - Definition of a usecase layer, combining a repo, a csv filename + service (external API client)
 type UseCase struct {
 	repo        repo
 	csvFileName string
+	csvBackup   bool // Whether the previous csv contents are kept in a .bak file on every write
 	service     service
+	modified    *lastModified      // Shared by every copy of the UseCase. nil means changes are not tracked
+	refresh     *refresher         // Shared by every copy of the UseCase
+	budget      *workerpool.Budget // Workers every pool leases before starting. nil means no limit
+	csv         *csvState          // Shared by every copy of the UseCase. nil means the csv contents are not tracked
+	lifecycle   *lifecycle         // Shared by every copy of the UseCase. nil means it never shuts down
+	persistMu   *sync.Mutex        // Shared by every copy of the UseCase. Serializes changes along with their csv writes
+}
+
+// Time of the last change to the pokemons, along with a counter moving on every change
+type lastModified struct {
+	mu      sync.RWMutex
+	time    time.Time
+	version uint64
+}
+
+// Contents of the csv file as last read or written, guarded by persistMu
+// Tells reloads apart from the file changes persist makes itself
+type csvState struct {
+	sum [sha256.Size]byte
 }
 
 // Great help
 // https://golangcode.com/how-to-read-a-csv-file-into-a-struct/
 
-// New - UseCase factory
-func New(repo repo, csvFilename string, service service) (*UseCase, error) {
+// New - UseCase factory. budget caps the workers of every concurrent filter and search, nil means no limit
+// An empty repository is seeded with the pokemons in the csv file. Otherwise the repository is where they survive
+// restarts, so it is kept as it is and the csv file is written out of it. Importing replaces them on purpose
+func New(repo repo, csvFilename string, csvBackup bool, service service, budget *workerpool.Budget) (*UseCase, error) {
+	// Build a new empty DB
+	newUseCase := &UseCase{repo, csvFilename, csvBackup, service, &lastModified{version: uint64(time.Now().UnixNano())}, &refresher{}, budget, &csvState{}, newLifecycle(), &sync.Mutex{}}
+
+	// A repository that cannot be read is not the same as an empty one, reseeding it would wipe what it holds
+	stored, err := repo.GetAllPokemons()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if len(stored) > 0 {
+		newUseCase.persistMu.Lock()
+		defer newUseCase.persistMu.Unlock()
+		if err := newUseCase.persist(); err != nil {
+			return nil, err
+		}
+		return newUseCase, nil
+	}
+
+	v, sum, err := readCsv(csvFilename)
+	if err != nil {
+		return nil, err
+	}
+	newUseCase.csv.sum = sum
+	// Then initialize the new DB with this particular set of Pokemons
+	if err := newUseCase.repo.SetPokemons(v); err != nil {
+		return nil, err
+	}
+	metrics.RepositorySize.Set(float64(len(v)))
+	newUseCase.touch()
+
+	return newUseCase, nil
+}
+
+// Reads every pokemon in the csv file, along with the checksum of its contents
+func readCsv(csvFilename string) ([]model.Pokemon, [sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
 	// Open CSV file
 	f, err := os.Open(csvFilename)
 	if err != nil {
-		return nil, err
+		return nil, sum, err
 	}
 	defer f.Close()
 
 	// Read file into a variable
-	lines, err := csv.NewReader(f).ReadAll()
+	// Rows may have a variable number of columns, since only id and name are mandatory
+	hash := sha256.New()
+	reader := csv.NewReader(io.TeeReader(f, hash))
+	reader.FieldsPerRecord = -1
+	lines, err := reader.ReadAll()
 	if err != nil {
-		return nil, err
+		return nil, sum, err
 	}
 
 	v := make([]model.Pokemon, 0, len(lines))
 
 	// Build pokemons slice out of the file lines
 	for _, line := range lines {
-		id, err := strconv.Atoi(line[0])
+		pokemon, err := parseLine(line)
 		if err != nil {
-			return nil, errors.New("Error converting " + line[0] + " to int")
-		}
-		pokemon := model.Pokemon{
-			ID:   id,
-			Name: line[1],
+			return nil, sum, err
 		}
 		v = append(v, pokemon)
 	}
 
-	// Build a new empty DB
-	newUseCase := &UseCase{repo, csvFilename, service}
-	// Then initialize the new DB with this particular set of Pokemons
-	newUseCase.repo.SetPokemons(v)
-
-	return newUseCase, nil
+	copy(sum[:], hash.Sum(nil))
+	return v, sum, nil
 }
 
 // GetAllPokemons - Returns a slice of all Pokemons available in this repository
-func (uc UseCase) GetAllPokemons() []model.Pokemon {
-	return uc.repo.GetAllPokemons()
+func (uc UseCase) GetAllPokemons() ([]model.Pokemon, error) {
+	pokemons, err := uc.repo.GetAllPokemons()
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return pokemons, nil
 }
 
 // GetPokemonById - Returns a pokemon given its id
@@ -83,97 +155,448 @@ func (uc UseCase) GetPokemonById(id int) (*model.Pokemon, error) {
 
 // SetPokemons - Updates the internal repository with a new set of Pokemons
 // Pointer as receiver so internal db can be modified
-func (uc *UseCase) SetPokemons(pokemons []model.Pokemon) {
-	uc.repo.SetPokemons(pokemons)
-	// Once internal data is updated, persist it into the csv file
-	uc.persist(pokemons)
+// The previous set is restored if persisting the new one fails
+func (uc *UseCase) SetPokemons(pokemons []model.Pokemon) error {
+	var previous []model.Pokemon
+	return uc.write(func() error {
+		var err error
+		if previous, err = uc.GetAllPokemons(); err != nil {
+			return err
+		}
+		if err := uc.repo.SetPokemons(pokemons); err != nil {
+			return storageError(err)
+		}
+		return nil
+	}, func() error {
+		return uc.repo.SetPokemons(previous)
+	})
 }
 
-// FetchPokemonsFromApi - Returns a slice of Pokemons from external API
-func (uc UseCase) FetchPokemonsFromApi() ([]model.Pokemon, error) {
-	return uc.service.FetchPokemonsFromApi()
+// LastModified - Returns when the pokemons last changed, with the second precision of http dates
+// The zero time means changes are not tracked
+func (uc UseCase) LastModified() time.Time {
+	if uc.modified == nil {
+		return time.Time{}
+	}
+	uc.modified.mu.RLock()
+	defer uc.modified.mu.RUnlock()
+	return uc.modified.time
 }
 
-func (uc UseCase) persist(pokemons []model.Pokemon) error {
-	file, err := os.Create(uc.csvFileName)
+// Version - Returns a number that changes on every change to the pokemons, unlike the second precision LastModified
+// It starts out of the startup time, so versions are not handed out again after a restart. 0 means changes are not tracked
+func (uc UseCase) Version() uint64 {
+	if uc.modified == nil {
+		return 0
+	}
+	uc.modified.mu.RLock()
+	defer uc.modified.mu.RUnlock()
+	return uc.modified.version
+}
 
+// Records a change to the pokemons
+func (uc UseCase) touch() {
+	if uc.modified == nil {
+		return
+	}
+	uc.modified.mu.Lock()
+	defer uc.modified.mu.Unlock()
+	uc.modified.time = time.Now().UTC().Truncate(time.Second)
+	uc.modified.version++
+}
+
+// FetchPokemonsFromApi - Returns a slice of Pokemons from external API, giving up once ctx is done
+func (uc UseCase) FetchPokemonsFromApi(ctx context.Context) ([]model.Pokemon, error) {
+	return uc.service.FetchPokemonsFromApi(ctx)
+}
+
+// UpstreamStatus - Returns whether the external API is reachable, as seen by its client
+func (uc UseCase) UpstreamStatus() model.UpstreamStatus {
+	return uc.service.UpstreamStatus()
+}
+
+// Returned by a change leaving the repository as it was, so there is nothing to persist
+var errUnchanged = errors.New("nothing changed")
+
+// Applies a change to the repository, then persists it into the csv file. persistMu is held all along,
+// so concurrent changes never interleave and the file always ends up with the latest repository contents.
+// undo reverts the change when it cannot be persisted, keeping the repository and the file in agreement.
+// Once closed, the repository is left untouched and a model.ErrShuttingDown is returned
+func (uc UseCase) write(change func() error, undo func() error) error {
+	uc.persistMu.Lock()
+	defer uc.persistMu.Unlock()
+	if uc.closed() {
+		return model.Errorf(model.ErrShuttingDown, "changing pokemons: shutting down")
+	}
+
+	if err := change(); err != nil {
+		if err == errUnchanged {
+			return nil
+		}
+		return err
+	}
+	err := uc.persist()
+	if err != nil {
+		if undoErr := undo(); undoErr != nil {
+			log.Printf("Error reverting a change that could not be persisted, the repository and %s disagree: %s", uc.csvFileName, undoErr)
+		}
+	}
+	return err
+}
+
+// Writes the repository contents into the csv file, atomically replacing the old one. persistMu must be held
+// Every change to the repository is followed by a persist, so this is also where changes are recorded
+func (uc UseCase) persist() error {
+	uc.touch()
+
+	// Writing out an unreadable repository would leave an empty csv file behind
+	pokemons, err := uc.GetAllPokemons()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	started := time.Now()
+	hash := sha256.New()
+	err = atomicfile.Write(uc.csvFileName, uc.csvBackup, func(w io.Writer) error {
+		writer := csv.NewWriter(io.MultiWriter(w, hash))
+		for _, value := range pokemons {
+			if err := writer.Write(formatLine(value)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		metrics.PersistDuration.WithLabelValues("failure").Observe(time.Since(started).Seconds())
+		return model.Errorf(model.ErrPersistence, "persisting pokemons into %s: %w", uc.csvFileName, err)
+	}
+	metrics.PersistDuration.WithLabelValues("success").Observe(time.Since(started).Seconds())
+	metrics.RepositorySize.Set(float64(len(pokemons)))
+	if uc.csv != nil {
+		copy(uc.csv.sum[:], hash.Sum(nil))
+	}
 
-	for _, value := range pokemons {
-		line := []string{strconv.FormatInt(int64(value.ID), 10), value.Name}
-		err := writer.Write(line)
-		if err != nil {
-			return err
+	return nil
+}
+
+// Marks repository failures as persistence errors, unless they already tell their kind
+func storageError(err error) error {
+	var kindErr *model.Error
+	if errors.As(err, &kindErr) {
+		return err
+	}
+	return model.Errorf(model.ErrPersistence, "storing pokemons: %w", err)
+}
+
+// CSV columns, in file order. Only id and name are mandatory so older files are still readable
+const (
+	colID = iota
+	colName
+	colTypes
+	colHeight
+	colWeight
+	colBaseExperience
+	colAbilities
+	colStats
+	numColumns
+)
+
+// Header names of the CSV columns, matching the pokemon JSON field names
+var columnNames = [numColumns]string{
+	colID:             "id",
+	colName:           "name",
+	colTypes:          "types",
+	colHeight:         "height",
+	colWeight:         "weight",
+	colBaseExperience: "base_experience",
+	colAbilities:      "abilities",
+	colStats:          "stats",
+}
+
+// Separators for the multi-valued columns, i.e. "grass|poison" or "hp:45|attack:49"
+const (
+	listSeparator = "|"
+	statSeparator = ":"
+)
+
+// Builds a Pokemon out of a CSV line
+func parseLine(line []string) (model.Pokemon, error) {
+	var pokemon model.Pokemon
+	if err := parseField(&pokemon, colID, line[colID]); err != nil {
+		return model.Pokemon{}, err
+	}
+	if len(line) < colTypes {
+		return model.Pokemon{}, model.Errorf(model.ErrValidation, "Expected at least %d columns, got %d", colTypes, len(line))
+	}
+
+	for col := colName; col < numColumns && col < len(line); col++ {
+		if err := parseField(&pokemon, col, line[col]); err != nil {
+			return model.Pokemon{}, err
+		}
+	}
+
+	return pokemon, nil
+}
+
+// Sets the pokemon field stored in the given CSV column out of its value
+// Optional columns may be empty, leaving the field unset
+func parseField(pokemon *model.Pokemon, col int, value string) error {
+	var err error
+	switch col {
+	case colID:
+		if pokemon.ID, err = strconv.Atoi(value); err != nil {
+			return model.Errorf(model.ErrValidation, "Error converting %s to int", value)
+		}
+	case colName:
+		pokemon.Name = value
+	case colTypes:
+		pokemon.Types = splitList(value)
+	case colAbilities:
+		pokemon.Abilities = splitList(value)
+	case colHeight, colWeight, colBaseExperience:
+		if value == "" {
+			return nil
+		}
+		target := map[int]*int{
+			colHeight:         &pokemon.Height,
+			colWeight:         &pokemon.Weight,
+			colBaseExperience: &pokemon.BaseExperience,
+		}[col]
+		if *target, err = strconv.Atoi(value); err != nil {
+			return model.Errorf(model.ErrValidation, "Error converting %s to int", value)
+		}
+	case colStats:
+		pokemon.Stats = nil
+		for _, entry := range splitList(value) {
+			parts := strings.SplitN(entry, statSeparator, 2)
+			if len(parts) != 2 {
+				return model.Errorf(model.ErrValidation, "Error parsing stat %s", entry)
+			}
+			baseStat, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return model.Errorf(model.ErrValidation, "Error converting %s to int", parts[1])
+			}
+			pokemon.Stats = append(pokemon.Stats, model.Stat{Name: parts[0], BaseStat: baseStat})
 		}
 	}
 
 	return nil
 }
 
+// Turns a Pokemon into a CSV line
+func formatLine(pokemon model.Pokemon) []string {
+	stats := make([]string, 0, len(pokemon.Stats))
+	for _, stat := range pokemon.Stats {
+		stats = append(stats, stat.Name+statSeparator+strconv.Itoa(stat.BaseStat))
+	}
+
+	return []string{
+		colID:             strconv.Itoa(pokemon.ID),
+		colName:           pokemon.Name,
+		colTypes:          strings.Join(pokemon.Types, listSeparator),
+		colHeight:         strconv.Itoa(pokemon.Height),
+		colWeight:         strconv.Itoa(pokemon.Weight),
+		colBaseExperience: strconv.Itoa(pokemon.BaseExperience),
+		colAbilities:      strings.Join(pokemon.Abilities, listSeparator),
+		colStats:          strings.Join(stats, listSeparator),
+	}
+}
+
+// Splits a multi-valued column. An empty column means no values at all
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, listSeparator)
+}
+
 type pokeTask struct {
 	pokemon   model.Pokemon
 	processor func(model.Pokemon) bool
 }
 
 // Run - Poketask method execution, ready to send results to input channel
-func (pt pokeTask) Run(ch chan<- interface{}) bool {
+func (pt pokeTask) Run(ctx context.Context, ch chan<- interface{}) bool {
 	if pt.processor(pt.pokemon) {
-		// Inject the pokemon to the output channel
-		ch <- pt.pokemon
-		return true
+		// Inject the pokemon to the output channel, unless nobody is listening anymore
+		select {
+		case ch <- pt.pokemon:
+			return true
+		case <-ctx.Done():
+			return false
+		}
 	}
 	return false
 }
 
-// FilterPokemonsConcurrently - Configures and executes a worker pool to extract a set of pokemons based off the given criteria
-func (uc UseCase) FilterPokemonsConcurrently(oe enum.OddEven, numWorkers int, items int, ipw int) []model.Pokemon {
-	// First, get all pokemons to work
-	allPokemons := uc.GetAllPokemons()
-
-	fmt.Printf("Worker config: numWorkers %d, items = %d, items_per_worker = %d\n", numWorkers, items, ipw)
-
-	// TODO: I may want to move the dependency injection somewhere else
-	config := config.NewPoolConfig(oe, numWorkers, items, ipw)
-	pool := workerpool.New(numWorkers, config)
-
-	// Process a Pokemon, to verify if matches what we are looking for
-	filterPokemon := func(pokemon model.Pokemon) bool {
+// Process a Pokemon, to verify if matches the odd/even criteria
+func oddEvenProcessor(oe enum.OddEven) func(model.Pokemon) bool {
+	return func(pokemon model.Pokemon) bool {
 		if oe == enum.Even {
 			return pokemon.IsEven()
 		}
 		return !pokemon.IsEven()
 	}
+}
+
+// FilterPokemonsConcurrently - Configures and executes a worker pool to extract a set of odd/even pokemons out of src
+// Workers stop as soon as ctx is done, in which case the context error is returned
+func (uc UseCase) FilterPokemonsConcurrently(ctx context.Context, src enum.Source, oe enum.OddEven, numWorkers int, items int, ipw int) ([]model.Pokemon, error) {
+	return uc.runPool(ctx, src, config.NewPoolConfig(oe, numWorkers, items, ipw), oddEvenProcessor(oe))
+}
+
+// StreamFilterPokemons - Same as FilterPokemonsConcurrently, but hands every pokemon to emit as soon as a worker finds it
+// Tells why the pool stopped
+func (uc UseCase) StreamFilterPokemons(ctx context.Context, src enum.Source, oe enum.OddEven, numWorkers int, items int, ipw int, emit func(model.Pokemon)) (workerpool.StopReason, error) {
+	return uc.streamPool(ctx, src, config.NewPoolConfig(oe, numWorkers, items, ipw), oddEvenProcessor(oe), emit)
+}
+
+// SearchPokemonsConcurrently - Configures and executes a worker pool to extract a set of pokemons out of src matching the given predicate
+// Workers stop as soon as ctx is done, in which case the context error is returned
+func (uc UseCase) SearchPokemonsConcurrently(ctx context.Context, src enum.Source, predicate filter.Predicate, numWorkers int, items int, ipw int) ([]model.Pokemon, error) {
+	return uc.runPool(ctx, src, config.NewPoolConfig(enum.Undefined, numWorkers, items, ipw), predicate)
+}
+
+// StreamSearchPokemons - Same as SearchPokemonsConcurrently, but hands every pokemon to emit as soon as a worker finds it
+// Tells why the pool stopped
+func (uc UseCase) StreamSearchPokemons(ctx context.Context, src enum.Source, predicate filter.Predicate, numWorkers int, items int, ipw int, emit func(model.Pokemon)) (workerpool.StopReason, error) {
+	return uc.streamPool(ctx, src, config.NewPoolConfig(enum.Undefined, numWorkers, items, ipw), predicate, emit)
+}
+
+// Runs every pokemon through the given processor in a worker pool, collecting the matching ones
+func (uc UseCase) runPool(ctx context.Context, src enum.Source, cfg config.GoRoutinePoolConfig, processor func(model.Pokemon) bool) ([]model.Pokemon, error) {
+	response := make([]model.Pokemon, 0)
+	_, err := uc.streamPool(ctx, src, cfg, processor, func(pokemon model.Pokemon) {
+		response = append(response, pokemon)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Task config
-	var tasks []pokeTask
-	for _, p := range allPokemons {
-		tasks = append(tasks, pokeTask{
-			pokemon:   p,
-			processor: filterPokemon,
+	return response, nil
+}
+
+// Function handing pokemons to a worker pool. schedule returns false once the pool does not take more work
+type producer func(schedule func(model.Pokemon) bool) error
+
+// Runs every pokemon of src through the given processor in a worker pool, handing the matching ones to emit as they arrive
+func (uc UseCase) streamPool(ctx context.Context, src enum.Source, cfg config.GoRoutinePoolConfig, processor func(model.Pokemon) bool, emit func(model.Pokemon)) (workerpool.StopReason, error) {
+	produce := uc.produceFromMemory
+	if src == enum.CSV {
+		produce = uc.produceFromCsv
+	}
+
+	fmt.Printf("Worker config: numWorkers %d, items = %d, items_per_worker = %d\n", cfg.NumWorkers, cfg.Items, cfg.ItemsPerWorker)
+
+	if uc.stopping() {
+		return workerpool.Cancelled, uc.stoppedError(context.Canceled)
+	}
+	// The producer stops the pool if it fails, so it needs its own way to cancel it. So does shutting down
+	ctx, cancel := uc.poolContext(ctx)
+	defer cancel()
+
+	// Released last, once every worker of the pool is gone
+	if uc.budget != nil {
+		release, err := uc.budget.Acquire(ctx, cfg.NumWorkers)
+		if err != nil {
+			return workerpool.Cancelled, uc.stoppedError(admissionError(err))
+		}
+		defer release()
+	}
+
+	pool := workerpool.New(ctx, cfg.NumWorkers, cfg)
+	defer pool.Close()
+
+	// Scheduling work from a different go routine, so the queue does not block before monitoring starts
+	produceErr := make(chan error, 1)
+	go func() {
+		err := produce(func(p model.Pokemon) bool {
+			return pool.ScheduleWork(pokeTask{
+				pokemon:   p,
+				processor: processor,
+			})
 		})
+		if err != nil {
+			produceErr <- err
+			cancel()
+			return
+		}
+		// Let the workers know when there is nothing else to process (EOF)
+		pool.CloseQueue()
+	}()
+
+	// Worker pool hands generic interface elements, so turn them into pokemons
+	reason, err := pool.MonitorFunc(func(entry interface{}) {
+		emit(entry.(model.Pokemon))
+	})
+
+	// A failing producer is the actual reason the pool was cancelled
+	select {
+	case perr := <-produceErr:
+		return workerpool.Cancelled, perr
+	default:
+		return reason, uc.stoppedError(err)
 	}
+}
 
-	// Scheduling work
-	for _, task := range tasks {
-		pool.ScheduleWork(task)
+// Tells the kind of a failure to lease workers. Context errors are left as they are
+func admissionError(err error) error {
+	switch {
+	case errors.Is(err, workerpool.ErrTooManyWorkers):
+		return model.Errorf(model.ErrValidation, "%w", err)
+	case errors.Is(err, workerpool.ErrQueueFull):
+		return model.Errorf(model.ErrTooManyRequests, "%w", err)
+	case errors.Is(err, workerpool.ErrQueueTimeout):
+		return model.Errorf(model.ErrOverloaded, "%w", err)
 	}
+	return err
+}
 
-	// Worker pool returns a generic interface element
-	genericResponse := pool.Monitor()
+// Hands every pokemon in the repository to the pool
+func (uc UseCase) produceFromMemory(schedule func(model.Pokemon) bool) error {
+	pokemons, err := uc.GetAllPokemons()
+	if err != nil {
+		return err
+	}
+	for _, p := range pokemons {
+		if !schedule(p) {
+			// Pool stopped, nobody will take more work
+			return nil
+		}
+	}
+	return nil
+}
 
-	// So, turn those interfaces into pokemons
-	response := make([]model.Pokemon, len(genericResponse))
-	for i, v := range genericResponse {
-		response[i] = v.(model.Pokemon)
+// Reads the CSV file row by row, handing every pokemon to the pool as soon as it is read
+// Only one row is held at a time, so memory stays flat no matter the file size
+func (uc UseCase) produceFromCsv(schedule func(model.Pokemon) bool) error {
+	f, err := os.Open(uc.csvFileName)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
 
-	pool.Close()
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 
-	return response
+		pokemon, err := parseLine(line)
+		if err != nil {
+			return err
+		}
+		if !schedule(pokemon) {
+			// Pool stopped, no need to keep reading
+			return nil
+		}
+	}
 }
@@ -1,14 +1,29 @@
 package usecase
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"rincon-orlando/go-bootcamp/metrics"
 	"rincon-orlando/go-bootcamp/model"
+	"rincon-orlando/go-bootcamp/repository"
 	"rincon-orlando/go-bootcamp/util/enum"
+	"rincon-orlando/go-bootcamp/util/filter"
+	"rincon-orlando/go-bootcamp/workerpool"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 var pokemons = []model.Pokemon{
@@ -17,33 +32,63 @@ var pokemons = []model.Pokemon{
 	{ID: 3, Name: "venusaur"},
 }
 
+// Every pokemon in store, failing the test when they cannot be read
+func allPokemons(t *testing.T, store interface {
+	GetAllPokemons() ([]model.Pokemon, error)
+}) []model.Pokemon {
+	all, err := store.GetAllPokemons()
+	assert.Nil(t, err)
+	return all
+}
+
 type mockRepository struct {
 	mock.Mock
 }
 
-func (mr mockRepository) GetAllPokemons() []model.Pokemon {
+func (mr *mockRepository) GetAllPokemons() ([]model.Pokemon, error) {
 	arg := mr.Called()
-	return arg.Get(0).([]model.Pokemon)
+	return arg.Get(0).([]model.Pokemon), arg.Error(1)
 }
 
-func (mr mockRepository) GetPokemonById(id int) (*model.Pokemon, error) {
+func (mr *mockRepository) GetPokemonById(id int) (*model.Pokemon, error) {
 	arg := mr.Called()
 	return arg.Get(0).(*model.Pokemon), arg.Error(1)
 }
 
-func (mr mockRepository) SetPokemons(pokemons []model.Pokemon) {
+func (mr *mockRepository) SetPokemons(pokemons []model.Pokemon) error {
 	// Do nothing, but needs to be mocked to comply with the interface contract
+	return nil
+}
+
+func (mr *mockRepository) CreatePokemon(pokemon model.Pokemon) error {
+	arg := mr.Called()
+	return arg.Error(0)
+}
+
+func (mr *mockRepository) UpdatePokemon(pokemon model.Pokemon) error {
+	arg := mr.Called()
+	return arg.Error(0)
+}
+
+func (mr *mockRepository) DeletePokemon(id int) error {
+	arg := mr.Called()
+	return arg.Error(0)
 }
 
 type mockService struct {
 	mock.Mock
 }
 
-func (ms mockService) FetchPokemonsFromApi() ([]model.Pokemon, error) {
+func (ms *mockService) FetchPokemonsFromApi(ctx context.Context) ([]model.Pokemon, error) {
 	arg := ms.Called()
 	return arg.Get(0).([]model.Pokemon), arg.Error(1)
 }
 
+func (ms *mockService) UpstreamStatus() model.UpstreamStatus {
+	arg := ms.Called()
+	return arg.Get(0).(model.UpstreamStatus)
+}
+
 // TestUseCase_New - Test UseCase factory method
 func TestUseCase_New(t *testing.T) {
 	testCases := []struct {
@@ -60,6 +105,13 @@ func TestUseCase_New(t *testing.T) {
 			hasError:           false,
 			error:              nil,
 		},
+		{
+			name:               "new use case with detail columns",
+			csvPath:            "./test_csv/pokemons_full.csv",
+			repositoryPokemons: pokemons,
+			hasError:           false,
+			error:              nil,
+		},
 		{
 			name:               "missing csv file",
 			csvPath:            "./test_csv/pokemons_missing.csv",
@@ -78,9 +130,10 @@ func TestUseCase_New(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			mr := mockRepository{}
-			ms := mockService{}
-			uc, err := New(mr, tc.csvPath, ms)
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return([]model.Pokemon{}, nil)
+			ms := &mockService{}
+			uc, err := New(mr, tc.csvPath, false, ms, nil)
 
 			if tc.hasError {
 				assert.Nil(t, uc)
@@ -93,6 +146,149 @@ func TestUseCase_New(t *testing.T) {
 	}
 }
 
+// TestUseCase_New_Stored - Validates pokemons stored by a durable backend survive a restart, instead of the csv file seeding it again
+func TestUseCase_New_Stored(t *testing.T) {
+	for name, backend := range map[string]enum.Backend{"sqlite": enum.SQLiteBackend, "bolt": enum.BoltBackend} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			dbPath := filepath.Join(dir, "pokemons.db")
+			csvPath := filepath.Join(dir, "pokemons.csv")
+			require.Nil(t, os.WriteFile(csvPath, []byte("1,bulbasaur\n"), 0644))
+
+			// First run seeds the empty backend out of the csv file, then changes it
+			store, err := repository.Open(backend, dbPath)
+			require.Nil(t, err)
+			uc, err := New(store, csvPath, false, &mockService{}, nil)
+			require.Nil(t, err)
+			assert.Equal(t, []model.Pokemon{{ID: 1, Name: "bulbasaur"}}, allPokemons(t, uc))
+			_, err = uc.CreatePokemon(model.Pokemon{ID: 4, Name: "charmander"})
+			require.Nil(t, err)
+			version := uc.Version()
+			require.Nil(t, store.Close())
+
+			// The csv file changing while the app is down does not replace what the backend stored
+			require.Nil(t, os.WriteFile(csvPath, []byte("7,squirtle\n"), 0644))
+			store, err = repository.Open(backend, dbPath)
+			require.Nil(t, err)
+			defer store.Close()
+			uc, err = New(store, csvPath, false, &mockService{}, nil)
+			require.Nil(t, err)
+			// Versions handed out before the restart never come back
+			assert.Greater(t, uc.Version(), version)
+
+			expected := []model.Pokemon{{ID: 1, Name: "bulbasaur"}, {ID: 4, Name: "charmander"}}
+			assert.Equal(t, len(expected), len(allPokemons(t, uc)))
+			for _, pokemon := range expected {
+				stored, err := uc.GetPokemonById(pokemon.ID)
+				require.Nil(t, err)
+				assert.Equal(t, pokemon.Name, stored.Name)
+			}
+			content, _ := os.ReadFile(csvPath)
+			assert.Equal(t, "1,bulbasaur,,0,0,0,,\n4,charmander,,0,0,0,,\n", string(content))
+
+			// The csv file written is known, so it is not reloaded
+			reloaded, err := uc.ReloadCsv()
+			assert.Nil(t, err)
+			assert.False(t, reloaded)
+		})
+	}
+}
+
+// TestUseCase_parseLine - Validates a CSV line is turned into a pokemon
+func TestUseCase_parseLine(t *testing.T) {
+	testCases := []struct {
+		name            string
+		line            []string
+		expectedPokemon model.Pokemon
+		hasError        bool
+		error           error
+	}{
+		{
+			name:            "id and name only",
+			line:            []string{"2", "ivysaur"},
+			expectedPokemon: model.Pokemon{ID: 2, Name: "ivysaur"},
+		},
+		{
+			name: "all columns",
+			line: []string{"1", "bulbasaur", "grass|poison", "7", "69", "64", "overgrow|chlorophyll", "hp:45|attack:49"},
+			expectedPokemon: model.Pokemon{
+				ID:             1,
+				Name:           "bulbasaur",
+				Types:          []string{"grass", "poison"},
+				Stats:          []model.Stat{{Name: "hp", BaseStat: 45}, {Name: "attack", BaseStat: 49}},
+				Height:         7,
+				Weight:         69,
+				BaseExperience: 64,
+				Abilities:      []string{"overgrow", "chlorophyll"},
+			},
+		},
+		{
+			name:     "missing name",
+			line:     []string{"1"},
+			hasError: true,
+			error:    errors.New("Expected at least 2 columns, got 1"),
+		},
+		{
+			name:     "wrong height",
+			line:     []string{"1", "bulbasaur", "grass", "tall"},
+			hasError: true,
+			error:    errors.New("Error converting tall to int"),
+		},
+		{
+			name:     "wrong stat",
+			line:     []string{"1", "bulbasaur", "", "", "", "", "", "hp"},
+			hasError: true,
+			error:    errors.New("Error parsing stat hp"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pokemon, err := parseLine(tc.line)
+			if tc.hasError {
+				assert.EqualError(t, err, tc.error.Error())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedPokemon, pokemon)
+			}
+		})
+	}
+}
+
+// TestUseCase_formatLine - Validates a pokemon survives a round trip through the CSV format
+func TestUseCase_formatLine(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pokemon model.Pokemon
+	}{
+		{
+			name:    "id and name only",
+			pokemon: model.Pokemon{ID: 2, Name: "ivysaur"},
+		},
+		{
+			name: "all fields",
+			pokemon: model.Pokemon{
+				ID:             1,
+				Name:           "bulbasaur",
+				Types:          []string{"grass", "poison"},
+				Stats:          []model.Stat{{Name: "hp", BaseStat: 45}},
+				Height:         7,
+				Weight:         69,
+				BaseExperience: 64,
+				Abilities:      []string{"overgrow"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pokemon, err := parseLine(formatLine(tc.pokemon))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.pokemon, pokemon)
+		})
+	}
+}
+
 // TestUseCase_GetAllPokemons - Vaidates use case GetAllPokemons method
 func TestUseCase_GetAllPokemons(t *testing.T) {
 	testCases := []struct {
@@ -109,12 +305,12 @@ func TestUseCase_GetAllPokemons(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			mr := mockRepository{}
-			mr.On("GetAllPokemons").Return(tc.repositoryPokemons)
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(tc.repositoryPokemons, nil)
 
 			uc := UseCase{repo: mr}
 
-			assert.EqualValues(t, tc.expectedUseCasePokemons, uc.repo.GetAllPokemons())
+			assert.EqualValues(t, tc.expectedUseCasePokemons, allPokemons(t, uc.repo))
 		})
 	}
 }
@@ -152,7 +348,7 @@ func TestUseCase_GetPokemonById(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			mr := mockRepository{}
+			mr := &mockRepository{}
 			mr.On("GetPokemonById").Return(tc.repositoryPokemon, tc.repositoryError)
 
 			uc := UseCase{repo: mr}
@@ -166,10 +362,116 @@ func TestUseCase_GetPokemonById(t *testing.T) {
 	}
 }
 
-// TestUseCase_SetPokemons - Validates use case SetPokemons method
+// Csv writes observed so far with the given outcome
+func observedPersists(t *testing.T, outcome string) uint64 {
+	var m dto.Metric
+	assert.Nil(t, metrics.PersistDuration.WithLabelValues(outcome).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+// TestUseCase_SetPokemons - Validates use case SetPokemons method persists the repository, observing how it went
 func TestUseCase_SetPokemons(t *testing.T) {
-	// There is no real point in testing this. SetPokemons is a plain proxy to the repository
-	// and it will to write a file which is out of the scope of this
+	testCases := []struct {
+		name        string
+		csvBackup   bool
+		unwritable  bool
+		expectedCsv string
+		expectedBak string
+		hasError    bool
+	}{
+		{
+			name:        "persist pokemons",
+			expectedCsv: "1,bulbasaur,,0,0,0,,\n2,ivysaur,,0,0,0,,\n3,venusaur,,0,0,0,,\n",
+		},
+		{
+			name:        "persist pokemons keeping a backup",
+			csvBackup:   true,
+			expectedCsv: "1,bulbasaur,,0,0,0,,\n2,ivysaur,,0,0,0,,\n3,venusaur,,0,0,0,,\n",
+			expectedBak: "1,old\n",
+		},
+		{
+			name:        "persist into a missing directory",
+			unwritable:  true,
+			hasError:    true,
+			expectedCsv: "1,old\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			csvPath := filepath.Join(dir, "pokemons.csv")
+			assert.Nil(t, os.WriteFile(csvPath, []byte("1,old\n"), 0644))
+
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(pokemons, nil)
+
+			uc := UseCase{repo: mr, csvFileName: csvPath, csvBackup: tc.csvBackup, persistMu: &sync.Mutex{}}
+			if tc.unwritable {
+				uc.csvFileName = filepath.Join(dir, "missing", "pokemons.csv")
+			}
+
+			outcome := "success"
+			if tc.hasError {
+				outcome = "failure"
+			}
+			persists := observedPersists(t, outcome)
+
+			err := uc.SetPokemons(pokemons)
+			if tc.hasError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, persists+1, observedPersists(t, outcome))
+			assert.Equal(t, float64(len(pokemons)), testutil.ToFloat64(metrics.RepositorySize))
+
+			content, _ := os.ReadFile(csvPath)
+			assert.Equal(t, tc.expectedCsv, string(content))
+
+			bak, err := os.ReadFile(csvPath + ".bak")
+			if tc.expectedBak == "" {
+				assert.True(t, os.IsNotExist(err))
+			} else {
+				assert.Equal(t, tc.expectedBak, string(bak))
+			}
+		})
+	}
+}
+
+// TestUseCase_LastModified - Validates every change moves the last modification time, even when persisting it fails
+func TestUseCase_LastModified(t *testing.T) {
+	mr := &mockRepository{}
+	mr.On("GetAllPokemons").Return(pokemons, nil)
+	mr.On("GetPokemonById").Return(&pokemons[0], nil)
+	mr.On("DeletePokemon").Return(nil)
+	mr.On("CreatePokemon").Return(nil)
+
+	uc := UseCase{repo: mr, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), modified: &lastModified{}, persistMu: &sync.Mutex{}}
+	assert.True(t, uc.LastModified().IsZero())
+	assert.Equal(t, uint64(0), uc.Version())
+
+	before := time.Now().UTC().Truncate(time.Second)
+	assert.Nil(t, uc.SetPokemons(pokemons))
+	modified := uc.LastModified()
+	assert.False(t, modified.Before(before))
+	assert.Equal(t, time.UTC, modified.Location())
+	assert.Equal(t, 0, modified.Nanosecond())
+	// Changes within the same second still move the version
+	version := uc.Version()
+	assert.Nil(t, uc.SetPokemons(pokemons))
+	assert.Equal(t, version+1, uc.Version())
+
+	uc.modified.time = time.Time{}
+	uc.csvFileName = filepath.Join(t.TempDir(), "missing", "pokemons.csv")
+	assert.NotNil(t, uc.DeletePokemon(1))
+	assert.False(t, uc.LastModified().IsZero())
+
+	// Without tracking there is never a last modification time
+	untracked := UseCase{repo: mr, csvFileName: filepath.Join(t.TempDir(), "pokemons.csv"), persistMu: &sync.Mutex{}}
+	assert.Nil(t, untracked.SetPokemons(pokemons))
+	assert.True(t, untracked.LastModified().IsZero())
+	assert.Equal(t, uint64(0), untracked.Version())
 }
 
 func TestUseCase_FetchPokemonsFromApi(t *testing.T) {
@@ -198,12 +500,12 @@ func TestUseCase_FetchPokemonsFromApi(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ms := mockService{}
+			ms := &mockService{}
 			ms.On("FetchPokemonsFromApi").Return(tc.externalApiPokemons, tc.error)
 
 			uc := UseCase{service: ms}
 
-			response, err := uc.FetchPokemonsFromApi()
+			response, err := uc.FetchPokemonsFromApi(context.Background())
 
 			assert.EqualValues(t, tc.expectedUseCasePokemons, response)
 			if tc.hasError {
@@ -252,28 +554,345 @@ func TestUseCase_FilterPokemonsConcurrently(t *testing.T) {
 			items:          1,
 			itemsPerWorker: 1,
 		},
-		// FIXME: Implementation fails with this scenario, it is not the test case, but the code
-		// {
-		// 	name:           "fetch even pokemon",
-		// 	inputPokemons:  pokemons,
-		// 	outputPokemons: []model.Pokemon{{ID: 2, Name: "ivysaur"}},
-		// 	filter:         enum.Even,
-		// 	numWorkers:     2,
-		// 	items:          2,
-		// 	itemsPerWorker: 1,
-		// },
+		{
+			name:           "fetch even pokemon",
+			inputPokemons:  pokemons,
+			outputPokemons: []model.Pokemon{{ID: 2, Name: "ivysaur"}},
+			filter:         enum.Even,
+			numWorkers:     2,
+			items:          2,
+			itemsPerWorker: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(tc.inputPokemons, nil)
+
+			uc := UseCase{repo: mr}
+
+			response, err := uc.FilterPokemonsConcurrently(context.Background(), enum.Memory, tc.filter, tc.numWorkers, tc.items, tc.itemsPerWorker)
+
+			assert.Nil(t, err)
+			// Workers run concurrently, so the arrival order is not guaranteed
+			assert.ElementsMatch(t, tc.outputPokemons, response)
+		})
+	}
+}
+
+// TestUseCase_FilterPokemonsConcurrently_Budget - Validates pools lease their workers, and the kind of error when they cannot
+func TestUseCase_FilterPokemonsConcurrently_Budget(t *testing.T) {
+	mr := &mockRepository{}
+	mr.On("GetAllPokemons").Return(pokemons, nil)
+
+	budget := workerpool.NewBudget(2, 2, 0, time.Millisecond)
+	uc := UseCase{repo: mr, budget: budget}
+
+	response, err := uc.FilterPokemonsConcurrently(context.Background(), enum.Memory, enum.Odd, 2, 2, 1)
+	assert.Nil(t, err)
+	assert.Len(t, response, 2)
+
+	_, err = uc.FilterPokemonsConcurrently(context.Background(), enum.Memory, enum.Odd, 3, 2, 1)
+	assert.ErrorIs(t, err, model.ErrValidation)
+	assert.EqualError(t, err, "too many workers: 3 requested, must be between 1 and 2")
+
+	// Workers were given back, but a pool holding them all leaves no room for another one
+	release, err := budget.Acquire(context.Background(), 2)
+	require.Nil(t, err)
+	_, err = uc.FilterPokemonsConcurrently(context.Background(), enum.Memory, enum.Odd, 1, 2, 1)
+	assert.ErrorIs(t, err, model.ErrTooManyRequests)
+	var busy *workerpool.BusyError
+	assert.ErrorAs(t, err, &busy)
+	release()
+}
+
+// TestUseCase_SearchPokemonsConcurrently - Validates use case SearchPokemonsConcurrently method
+func TestUseCase_SearchPokemonsConcurrently(t *testing.T) {
+	testCases := []struct {
+		name           string
+		inputPokemons  []model.Pokemon
+		outputPokemons []model.Pokemon
+		expr           string
+		numWorkers     int
+		items          int
+		itemsPerWorker int
+	}{
+		{
+			name:           "search by name",
+			inputPokemons:  pokemons,
+			outputPokemons: []model.Pokemon{{ID: 2, Name: "ivysaur"}},
+			expr:           `name~"ivy"`,
+			numWorkers:     1,
+			items:          1,
+			itemsPerWorker: 1,
+		},
+		{
+			name:           "search by id and name",
+			inputPokemons:  pokemons,
+			outputPokemons: []model.Pokemon{{ID: 2, Name: "ivysaur"}, {ID: 3, Name: "venusaur"}},
+			expr:           `id>1 AND name~"saur"`,
+			numWorkers:     1,
+			items:          2,
+			itemsPerWorker: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(tc.inputPokemons, nil)
+
+			uc := UseCase{repo: mr}
+
+			predicate, err := filter.Parse(tc.expr)
+			assert.Nil(t, err)
+
+			response, err := uc.SearchPokemonsConcurrently(context.Background(), enum.Memory, predicate, tc.numWorkers, tc.items, tc.itemsPerWorker)
+
+			assert.Nil(t, err)
+			assert.EqualValues(t, tc.outputPokemons, response)
+		})
+	}
+}
+
+// TestUseCase_StreamFilterPokemons - Validates use case StreamFilterPokemons method
+func TestUseCase_StreamFilterPokemons(t *testing.T) {
+	testCases := []struct {
+		name           string
+		inputPokemons  []model.Pokemon
+		outputPokemons []model.Pokemon
+		filter         enum.OddEven
+		numWorkers     int
+		items          int
+		itemsPerWorker int
+		expectedReason workerpool.StopReason
+	}{
+		{
+			name:           "items reached",
+			inputPokemons:  pokemons,
+			outputPokemons: []model.Pokemon{{ID: 1, Name: "bulbasaur"}},
+			filter:         enum.Odd,
+			numWorkers:     1,
+			items:          1,
+			itemsPerWorker: 5,
+			expectedReason: workerpool.ItemsReached,
+		},
+		{
+			name:           "end of file",
+			inputPokemons:  pokemons,
+			outputPokemons: []model.Pokemon{{ID: 2, Name: "ivysaur"}},
+			filter:         enum.Even,
+			numWorkers:     1,
+			items:          5,
+			itemsPerWorker: 5,
+			expectedReason: workerpool.EOF,
+		},
+		{
+			name:           "workers exhausted",
+			inputPokemons:  pokemons,
+			outputPokemons: []model.Pokemon{{ID: 1, Name: "bulbasaur"}},
+			filter:         enum.Odd,
+			numWorkers:     1,
+			items:          5,
+			itemsPerWorker: 1,
+			expectedReason: workerpool.WorkersExhausted,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			mr := mockRepository{}
-			mr.On("GetAllPokemons").Return(tc.inputPokemons)
+			mr := &mockRepository{}
+			mr.On("GetAllPokemons").Return(tc.inputPokemons, nil)
 
 			uc := UseCase{repo: mr}
 
-			response := uc.FilterPokemonsConcurrently(tc.filter, tc.numWorkers, tc.items, tc.itemsPerWorker)
+			var response []model.Pokemon
+			reason, err := uc.StreamFilterPokemons(context.Background(), enum.Memory, tc.filter, tc.numWorkers, tc.items, tc.itemsPerWorker, func(p model.Pokemon) {
+				response = append(response, p)
+			})
 
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedReason, reason)
 			assert.EqualValues(t, tc.outputPokemons, response)
 		})
 	}
 }
+
+// Writes a CSV file with numRows pokemons in a temp dir, returning its path
+func writeBigCsv(t *testing.T, numRows int) string {
+	path := filepath.Join(t.TempDir(), "big.csv")
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for i := 1; i <= numRows; i++ {
+		fmt.Fprintf(w, "%d,pokemon%d\n", i, i)
+	}
+	assert.Nil(t, w.Flush())
+
+	return path
+}
+
+// TestUseCase_StreamFilterPokemons_Csv - Validates pools reading the CSV file row by row
+func TestUseCase_StreamFilterPokemons_Csv(t *testing.T) {
+	bigCsv := writeBigCsv(t, 100000)
+
+	testCases := []struct {
+		name           string
+		csvPath        string
+		filter         enum.OddEven
+		numWorkers     int
+		items          int
+		itemsPerWorker int
+		expectedItems  int
+		expectedReason workerpool.StopReason
+		hasError       bool
+		error          error
+	}{
+		{
+			name:           "end of file",
+			csvPath:        "./test_csv/pokemons_full.csv",
+			filter:         enum.Odd,
+			numWorkers:     2,
+			items:          10,
+			itemsPerWorker: 10,
+			expectedItems:  2,
+			expectedReason: workerpool.EOF,
+		},
+		{
+			name:           "early termination on a big file",
+			csvPath:        bigCsv,
+			filter:         enum.Even,
+			numWorkers:     4,
+			items:          3,
+			itemsPerWorker: 10,
+			expectedItems:  3,
+			expectedReason: workerpool.ItemsReached,
+		},
+		{
+			name:           "whole big file",
+			csvPath:        bigCsv,
+			filter:         enum.Even,
+			numWorkers:     4,
+			items:          0,
+			itemsPerWorker: 0,
+			expectedItems:  50000,
+			expectedReason: workerpool.EOF,
+		},
+		{
+			name:           "missing csv file",
+			csvPath:        "./test_csv/pokemons_missing.csv",
+			filter:         enum.Odd,
+			numWorkers:     2,
+			items:          10,
+			itemsPerWorker: 10,
+			expectedReason: workerpool.Cancelled,
+			hasError:       true,
+			error:          errors.New("open ./test_csv/pokemons_missing.csv: no such file or directory"),
+		},
+		{
+			name:           "wrong csv file",
+			csvPath:        "./test_csv/not_pokemons.csv",
+			filter:         enum.Odd,
+			numWorkers:     2,
+			items:          10,
+			itemsPerWorker: 10,
+			expectedReason: workerpool.Cancelled,
+			hasError:       true,
+			error:          errors.New("Error converting just plain text to int"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uc := UseCase{csvFileName: tc.csvPath}
+
+			items := 0
+			reason, err := uc.StreamFilterPokemons(context.Background(), enum.CSV, tc.filter, tc.numWorkers, tc.items, tc.itemsPerWorker, func(p model.Pokemon) {
+				items++
+			})
+
+			assert.Equal(t, tc.expectedReason, reason)
+			if tc.hasError {
+				assert.EqualError(t, err, tc.error.Error())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedItems, items)
+			}
+		})
+	}
+}
+
+// Repository whose pokemons cannot be read in full, like a backend failing a query
+type unreadableRepository struct {
+	*repository.DB
+}
+
+func (ur unreadableRepository) GetAllPokemons() ([]model.Pokemon, error) {
+	return nil, errors.New("disk I/O error")
+}
+
+// TestUseCase_ReadError - Validates a repository that cannot be read aborts writes, instead of taking it for an empty one
+func TestUseCase_ReadError(t *testing.T) {
+	csv := []byte("1,bulbasaur\n2,ivysaur\n")
+
+	t.Run("new", func(t *testing.T) {
+		csvPath := filepath.Join(t.TempDir(), "pokemons.csv")
+		require.Nil(t, os.WriteFile(csvPath, csv, 0644))
+		db := repository.New()
+
+		uc, err := New(unreadableRepository{db}, csvPath, false, &mockService{}, nil)
+
+		assert.Nil(t, uc)
+		assert.ErrorIs(t, err, model.ErrPersistence)
+		// Not reseeded out of the csv file
+		assert.Empty(t, allPokemons(t, db))
+	})
+
+	testCases := []struct {
+		name  string
+		write func(uc *UseCase) error
+	}{
+		{
+			name: "create with the next id",
+			write: func(uc *UseCase) error {
+				_, err := uc.CreatePokemon(model.Pokemon{Name: "charmander"})
+				return err
+			},
+		},
+		{
+			name:  "update",
+			write: func(uc *UseCase) error { return uc.UpdatePokemon(model.Pokemon{ID: 1, Name: "bulbasaur-2"}) },
+		},
+		{
+			name:  "set",
+			write: func(uc *UseCase) error { return uc.SetPokemons([]model.Pokemon{{ID: 4, Name: "charmander"}}) },
+		},
+		{
+			name: "sync",
+			write: func(uc *UseCase) error {
+				_, err := uc.SyncPokemons([]model.Pokemon{{ID: 4, Name: "charmander"}}, enum.Replace, false)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			csvPath := filepath.Join(t.TempDir(), "pokemons.csv")
+			require.Nil(t, os.WriteFile(csvPath, csv, 0644))
+			db := repository.New()
+			require.Nil(t, db.SetPokemons(pokemons))
+			uc := &UseCase{repo: unreadableRepository{db}, csvFileName: csvPath, persistMu: &sync.Mutex{}}
+
+			assert.ErrorIs(t, tc.write(uc), model.ErrPersistence)
+			// Neither the repository nor the csv file are changed
+			assert.Equal(t, pokemons, allPokemons(t, db))
+			written, err := os.ReadFile(csvPath)
+			require.Nil(t, err)
+			assert.Equal(t, csv, written)
+		})
+	}
+}
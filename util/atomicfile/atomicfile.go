@@ -0,0 +1,101 @@
+package atomicfile
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Suffix of the copy kept of the previous file contents
+const BackupSuffix = ".bak"
+
+// Write - Replaces the file at path with whatever write outputs, without ever leaving it half written
+// Contents go to a temp file in the same directory, which is fsynced and then renamed over path.
+// A crash at any point leaves either the old or the new contents in place.
+// With backup set, the previous contents are kept in path + BackupSuffix first
+func Write(path string, backup bool, write func(w io.Writer) error) error {
+	if backup {
+		if err := rotate(path); err != nil {
+			return err
+		}
+	}
+	return replace(path, write)
+}
+
+// Copies the current contents of path into its backup file. A missing path has nothing to back up
+func rotate(path string) error {
+	src, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return replace(path+BackupSuffix, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+// Writes into a temp file next to path and renames it over path once it is safely on disk
+func replace(path string, write func(w io.Writer) error) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Never leave the temp file behind when something goes wrong
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	buffered := bufio.NewWriter(tmp)
+	if err = write(buffered); err != nil {
+		return err
+	}
+	if err = buffered.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	// Keep the permissions of the file being replaced, CreateTemp always uses 0600
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// Makes the rename durable. Some platforms cannot open or sync directories, which is not an error there
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return nil
+}
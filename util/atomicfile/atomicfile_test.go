@@ -0,0 +1,100 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWrite - Validates files are replaced as a whole, or not at all
+func TestWrite(t *testing.T) {
+	testCases := []struct {
+		name            string
+		existing        string // Empty means there is no file yet
+		backup          bool
+		content         string
+		writeError      error
+		expectedContent string
+		expectedBackup  string // Empty means no backup is expected
+	}{
+		{
+			name:            "write a new file",
+			content:         "new",
+			expectedContent: "new",
+		},
+		{
+			name:            "replace a file",
+			existing:        "old",
+			content:         "new",
+			expectedContent: "new",
+		},
+		{
+			name:            "replace a file keeping a backup",
+			existing:        "old",
+			backup:          true,
+			content:         "new",
+			expectedContent: "new",
+			expectedBackup:  "old",
+		},
+		{
+			name:            "backup of a missing file",
+			backup:          true,
+			content:         "new",
+			expectedContent: "new",
+		},
+		{
+			name:            "failed write keeps the old file",
+			existing:        "old",
+			content:         "half written",
+			writeError:      errors.New("disk full"),
+			expectedContent: "old",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "pokemons.csv")
+			if tc.existing != "" {
+				assert.Nil(t, os.WriteFile(path, []byte(tc.existing), 0640))
+			}
+
+			err := Write(path, tc.backup, func(w io.Writer) error {
+				if _, err := io.WriteString(w, tc.content); err != nil {
+					return err
+				}
+				return tc.writeError
+			})
+			if tc.writeError != nil {
+				assert.ErrorIs(t, err, tc.writeError)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			content, _ := os.ReadFile(path)
+			assert.Equal(t, tc.expectedContent, string(content))
+
+			backup, err := os.ReadFile(path + BackupSuffix)
+			if tc.expectedBackup == "" {
+				assert.True(t, os.IsNotExist(err))
+			} else {
+				assert.Equal(t, tc.expectedBackup, string(backup))
+			}
+
+			// No temp file is ever left behind
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				assert.NotRegexp(t, `\.tmp$`, entry.Name())
+			}
+
+			if tc.existing != "" {
+				info, _ := os.Stat(path)
+				assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package enum
+
+import (
+	"errors"
+	"strings"
+)
+
+// Backend - Works as enum to identify where the repository keeps pokemons
+type Backend int
+
+const (
+	UndefinedBackend Backend = iota
+	MemoryBackend            // Plain in-memory map, lost on restart
+	SQLiteBackend            // Embedded SQLite database file
+	BoltBackend              // Embedded bbolt key/value file
+)
+
+// ParseBackend - Takes a string and returns a MemoryBackend, SQLiteBackend or BoltBackend enum
+func ParseBackend(input string) (Backend, error) {
+	switch strings.ToLower(input) {
+	case "memory":
+		return MemoryBackend, nil
+	case "sqlite":
+		return SQLiteBackend, nil
+	case "bolt":
+		return BoltBackend, nil
+	}
+
+	return UndefinedBackend, errors.New(input + " is not a valid input. Must be either 'memory', 'sqlite' or 'bolt'")
+}
@@ -0,0 +1,55 @@
+package enum
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Backend(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		expectedResult Backend
+		hasError       bool
+		error          error
+	}{
+		{
+			name:           "test memory translation",
+			input:          "memory",
+			expectedResult: MemoryBackend,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test sqlite translation",
+			input:          "SQLite",
+			expectedResult: SQLiteBackend,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test bolt translation",
+			input:          "bolt",
+			expectedResult: BoltBackend,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test wrong case translation",
+			input:          "postgres",
+			expectedResult: UndefinedBackend,
+			hasError:       true,
+			error:          errors.New("postgres is not a valid input. Must be either 'memory', 'sqlite' or 'bolt'"),
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := ParseBackend(tc.input)
+		assert.Equal(t, tc.expectedResult, result)
+		if tc.hasError {
+			assert.EqualError(t, err, tc.error.Error())
+		}
+	}
+}
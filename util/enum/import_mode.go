@@ -0,0 +1,27 @@
+package enum
+
+import (
+	"errors"
+	"strings"
+)
+
+// ImportMode - Works as enum to identify what an import does with invalid rows
+type ImportMode int
+
+const (
+	UndefinedImportMode ImportMode = iota
+	Reject                         // Any invalid row rejects the whole file
+	Skip                           // Invalid rows are left out, the valid ones are imported
+)
+
+// ParseImportMode - Takes a string and returns a Reject or Skip enum
+func ParseImportMode(input string) (ImportMode, error) {
+	switch strings.ToLower(input) {
+	case "reject":
+		return Reject, nil
+	case "skip":
+		return Skip, nil
+	}
+
+	return UndefinedImportMode, errors.New(input + " is not a valid input. Must be either 'reject' or 'skip'")
+}
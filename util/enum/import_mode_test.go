@@ -0,0 +1,48 @@
+package enum
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ImportMode(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		expectedResult ImportMode
+		hasError       bool
+		error          error
+	}{
+		{
+			name:           "test reject translation",
+			input:          "reject",
+			expectedResult: Reject,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test skip translation",
+			input:          "SKIP",
+			expectedResult: Skip,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test wrong case translation",
+			input:          "ignore",
+			expectedResult: UndefinedImportMode,
+			hasError:       true,
+			error:          errors.New("ignore is not a valid input. Must be either 'reject' or 'skip'"),
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := ParseImportMode(tc.input)
+		assert.Equal(t, tc.expectedResult, result)
+		if tc.hasError {
+			assert.EqualError(t, err, tc.error.Error())
+		}
+	}
+}
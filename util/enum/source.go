@@ -0,0 +1,27 @@
+package enum
+
+import (
+	"errors"
+	"strings"
+)
+
+// Source - Works as enum to identify where the worker pool reads pokemons from
+type Source int
+
+const (
+	UndefinedSource Source = iota
+	Memory                 // The in-memory repository
+	CSV                    // The CSV file, row by row
+)
+
+// ParseSource - Takes a string and returns a Memory or CSV enum
+func ParseSource(input string) (Source, error) {
+	switch strings.ToLower(input) {
+	case "memory":
+		return Memory, nil
+	case "csv":
+		return CSV, nil
+	}
+
+	return UndefinedSource, errors.New(input + " is not a valid input. Must be either 'memory' or 'csv'")
+}
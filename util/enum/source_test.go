@@ -0,0 +1,48 @@
+package enum
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Source(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		expectedResult Source
+		hasError       bool
+		error          error
+	}{
+		{
+			name:           "test memory translation",
+			input:          "memory",
+			expectedResult: Memory,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test csv translation",
+			input:          "CSV",
+			expectedResult: CSV,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test wrong case translation",
+			input:          "disk",
+			expectedResult: UndefinedSource,
+			hasError:       true,
+			error:          errors.New("disk is not a valid input. Must be either 'memory' or 'csv'"),
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := ParseSource(tc.input)
+		assert.Equal(t, tc.expectedResult, result)
+		if tc.hasError {
+			assert.EqualError(t, err, tc.error.Error())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package enum
+
+import (
+	"errors"
+	"strings"
+)
+
+// SyncMode - Works as enum to identify how pokemons fetched from the API are combined with the local ones
+type SyncMode int
+
+const (
+	UndefinedSyncMode SyncMode = iota
+	Replace                    // Local pokemons become the fetched ones, local-only rows are removed
+	Merge                      // Fetched pokemons are added or updated field by field, keeping local values the API leaves empty
+	UpsertOnly                 // Fetched pokemons are added or replace the local ones as a whole, nothing is removed
+)
+
+// ParseSyncMode - Takes a string and returns a Replace, Merge or UpsertOnly enum
+func ParseSyncMode(input string) (SyncMode, error) {
+	switch strings.ToLower(input) {
+	case "replace":
+		return Replace, nil
+	case "merge":
+		return Merge, nil
+	case "upsert-only":
+		return UpsertOnly, nil
+	}
+
+	return UndefinedSyncMode, errors.New(input + " is not a valid input. Must be either 'replace', 'merge' or 'upsert-only'")
+}
+
+// String - Returns the param value the mode is parsed from
+func (m SyncMode) String() string {
+	switch m {
+	case Replace:
+		return "replace"
+	case Merge:
+		return "merge"
+	case UpsertOnly:
+		return "upsert-only"
+	}
+	return "undefined"
+}
@@ -0,0 +1,60 @@
+package enum
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SyncMode(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		expectedResult SyncMode
+		hasError       bool
+		error          error
+	}{
+		{
+			name:           "test replace translation",
+			input:          "replace",
+			expectedResult: Replace,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test merge translation",
+			input:          "Merge",
+			expectedResult: Merge,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test upsert only translation",
+			input:          "UPSERT-ONLY",
+			expectedResult: UpsertOnly,
+			hasError:       false,
+			error:          nil,
+		},
+		{
+			name:           "test wrong case translation",
+			input:          "upsert",
+			expectedResult: UndefinedSyncMode,
+			hasError:       true,
+			error:          errors.New("upsert is not a valid input. Must be either 'replace', 'merge' or 'upsert-only'"),
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := ParseSyncMode(tc.input)
+		assert.Equal(t, tc.expectedResult, result)
+		if tc.hasError {
+			assert.EqualError(t, err, tc.error.Error())
+		} else {
+			// String gives back what the mode is parsed from
+			roundTrip, err := ParseSyncMode(result.String())
+			assert.Nil(t, err)
+			assert.Equal(t, result, roundTrip)
+		}
+	}
+}
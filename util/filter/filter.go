@@ -0,0 +1,273 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rincon-orlando/go-bootcamp/model"
+)
+
+// Grammar of the filter expressions, i.e. id>100 AND name~"saur"
+//
+// expr       := and ( OR and )*
+// and        := unary ( AND unary )*
+// unary      := NOT unary | '(' expr ')' | comparison
+// comparison := field op value
+// op         := = | != | > | >= | < | <= | ~
+//
+// Numeric fields: id, height, weight, base_experience and stat.<name> (i.e. stat.hp)
+// Text fields: name, type and ability. type and ability match if any of the pokemon values does
+// '~' means "contains", case insensitive, and only applies to text fields
+
+// Predicate - Tells whether a pokemon matches a filter expression
+type Predicate func(model.Pokemon) bool
+
+// ParseError - Error found while parsing an expression, Pos being the offset of the failing token
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+// Error - Helps formatting a parse error as string
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Parse - Turns a filter expression into a Predicate
+func Parse(expr string) (Predicate, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything must be consumed, otherwise there is garbage at the end
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &ParseError{tok.pos, fmt.Sprintf("unexpected %q", tok.text)}
+	}
+
+	return predicate, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// Tells whether the next token is the given keyword, consuming it if so
+func (p *parser) keyword(kw string) bool {
+	tok := p.peek()
+	if tok.kind == tokIdent && strings.EqualFold(tok.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (Predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(pokemon model.Pokemon) bool { return l(pokemon) || right(pokemon) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Predicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("AND") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(pokemon model.Pokemon) bool { return l(pokemon) && right(pokemon) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Predicate, error) {
+	if p.keyword("NOT") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(pokemon model.Pokemon) bool { return !inner(pokemon) }, nil
+	}
+
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokRParen {
+			return nil, &ParseError{tok.pos, fmt.Sprintf("expected ')', got %q", tok.text)}
+		}
+		return inner, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Predicate, error) {
+	field := p.next()
+	if field.kind != tokIdent {
+		return nil, &ParseError{field.pos, fmt.Sprintf("expected a field, got %q", field.text)}
+	}
+
+	op := p.next()
+	if op.kind != tokOp {
+		return nil, &ParseError{op.pos, fmt.Sprintf("expected an operator, got %q", op.text)}
+	}
+
+	value := p.next()
+	if value.kind != tokNumber && value.kind != tokString && value.kind != tokIdent {
+		return nil, &ParseError{value.pos, fmt.Sprintf("expected a value, got %q", value.text)}
+	}
+
+	name := strings.ToLower(field.text)
+	if getter, ok := numericField(name); ok {
+		return numericComparison(getter, op, value)
+	}
+	if getter, ok := textFields[name]; ok {
+		return textComparison(getter, op, value)
+	}
+
+	return nil, &ParseError{field.pos, fmt.Sprintf("unknown field %q", field.text)}
+}
+
+// Numeric fields getters. The bool is false when the pokemon does not have such value
+type numericGetter func(model.Pokemon) (int, bool)
+
+func numericField(name string) (numericGetter, bool) {
+	switch name {
+	case "id":
+		return func(p model.Pokemon) (int, bool) { return p.ID, true }, true
+	case "height":
+		return func(p model.Pokemon) (int, bool) { return p.Height, true }, true
+	case "weight":
+		return func(p model.Pokemon) (int, bool) { return p.Weight, true }, true
+	case "base_experience":
+		return func(p model.Pokemon) (int, bool) { return p.BaseExperience, true }, true
+	}
+
+	if strings.HasPrefix(name, "stat.") && len(name) > len("stat.") {
+		stat := strings.TrimPrefix(name, "stat.")
+		return func(p model.Pokemon) (int, bool) {
+			for _, s := range p.Stats {
+				if strings.EqualFold(s.Name, stat) {
+					return s.BaseStat, true
+				}
+			}
+			return 0, false
+		}, true
+	}
+
+	return nil, false
+}
+
+// Text fields getters. Single valued fields return a one element slice
+var textFields = map[string]func(model.Pokemon) []string{
+	"name":    func(p model.Pokemon) []string { return []string{p.Name} },
+	"type":    func(p model.Pokemon) []string { return p.Types },
+	"ability": func(p model.Pokemon) []string { return p.Abilities },
+}
+
+func numericComparison(getter numericGetter, op token, value token) (Predicate, error) {
+	if value.kind != tokNumber {
+		return nil, &ParseError{value.pos, fmt.Sprintf("expected a number, got %q", value.text)}
+	}
+	n, err := strconv.Atoi(value.text)
+	if err != nil {
+		return nil, &ParseError{value.pos, fmt.Sprintf("invalid number %q", value.text)}
+	}
+
+	var cmp func(int) bool
+	switch op.text {
+	case "=":
+		cmp = func(v int) bool { return v == n }
+	case "!=":
+		cmp = func(v int) bool { return v != n }
+	case ">":
+		cmp = func(v int) bool { return v > n }
+	case ">=":
+		cmp = func(v int) bool { return v >= n }
+	case "<":
+		cmp = func(v int) bool { return v < n }
+	case "<=":
+		cmp = func(v int) bool { return v <= n }
+	default:
+		return nil, &ParseError{op.pos, fmt.Sprintf("operator %q does not apply to numbers", op.text)}
+	}
+
+	return func(p model.Pokemon) bool {
+		v, ok := getter(p)
+		return ok && cmp(v)
+	}, nil
+}
+
+func textComparison(getter func(model.Pokemon) []string, op token, value token) (Predicate, error) {
+	if value.kind == tokNumber {
+		return nil, &ParseError{value.pos, fmt.Sprintf("expected a text, got %q", value.text)}
+	}
+	text := value.text
+
+	var cmp func(string) bool
+	switch op.text {
+	case "=", "!=":
+		cmp = func(v string) bool { return strings.EqualFold(v, text) }
+	case "~":
+		lower := strings.ToLower(text)
+		cmp = func(v string) bool { return strings.Contains(strings.ToLower(v), lower) }
+	default:
+		return nil, &ParseError{op.pos, fmt.Sprintf("operator %q does not apply to texts", op.text)}
+	}
+
+	matchAny := func(p model.Pokemon) bool {
+		for _, v := range getter(p) {
+			if cmp(v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Negation means none of the values is equal
+	if op.text == "!=" {
+		return func(p model.Pokemon) bool { return !matchAny(p) }, nil
+	}
+	return matchAny, nil
+}
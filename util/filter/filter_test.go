@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"testing"
+
+	"rincon-orlando/go-bootcamp/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var bulbasaur = model.Pokemon{
+	ID:             1,
+	Name:           "bulbasaur",
+	Types:          []string{"grass", "poison"},
+	Stats:          []model.Stat{{Name: "hp", BaseStat: 45}, {Name: "special-attack", BaseStat: 65}},
+	Height:         7,
+	Weight:         69,
+	BaseExperience: 64,
+	Abilities:      []string{"overgrow", "chlorophyll"},
+}
+
+var charmander = model.Pokemon{
+	ID:             4,
+	Name:           "charmander",
+	Types:          []string{"fire"},
+	Stats:          []model.Stat{{Name: "hp", BaseStat: 39}},
+	Height:         6,
+	Weight:         85,
+	BaseExperience: 62,
+	Abilities:      []string{"blaze"},
+}
+
+// TestParse - Test valid expressions match the expected pokemons
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name              string
+		expr              string
+		matchesBulbasaur  bool
+		matchesCharmander bool
+	}{
+		{name: "id equal", expr: "id=1", matchesBulbasaur: true, matchesCharmander: false},
+		{name: "id not equal", expr: "id!=1", matchesBulbasaur: false, matchesCharmander: true},
+		{name: "id greater", expr: "id>1", matchesBulbasaur: false, matchesCharmander: true},
+		{name: "id greater or equal", expr: "id >= 1", matchesBulbasaur: true, matchesCharmander: true},
+		{name: "weight lower", expr: "weight<80", matchesBulbasaur: true, matchesCharmander: false},
+		{name: "height lower or equal", expr: "height<=6", matchesBulbasaur: false, matchesCharmander: true},
+		{name: "base experience", expr: "base_experience=62", matchesBulbasaur: false, matchesCharmander: true},
+		{name: "name contains", expr: `name~"SAUR"`, matchesBulbasaur: true, matchesCharmander: false},
+		{name: "name bare value", expr: "name=charmander", matchesBulbasaur: false, matchesCharmander: true},
+		{name: "any type", expr: `type="poison"`, matchesBulbasaur: true, matchesCharmander: false},
+		{name: "no type", expr: `type!="poison"`, matchesBulbasaur: false, matchesCharmander: true},
+		{name: "ability contains", expr: `ability~"phyll"`, matchesBulbasaur: true, matchesCharmander: false},
+		{name: "stat", expr: "stat.hp>40", matchesBulbasaur: true, matchesCharmander: false},
+		{name: "missing stat", expr: "stat.special-attack>0", matchesBulbasaur: true, matchesCharmander: false},
+		{name: "and", expr: `id>0 AND name~"char"`, matchesBulbasaur: false, matchesCharmander: true},
+		{name: "or", expr: "id=1 or id=4", matchesBulbasaur: true, matchesCharmander: true},
+		{name: "not", expr: "NOT id=1", matchesBulbasaur: false, matchesCharmander: true},
+		{name: "and binds tighter than or", expr: "id=1 OR id=4 AND weight<80", matchesBulbasaur: true, matchesCharmander: false},
+		{name: "parenthesis", expr: "(id=1 OR id=4) AND weight>80", matchesBulbasaur: false, matchesCharmander: true},
+		{name: "escaped quote", expr: `name="char\"mander"`, matchesBulbasaur: false, matchesCharmander: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			predicate, err := Parse(tc.expr)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.matchesBulbasaur, predicate(bulbasaur))
+			assert.Equal(t, tc.matchesCharmander, predicate(charmander))
+		})
+	}
+}
+
+// TestParse_Errors - Test wrong expressions report the failing position
+func TestParse_Errors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		expr  string
+		error string
+		pos   int
+	}{
+		{name: "empty", expr: "", error: `expected a field, got "end of expression" at position 0`, pos: 0},
+		{name: "unknown field", expr: "id>1 AND color=red", error: `unknown field "color" at position 9`, pos: 9},
+		{name: "missing operator", expr: "id 1", error: `expected an operator, got "1" at position 3`, pos: 3},
+		{name: "missing value", expr: "id>", error: `expected a value, got "end of expression" at position 3`, pos: 3},
+		{name: "text for number", expr: `id>"one"`, error: `expected a number, got "one" at position 3`, pos: 3},
+		{name: "number for text", expr: "name=1", error: `expected a text, got "1" at position 5`, pos: 5},
+		{name: "contains on number", expr: "id~1", error: `operator "~" does not apply to numbers at position 2`, pos: 2},
+		{name: "greater on text", expr: "name>a", error: `operator ">" does not apply to texts at position 4`, pos: 4},
+		{name: "unterminated string", expr: `name="bulba`, error: "unterminated string at position 5", pos: 5},
+		{name: "unclosed parenthesis", expr: "(id=1", error: `expected ')', got "end of expression" at position 5`, pos: 5},
+		{name: "trailing garbage", expr: "id=1 id=2", error: `unexpected "id" at position 5`, pos: 5},
+		{name: "wrong character", expr: "id=1 & id=2", error: `unexpected character '&' at position 5`, pos: 5},
+		{name: "lonely bang", expr: "id!1", error: "expected '!=' at position 2", pos: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			predicate, err := Parse(tc.expr)
+			assert.Nil(t, predicate)
+			assert.EqualError(t, err, tc.error)
+			assert.Equal(t, tc.pos, err.(*ParseError).Pos)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string // Unquoted text for strings
+	pos  int    // Offset of the token in the expression
+}
+
+// Splits an expression into tokens, always ending with an EOF token
+func tokenize(expr string) ([]token, error) {
+	tokens := make([]token, 0)
+
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case strings.ContainsRune("=!<>~", c):
+			op := string(c)
+			if i+1 < len(expr) && expr[i+1] == '=' && c != '=' && c != '~' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &ParseError{i, "expected '!='"}
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		case c == '"':
+			text, end, err := readString(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokString, text, i})
+			i = end
+		case c == '-' || unicode.IsDigit(c):
+			end := i + 1
+			for end < len(expr) && unicode.IsDigit(rune(expr[end])) {
+				end++
+			}
+			tokens = append(tokens, token{tokNumber, expr[i:end], i})
+			i = end
+		case isIdentChar(c):
+			end := i + 1
+			for end < len(expr) && (isIdentChar(rune(expr[end])) || expr[end] == '-' || unicode.IsDigit(rune(expr[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{tokIdent, expr[i:end], i})
+			i = end
+		default:
+			return nil, &ParseError{i, fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+
+	return append(tokens, token{tokEOF, "end of expression", len(expr)}), nil
+}
+
+func isIdentChar(c rune) bool {
+	return unicode.IsLetter(c) || c == '_' || c == '.'
+}
+
+// Reads a double quoted string starting at start. Returns its unquoted text and the offset right after it
+func readString(expr string, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			if i+1 < len(expr) {
+				i++
+				sb.WriteByte(expr[i])
+			}
+		case '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(expr[i])
+		}
+	}
+
+	return "", 0, &ParseError{start, "unterminated string"}
+}
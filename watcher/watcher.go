@@ -0,0 +1,80 @@
+package watcher
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher - Tells when a file changes, however it gets written
+// The directory is watched rather than the file itself, so files replaced by a rename (atomicfile, most editors,
+// config maps) keep being watched, and a file removed and created again is picked up too
+type Watcher struct {
+	path    string
+	quiet   time.Duration
+	watcher *fsnotify.Watcher
+}
+
+// New - Watcher factory. Changes are reported once the file went quiet for the given time,
+// so a burst of writes from a single save is reported just once. The file does not need to exist yet
+func New(path string, quiet time.Duration) (*Watcher, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	return &Watcher{path: path, quiet: quiet, watcher: watcher}, nil
+}
+
+// Run - Calls onChange after every change to the file until ctx is done, then stops watching
+// Removing the file is not a change, there is nothing to read until it is back
+func (w *Watcher) Run(ctx context.Context, onChange func()) {
+	defer w.watcher.Close()
+
+	// Stopped and drained until there is a pending change
+	timer := time.NewTimer(w.quiet)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != w.path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(w.quiet)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("Error watching " + w.path + ": " + err.Error())
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
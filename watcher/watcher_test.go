@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWatcher_Run - Validates every way of changing a file is reported, once per burst of writes
+func TestWatcher_Run(t *testing.T) {
+	write := func(path string, contents string) {
+		require.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+
+	testCases := []struct {
+		name     string
+		existing bool
+		change   func(path string)
+	}{
+		{
+			name:     "written in place",
+			existing: true,
+			change:   func(path string) { write(path, "new") },
+		},
+		{
+			name:     "replaced by a rename",
+			existing: true,
+			change: func(path string) {
+				write(path+".tmp", "new")
+				require.Nil(t, os.Rename(path+".tmp", path))
+			},
+		},
+		{
+			name:     "burst of writes",
+			existing: true,
+			change: func(path string) {
+				for i := 0; i < 5; i++ {
+					write(path, "new")
+				}
+			},
+		},
+		{
+			name:   "created",
+			change: func(path string) { write(path, "new") },
+		},
+		{
+			name:     "removed and created again",
+			existing: true,
+			change: func(path string) {
+				require.Nil(t, os.Remove(path))
+				write(path, "new")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.env")
+			if tc.existing {
+				write(path, "old")
+			}
+
+			w, err := New(path, 50*time.Millisecond)
+			require.Nil(t, err)
+
+			var changes int32
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				w.Run(ctx, func() { atomic.AddInt32(&changes, 1) })
+				close(done)
+			}()
+
+			// Other files in the same directory are not watched
+			write(filepath.Join(dir, "other.env"), "other")
+			tc.change(path)
+
+			assert.Eventually(t, func() bool { return atomic.LoadInt32(&changes) == 1 }, time.Second, 5*time.Millisecond)
+			time.Sleep(100 * time.Millisecond)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&changes))
+
+			cancel()
+			<-done
+		})
+	}
+}
+
+// TestWatcher_Removed - Validates removing the file is not reported
+func TestWatcher_Removed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pokemons.csv")
+	require.Nil(t, ioutil.WriteFile(path, []byte("1,bulbasaur\n"), 0644))
+
+	w, err := New(path, 10*time.Millisecond)
+	require.Nil(t, err)
+
+	var changes int32
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Nil(t, os.Remove(path))
+	w.Run(ctx, func() { atomic.AddInt32(&changes, 1) })
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&changes))
+}
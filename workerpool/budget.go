@@ -0,0 +1,116 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rincon-orlando/go-bootcamp/metrics"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// Reasons a Budget turns a pool away
+var (
+	ErrTooManyWorkers = errors.New("too many workers")
+	ErrQueueFull      = errors.New("too many requests waiting for workers")
+	ErrQueueTimeout   = errors.New("timed out waiting for workers")
+)
+
+// BusyError - A pool could not get its workers right now, RetryAfter tells when it is worth trying again
+type BusyError struct {
+	Err  error // ErrQueueFull or ErrQueueTimeout
+	Wait time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BusyError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter - Suggested wait before trying again
+func (e *BusyError) RetryAfter() time.Duration {
+	return e.Wait
+}
+
+// Budget - Process-wide cap on the workers all pools run at the same time
+// Pools lease their workers before starting. Those that do not fit wait in a bounded FIFO queue for a while,
+// so no amount of concurrent requests can start more worker go routines than the budget allows
+type Budget struct {
+	sem        *semaphore.Weighted
+	maxPerPool int
+	maxQueue   int
+	maxWait    time.Duration
+	queued     int64 // Pools waiting for workers
+}
+
+// NewBudget - Budget factory. capacity is the global amount of workers, maxPerPool the most a single pool may lease.
+// Up to maxQueue pools wait for workers, for at most maxWait each
+func NewBudget(capacity int, maxPerPool int, maxQueue int, maxWait time.Duration) *Budget {
+	if maxPerPool <= 0 || maxPerPool > capacity {
+		maxPerPool = capacity
+	}
+	return &Budget{
+		sem:        semaphore.NewWeighted(int64(capacity)),
+		maxPerPool: maxPerPool,
+		maxQueue:   maxQueue,
+		maxWait:    maxWait,
+	}
+}
+
+// Acquire - Leases n workers, waiting in the queue if they are not available right away
+// Fails with ErrTooManyWorkers if n is over the pool limit, with a BusyError if the queue is full or the wait
+// is too long, or with the ctx error if it is done first. release must be called once the pool is closed
+func (b *Budget) Acquire(ctx context.Context, n int) (release func(), err error) {
+	if n <= 0 || n > b.maxPerPool {
+		return nil, fmt.Errorf("%w: %d requested, must be between 1 and %d", ErrTooManyWorkers, n, b.maxPerPool)
+	}
+
+	// Waiting pools go first, TryAcquire fails while there are any
+	if !b.sem.TryAcquire(int64(n)) {
+		if err := b.wait(ctx, n); err != nil {
+			return nil, err
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.sem.Release(int64(n)) })
+	}, nil
+}
+
+func (b *Budget) wait(ctx context.Context, n int) error {
+	if atomic.AddInt64(&b.queued, 1) > int64(b.maxQueue) {
+		atomic.AddInt64(&b.queued, -1)
+		return &BusyError{ErrQueueFull, b.retryAfter()}
+	}
+	metrics.BudgetQueueDepth.Inc()
+	defer func() {
+		atomic.AddInt64(&b.queued, -1)
+		metrics.BudgetQueueDepth.Dec()
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, b.maxWait)
+	defer cancel()
+	if err := b.sem.Acquire(waitCtx, int64(n)); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &BusyError{ErrQueueTimeout, b.retryAfter()}
+	}
+	return nil
+}
+
+// Pools get turned away once the queue is full or they waited for maxWait, so by then some workers should be free
+func (b *Budget) retryAfter() time.Duration {
+	if b.maxWait < time.Second {
+		return time.Second
+	}
+	return b.maxWait
+}
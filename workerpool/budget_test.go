@@ -0,0 +1,95 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rincon-orlando/go-bootcamp/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBudget_Acquire - Validates workers are leased within the budget, and pools that do not fit wait or are turned away
+func TestBudget_Acquire(t *testing.T) {
+	b := NewBudget(4, 3, 1, 50*time.Millisecond)
+
+	_, err := b.Acquire(context.Background(), 4)
+	assert.True(t, errors.Is(err, ErrTooManyWorkers))
+	assert.EqualError(t, err, "too many workers: 4 requested, must be between 1 and 3")
+	_, err = b.Acquire(context.Background(), 0)
+	assert.True(t, errors.Is(err, ErrTooManyWorkers))
+
+	release3, err := b.Acquire(context.Background(), 3)
+	assert.Nil(t, err)
+	release1, err := b.Acquire(context.Background(), 1)
+	assert.Nil(t, err)
+
+	// The budget is spent: the first pool waits in the queue until it times out
+	start := time.Now()
+	_, err = b.Acquire(context.Background(), 1)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	var busy *BusyError
+	assert.True(t, errors.As(err, &busy))
+	assert.True(t, errors.Is(err, ErrQueueTimeout))
+	assert.Equal(t, time.Second, busy.RetryAfter())
+
+	// While one pool waits, the next one does not fit in the queue
+	waited := make(chan error)
+	go func() {
+		release, err := b.Acquire(context.Background(), 2)
+		if err == nil {
+			release()
+		}
+		waited <- err
+	}()
+	assert.Eventually(t, func() bool {
+		_, err := b.Acquire(context.Background(), 1)
+		return errors.Is(err, ErrQueueFull)
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.BudgetQueueDepth))
+
+	// Releasing twice gives the workers back only once
+	release3()
+	release3()
+	assert.Nil(t, <-waited)
+	release1()
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.BudgetQueueDepth))
+
+	// The whole budget is there again
+	release, err := b.Acquire(context.Background(), 3)
+	assert.Nil(t, err)
+	release()
+	release, err = b.Acquire(context.Background(), 1)
+	assert.Nil(t, err)
+	release()
+}
+
+// TestBudget_AcquireCancelled - Validates a pool giving up while waiting gets its context error
+func TestBudget_AcquireCancelled(t *testing.T) {
+	b := NewBudget(1, 0, 10, time.Minute)
+	release, err := b.Acquire(context.Background(), 1)
+	assert.Nil(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = b.Acquire(ctx, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+// TestBudget_RetryAfter - Validates the suggested wait follows the queue timeout, with a 1s minimum
+func TestBudget_RetryAfter(t *testing.T) {
+	b := NewBudget(1, 1, 0, 3*time.Second)
+	release, err := b.Acquire(context.Background(), 1)
+	assert.Nil(t, err)
+	defer release()
+
+	_, err = b.Acquire(context.Background(), 1)
+	var busy *BusyError
+	assert.True(t, errors.As(err, &busy))
+	assert.True(t, errors.Is(err, ErrQueueFull))
+	assert.Equal(t, 3*time.Second, busy.RetryAfter())
+}
@@ -0,0 +1,89 @@
+package workerpool
+
+import (
+	"context"
+
+	"rincon-orlando/go-bootcamp/config"
+	"rincon-orlando/go-bootcamp/util/enum"
+)
+
+// FetchJob - A unit of work that fetches a single value, i.e. an HTTP GET
+type FetchJob func() (interface{}, error)
+
+// FetchResult - Outcome of a single FetchJob, Index being its position in the input slice
+type FetchResult struct {
+	Index int
+	Value interface{}
+	Err   error
+}
+
+// fetchTask adapts a FetchJob to the workFunc contract so it can run in a GoRoutinePool
+type fetchTask struct {
+	index    int
+	job      FetchJob
+	inFlight chan struct{} // Semaphore capping the jobs running at the same time
+}
+
+// Run - Executes the job and sends its result (or error) to the output channel
+func (ft fetchTask) Run(ctx context.Context, ch chan<- interface{}) bool {
+	select {
+	case ft.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	value, err := ft.job()
+	<-ft.inFlight
+
+	select {
+	case ch <- FetchResult{ft.index, value, err}:
+		// Every job produces a result, even failing ones
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// FetchAll - Runs all jobs in a GoRoutinePool of numWorkers workers, with at most maxInFlight jobs running at once
+// Results are returned in the same order as the jobs. maxInFlight <= 0 means as many as workers
+// If ctx is done before every job finishes, the context error is returned
+func FetchAll(ctx context.Context, numWorkers int, maxInFlight int, jobs []FetchJob) ([]FetchResult, error) {
+	results := make([]FetchResult, len(jobs))
+	if len(jobs) == 0 {
+		return results, nil
+	}
+
+	if numWorkers <= 0 {
+		numWorkers = 1
+	}
+	if maxInFlight <= 0 || maxInFlight > numWorkers {
+		maxInFlight = numWorkers
+	}
+
+	// No odd/even or items per worker criteria here: every job counts, and workers run until the queue is closed
+	config := config.NewPoolConfig(enum.Undefined, numWorkers, len(jobs), 0)
+	pool := New(ctx, numWorkers, config)
+	defer pool.Close()
+
+	inFlight := make(chan struct{}, maxInFlight)
+	// Schedule from a different go routine, so the queue does not block before monitoring starts
+	go func() {
+		for i, job := range jobs {
+			if !pool.ScheduleWork(fetchTask{i, job, inFlight}) {
+				return
+			}
+		}
+		pool.CloseQueue()
+	}()
+
+	entries, err := pool.Monitor()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		result := entry.(FetchResult)
+		results[result.Index] = result
+	}
+
+	return results, nil
+}
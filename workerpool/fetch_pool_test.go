@@ -0,0 +1,129 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFetchAll - Test jobs run in the pool and their results keep the jobs order
+func TestFetchAll(t *testing.T) {
+	testCases := []struct {
+		name        string
+		numJobs     int
+		numWorkers  int
+		maxInFlight int
+		failing     int // Index of the failing job, -1 for none
+	}{
+		{
+			name:        "no jobs",
+			numJobs:     0,
+			numWorkers:  2,
+			maxInFlight: 0,
+			failing:     -1,
+		},
+		{
+			name:        "single worker",
+			numJobs:     10,
+			numWorkers:  1,
+			maxInFlight: 0,
+			failing:     -1,
+		},
+		{
+			name:        "more jobs than buffered channels",
+			numJobs:     200,
+			numWorkers:  4,
+			maxInFlight: 2,
+			failing:     -1,
+		},
+		{
+			name:        "failing job",
+			numJobs:     10,
+			numWorkers:  3,
+			maxInFlight: 3,
+			failing:     5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobs := make([]FetchJob, tc.numJobs)
+			for i := range jobs {
+				i := i
+				jobs[i] = func() (interface{}, error) {
+					if i == tc.failing {
+						return nil, errors.New("job failed")
+					}
+					return i * 10, nil
+				}
+			}
+
+			results, err := FetchAll(context.Background(), tc.numWorkers, tc.maxInFlight, jobs)
+
+			assert.Nil(t, err)
+			assert.Len(t, results, tc.numJobs)
+			for i, result := range results {
+				assert.Equal(t, i, result.Index)
+				if i == tc.failing {
+					assert.EqualError(t, result.Err, "job failed")
+				} else {
+					assert.Nil(t, result.Err)
+					assert.Equal(t, i*10, result.Value)
+				}
+			}
+		})
+	}
+}
+
+// TestFetchAll_MaxInFlight - Test no more than maxInFlight jobs run at the same time
+func TestFetchAll_MaxInFlight(t *testing.T) {
+	testCases := []struct {
+		name        string
+		numWorkers  int
+		maxInFlight int
+		expectedMax int32
+	}{
+		{
+			name:        "capped below workers",
+			numWorkers:  6,
+			maxInFlight: 2,
+			expectedMax: 2,
+		},
+		{
+			name:        "capped by workers",
+			numWorkers:  3,
+			maxInFlight: 0,
+			expectedMax: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var running, maxRunning int32
+			jobs := make([]FetchJob, 30)
+			for i := range jobs {
+				jobs[i] = func() (interface{}, error) {
+					n := atomic.AddInt32(&running, 1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+							break
+						}
+					}
+					time.Sleep(2 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return nil, nil
+				}
+			}
+
+			FetchAll(context.Background(), tc.numWorkers, tc.maxInFlight, jobs)
+
+			assert.LessOrEqual(t, maxRunning, tc.expectedMax)
+			assert.Greater(t, maxRunning, int32(0))
+		})
+	}
+}
@@ -1,21 +1,41 @@
 package workerpool
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"rincon-orlando/go-bootcamp/config"
+	"rincon-orlando/go-bootcamp/metrics"
+)
+
+// StopReason - Why a pool stopped producing items
+type StopReason string
+
+const (
+	ItemsReached     StopReason = "items_reached"     // The amount of requested items was found
+	WorkersExhausted StopReason = "workers_exhausted" // Every worker reached its items per worker limit
+	EOF              StopReason = "eof"               // There was no more work to process
+	Cancelled        StopReason = "cancelled"         // The pool context was cancelled or timed out
 )
 
 // GoRoutinePool struct that holds a set of jobs to execute, a wait group and config details
 type GoRoutinePool struct {
-	queue  chan work
-	wg     sync.WaitGroup
-	config config.GoRoutinePoolConfig
+	queue      chan work
+	queueOnce  sync.Once
+	queueMu    sync.RWMutex // Lets Close account for the work left in the queue, none can be scheduled afterwards
+	closed     bool
+	wg         sync.WaitGroup
+	config     config.GoRoutinePoolConfig
+	ctx        context.Context
+	cancel     context.CancelFunc
+	eofWorkers int32 // Workers that finished because the queue was drained
 }
 
 type workFunc interface {
-	Run(ch chan<- interface{}) bool
+	// Run must give up sending to ch as soon as ctx is done
+	Run(ctx context.Context, ch chan<- interface{}) bool
 }
 
 type work struct {
@@ -23,7 +43,9 @@ type work struct {
 }
 
 // New - Factory method
-func New(numWorkers int, config config.GoRoutinePoolConfig) *GoRoutinePool {
+// The pool stops as soon as ctx is done, or once Monitor or Close are called
+func New(ctx context.Context, numWorkers int, config config.GoRoutinePoolConfig) *GoRoutinePool {
+	ctx, cancel := context.WithCancel(ctx)
 	gp := &GoRoutinePool{
 		// Had to make this a buffered channel, otherwise this was blocking on some requests like
 		// http://localhost:8082/pokemons/filter?type=even&items=30&items_per_workers=1
@@ -31,6 +53,8 @@ func New(numWorkers int, config config.GoRoutinePoolConfig) *GoRoutinePool {
 		// http://localhost:8082/pokemons/filter?type=even&items=6&items_per_workers=1
 		queue:  make(chan work, 20),
 		config: config,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 
 	gp.AddWorkers(numWorkers)
@@ -38,8 +62,31 @@ func New(numWorkers int, config config.GoRoutinePoolConfig) *GoRoutinePool {
 }
 
 // ScheduleWork - Takes a work function and add it to a job channel for execution
-func (gp *GoRoutinePool) ScheduleWork(fn workFunc) {
-	gp.queue <- work{fn}
+// Returns false if the pool was stopped before the work could be scheduled
+func (gp *GoRoutinePool) ScheduleWork(fn workFunc) bool {
+	gp.queueMu.RLock()
+	defer gp.queueMu.RUnlock()
+	if gp.closed {
+		return false
+	}
+
+	// Counted before sending, so a worker taking it right away never makes the depth negative
+	metrics.PoolQueueDepth.Inc()
+	select {
+	case gp.queue <- work{fn}:
+		return true
+	case <-gp.ctx.Done():
+		metrics.PoolQueueDepth.Dec()
+		return false
+	}
+}
+
+// CloseQueue - Signals no more work will be scheduled, so workers finish once the queue is drained (EOF)
+// Must be called from the same go routine scheduling the work
+func (gp *GoRoutinePool) CloseQueue() {
+	gp.queueOnce.Do(func() {
+		close(gp.queue)
+	})
 }
 
 // AddWorkers - Add workers configured with the appropriate go routine
@@ -47,51 +94,123 @@ func (gp *GoRoutinePool) AddWorkers(numWorkers int) {
 	gp.wg.Add(numWorkers)
 	for i := 0; i < numWorkers; i++ {
 		go func(workerID int) {
+			metrics.PoolActiveWorkers.Inc()
+			defer func() {
+				metrics.PoolActiveWorkers.Dec()
+				fmt.Printf("Worker %d is done\n", workerID)
+				gp.wg.Done()                        // Complete this wait group task
+				gp.config.DoneChannel <- struct{}{} // Send a done signal for this
+			}()
+
 			items_per_worker := gp.config.ItemsPerWorker
-			for job := range gp.queue {
-				if job.fn.Run(gp.config.Channel) {
-					items_per_worker--
-					// This worker has finished its search because it was capped to certain items per worker
-					if items_per_worker == 0 {
-						// fmt.Printf("Worker %d found all its corresponding items\n", workerID)
-						break
+			for {
+				select {
+				case <-gp.ctx.Done():
+					// Cancelled, timed out or the monitor already has what it needs
+					return
+				case job, ok := <-gp.queue:
+					if !ok {
+						// No more work
+						atomic.AddInt32(&gp.eofWorkers, 1)
+						return
+					}
+					metrics.PoolQueueDepth.Dec()
+					if job.fn.Run(gp.ctx, gp.config.Channel) {
+						items_per_worker--
+						// This worker has finished its search because it was capped to certain items per worker
+						if items_per_worker == 0 {
+							return
+						}
 					}
 				}
 			}
-			fmt.Printf("Worker %d is done\n", workerID)
-			gp.wg.Done()                        // Complete this wait group task
-			gp.config.DoneChannel <- struct{}{} // Send a done signal for this
 		}(i)
 	}
 }
 
 // Monitor - Takes the logic to wait for the channels to be populated with the required information
-func (gp *GoRoutinePool) Monitor() []interface{} {
+// Returns once the items are found, all workers are done or the pool context is done, stopping the workers right away
+// The error is the context one if the pool was cancelled or timed out before finishing
+func (gp *GoRoutinePool) Monitor() ([]interface{}, error) {
 	response := make([]interface{}, 0)
+	_, err := gp.MonitorFunc(func(entry interface{}) {
+		response = append(response, entry)
+	})
+	return response, err
+}
+
+// MonitorFunc - Same as Monitor, but hands every item to fn as soon as a worker finds it instead of buffering them
+// Also tells why the pool stopped. fn runs on the caller go routine
+func (gp *GoRoutinePool) MonitorFunc(fn func(interface{})) (reason StopReason, err error) {
+	// No matter why we stop listening, workers have nothing else to do
+	defer gp.cancel()
+	defer func() {
+		metrics.PoolRuns.WithLabelValues(string(reason)).Inc()
+	}()
+
 	foundItems := 0
+	// Returns true once the amount of valid items you need to display as a response is reached
+	add := func(entry interface{}) bool {
+		fmt.Printf("New item arrived %s\n", entry)
+		metrics.PoolItemsFound.Inc()
+		fn(entry)
+		foundItems++
+		if foundItems == gp.config.Items {
+			fmt.Printf("Desired number of items [%d] obtained\n", gp.config.Items)
+			return true
+		}
+		return false
+	}
 
 	for n := gp.config.NumWorkers; n > 0; {
 		select {
 		case entry := <-gp.config.Channel:
-			fmt.Printf("New item arrived %s\n", entry)
-			response = append(response, entry)
-			foundItems++
-			// Case: reached the amount of valid items you need to display as a response
-			if foundItems == gp.config.Items {
-				// Look no more, we found the amount of pokemons requested
-				fmt.Printf("Desired number of items [%d] obtained\n", gp.config.Items)
-				n = 0 // Set this to 0 to break the outter loop
+			if add(entry) {
+				// Look no more, we found the amount of items requested
+				return ItemsReached, nil
 			}
 		case <-gp.config.DoneChannel:
 			n--
+		case <-gp.ctx.Done():
+			return Cancelled, gp.ctx.Err()
 		}
 	}
 
-	return response
+	// Every worker is done. Items are sent before the done signal, so the ones left are already buffered
+	for {
+		select {
+		case entry := <-gp.config.Channel:
+			if add(entry) {
+				return ItemsReached, nil
+			}
+		default:
+			// Had any worker found the queue drained, there was nothing else to do
+			if atomic.LoadInt32(&gp.eofWorkers) > 0 {
+				return EOF, nil
+			}
+			return WorkersExhausted, nil
+		}
+	}
 }
 
-// Close - Release the channel and waits for the waitgroup to complete
+// Close - Stops the workers and waits for the waitgroup to complete
 func (gp *GoRoutinePool) Close() {
-	close(gp.queue)
+	gp.cancel()
 	gp.wg.Wait()
+
+	// Work nobody is going to pick anymore
+	gp.queueMu.Lock()
+	defer gp.queueMu.Unlock()
+	gp.closed = true
+	for {
+		select {
+		case _, ok := <-gp.queue:
+			if !ok {
+				return
+			}
+			metrics.PoolQueueDepth.Dec()
+		default:
+			return
+		}
+	}
 }
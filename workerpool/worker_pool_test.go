@@ -0,0 +1,184 @@
+package workerpool
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"rincon-orlando/go-bootcamp/config"
+	"rincon-orlando/go-bootcamp/metrics"
+	"rincon-orlando/go-bootcamp/util/enum"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Work function matching every n-th value, so the output channel fills up quickly
+type testTask struct {
+	value int
+	every int
+}
+
+func (tt testTask) Run(ctx context.Context, ch chan<- interface{}) bool {
+	if tt.value%tt.every != 0 {
+		return false
+	}
+	select {
+	case ch <- tt.value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Waits a bit for the go routines to go back to the given number, failing otherwise
+func assertNoLeaks(t *testing.T, baseline int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "go routines leaked")
+}
+
+// TestGoRoutinePool_MonitorFunc - Test the pool stops for every possible reason, without leaking go routines
+// nor leaving workers or work behind in the metrics
+func TestGoRoutinePool_MonitorFunc(t *testing.T) {
+	testCases := []struct {
+		name           string
+		numTasks       int
+		every          int
+		numWorkers     int
+		items          int
+		itemsPerWorker int
+		closeQueue     bool
+		timeout        time.Duration
+		cancelAfter    time.Duration
+		expectedItems  int
+		expectedReason StopReason
+		expectedError  error
+	}{
+		{
+			name:           "items reached with plenty of pending work",
+			numTasks:       10000,
+			every:          1,
+			numWorkers:     4,
+			items:          3,
+			closeQueue:     true,
+			expectedItems:  3,
+			expectedReason: ItemsReached,
+		},
+		{
+			name:           "items per worker reached",
+			numTasks:       10000,
+			every:          1,
+			numWorkers:     4,
+			items:          100,
+			itemsPerWorker: 2,
+			closeQueue:     true,
+			expectedItems:  8,
+			expectedReason: WorkersExhausted,
+		},
+		{
+			name:           "end of work before items reached",
+			numTasks:       30,
+			every:          3,
+			numWorkers:     4,
+			items:          100,
+			closeQueue:     true,
+			expectedItems:  10,
+			expectedReason: EOF,
+		},
+		{
+			name:           "deadline",
+			numTasks:       100,
+			every:          1,
+			numWorkers:     4,
+			items:          1000,
+			closeQueue:     false, // Workers wait for more work forever, only the deadline stops them
+			timeout:        20 * time.Millisecond,
+			expectedReason: Cancelled,
+			expectedError:  context.DeadlineExceeded,
+		},
+		{
+			name:           "cancelled",
+			numTasks:       100,
+			every:          1,
+			numWorkers:     4,
+			items:          1000,
+			closeQueue:     false,
+			cancelAfter:    20 * time.Millisecond,
+			expectedReason: Cancelled,
+			expectedError:  context.Canceled,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc // The producer go routine may outlive this iteration
+		t.Run(tc.name, func(t *testing.T) {
+			baseline := runtime.NumGoroutine()
+			runs := testutil.ToFloat64(metrics.PoolRuns.WithLabelValues(string(tc.expectedReason)))
+			found := testutil.ToFloat64(metrics.PoolItemsFound)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			if tc.cancelAfter > 0 {
+				time.AfterFunc(tc.cancelAfter, cancel)
+			}
+
+			cfg := config.NewPoolConfig(enum.Undefined, tc.numWorkers, tc.items, tc.itemsPerWorker)
+			pool := New(ctx, tc.numWorkers, cfg)
+
+			go func() {
+				for i := 1; i <= tc.numTasks; i++ {
+					if !pool.ScheduleWork(testTask{i, tc.every}) {
+						return
+					}
+				}
+				if tc.closeQueue {
+					pool.CloseQueue()
+				}
+			}()
+
+			items := 0
+			reason, err := pool.MonitorFunc(func(interface{}) { items++ })
+			pool.Close()
+
+			assert.Equal(t, tc.expectedReason, reason)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedItems, items)
+			}
+
+			assertNoLeaks(t, baseline)
+			assert.Equal(t, runs+1, testutil.ToFloat64(metrics.PoolRuns.WithLabelValues(string(tc.expectedReason))))
+			assert.Equal(t, found+float64(items), testutil.ToFloat64(metrics.PoolItemsFound))
+			assert.Equal(t, 0.0, testutil.ToFloat64(metrics.PoolActiveWorkers))
+			assert.Equal(t, 0.0, testutil.ToFloat64(metrics.PoolQueueDepth))
+		})
+	}
+}
+
+// TestGoRoutinePool_Monitor - Test the buffered monitor collects every item found
+func TestGoRoutinePool_Monitor(t *testing.T) {
+	cfg := config.NewPoolConfig(enum.Undefined, 3, 100, 0)
+	pool := New(context.Background(), 3, cfg)
+	defer pool.Close()
+
+	go func() {
+		for i := 1; i <= 20; i++ {
+			pool.ScheduleWork(testTask{i, 2})
+		}
+		pool.CloseQueue()
+	}()
+
+	response, err := pool.Monitor()
+	assert.Nil(t, err)
+	assert.Len(t, response, 10)
+}